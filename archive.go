@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// forecastArchive keeps every fetched forecast in dir, with one file per
+// backend and location holding one JSON object per line. Unlike the cache it
+// never expires, so forecasts can be compared with earlier ones.
+type forecastArchive struct {
+	dir    string
+	record bool
+}
+
+// file returns the archive file of location fetched from backend.
+func (a *forecastArchive) file(backend, location string) string {
+	sum := sha256.Sum256([]byte(backend + "\x00" + strings.ToLower(location)))
+	return filepath.Join(a.dir, hex.EncodeToString(sum[:12])+".jsonl")
+}
+
+// add appends data fetched for q, if archiving is enabled.
+func (a *forecastArchive) add(q query, data iface.Data) error {
+	if !a.record {
+		return nil
+	}
+	b, err := json.Marshal(cacheEntry{q.backend, q.location, q.numdays, time.Now(), data})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(a.dir, 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(a.file(q.backend, q.location), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// entries returns the archived forecasts of location fetched from backend in
+// the order they were fetched.
+func (a *forecastArchive) entries(backend, location string) ([]cacheEntry, error) {
+	return readArchive(a.file(backend, location))
+}
+
+// all returns the archived forecasts of all backends and locations.
+func (a *forecastArchive) all() ([]cacheEntry, error) {
+	files, err := filepath.Glob(filepath.Join(a.dir, "*.jsonl"))
+	if err != nil {
+		return nil, err
+	}
+	var ret []cacheEntry
+	for _, f := range files {
+		entries, err := readArchive(f)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, entries...)
+	}
+	return ret, nil
+}
+
+func readArchive(path string) ([]cacheEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret []cacheEntry
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 16<<20)
+	for s.Scan() {
+		var e cacheEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			continue
+		}
+		ret = append(ret, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to read the archive %s: %v", path, err)
+	}
+	return ret, nil
+}
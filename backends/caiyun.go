@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
@@ -17,6 +16,8 @@ import (
 const (
 	CAIYUNAPI       = "http://api.caiyunapp.com/v2.6/%s/%s/weather?lang=%s&dailysteps=%s&hourlysteps=%s&alert=true&unit=metric:v2&begin=%s&granu=%s"
 	CAIYUNDATE_TMPL = "2006-01-02T15:04-07:00"
+	// days beyond the 360 hourly steps of the api only get the daily aggregates
+	CAIYUN_MAX_HOURLY_STEPS = 360
 )
 
 type CaiyunConfig struct {
@@ -31,6 +32,21 @@ func (c *CaiyunConfig) Setup() {
 	flag.BoolVar(&c.debug, "caiyun-debug", true, "caiyun backend: print raw requests and responses")
 }
 
+func (c *CaiyunConfig) Capabilities() iface.Capabilities {
+	return iface.Capabilities{
+		Fields:          []string{"Desc", "TempC", "FeelsLikeC", "ChanceOfRainPercent", "PrecipM", "VisibleDistM", "WindspeedKmph", "WinddirDegree", "Humidity"},
+		MaxDays:         15,
+		Resolution:      time.Hour,
+		Region:          "worldwide",
+		KeyFlag:         "caiyun-api-key",
+		LocationFormats: []string{iface.LocationCoordinates},
+		LanguageFlag:    "caiyun-lang",
+		Languages:       []string{"en", "en_US", "en_GB", "zh_CN", "zh_TW", "ja"},
+		Alerts:          true,
+		Nowcast:         true,
+	}
+}
+
 var SkyconToIfaceCode map[string]iface.WeatherCode
 
 func init() {
@@ -78,17 +94,21 @@ func ParseCoordinates(latlng string) (float64, float64, error) {
 
 func (c *CaiyunConfig) GetWeatherDataFromLocalBegin(lng float64, lat float64, numdays int) (*CaiyunWeather, error) {
 	cyLocation := fmt.Sprintf("%v,%v", lng, lat)
+	hourlySteps := numdays * 24
+	if hourlySteps > CAIYUN_MAX_HOURLY_STEPS {
+		hourlySteps = CAIYUN_MAX_HOURLY_STEPS
+	}
 
 	localBegin, err := func() (*time.Time, error) {
 		now := time.Now()
 		url := fmt.Sprintf(
 			CAIYUNAPI, c.apiKey, cyLocation, c.lang,
-			strconv.FormatInt(int64(numdays), 10), strconv.FormatInt(int64(numdays)*24, 10),
+			strconv.FormatInt(int64(numdays), 10), strconv.FormatInt(int64(hourlySteps), 10),
 			strconv.FormatInt(now.Unix(), 10),
 			"realtime",
 		)
 		url += "fields=temperature"
-		resp, err := http.Get(url)
+		resp, err := iface.HTTPClient.Get(url)
 		if err != nil {
 			return nil, err
 		}
@@ -107,7 +127,7 @@ func (c *CaiyunConfig) GetWeatherDataFromLocalBegin(lng float64, lat float64, nu
 
 		loc, err := time.LoadLocation(weatherData.Timezone)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		localNow := now.In(loc)
 		localBegin := time.Date(localNow.Year(), localNow.Month(), localNow.Day(), 0, 0, 0, 0, loc)
@@ -119,11 +139,11 @@ func (c *CaiyunConfig) GetWeatherDataFromLocalBegin(lng float64, lat float64, nu
 
 	url := fmt.Sprintf(
 		CAIYUNAPI, c.apiKey, cyLocation, c.lang,
-		strconv.FormatInt(int64(numdays), 10), strconv.FormatInt(int64(numdays)*24, 10),
+		strconv.FormatInt(int64(numdays), 10), strconv.FormatInt(int64(hourlySteps), 10),
 		strconv.FormatInt(localBegin.Unix(), 10),
 		"realtime,minutely,hourly,daily",
 	)
-	resp, err := http.Get(url)
+	resp, err := iface.HTTPClient.Get(url)
 	if err != nil {
 		return nil, err
 	}
@@ -142,18 +162,41 @@ func (c *CaiyunConfig) GetWeatherDataFromLocalBegin(lng float64, lat float64, nu
 	return weatherData, nil
 }
 
-func (c *CaiyunConfig) Fetch(location string, numdays int) iface.Data {
+// caiyunAlertSeverity maps the last two digits of an alert code, the colors
+// blue, yellow, orange and red, to the iface.Alert severities.
+func caiyunAlertSeverity(code string) string {
+	if len(code) != 4 {
+		return ""
+	}
+	switch code[2:] {
+	case "01":
+		return "minor"
+	case "02":
+		return "moderate"
+	case "03":
+		return "severe"
+	case "04":
+		return "extreme"
+	}
+	return ""
+}
+
+func (c *CaiyunConfig) Fetch(location string, numdays int) (iface.Data, error) {
 	if c.debug {
 		log.Printf("caiyun location %v", location)
 	}
 	res := iface.Data{}
 	lat, lng, err := ParseCoordinates(location)
 	if err != nil {
-		panic(err)
+		return res, err
 	}
 	weatherData, err := c.GetWeatherDataFromLocalBegin(lng, lat, numdays)
 	if err != nil {
-		panic(err)
+		return res, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+	loc, err := time.LoadLocation(weatherData.Timezone)
+	if err != nil {
+		return res, fmt.Errorf("Unknown time zone %q in the response: %v", weatherData.Timezone, err)
 	}
 	res.Current.Desc = weatherData.Result.Minutely.Description + "\t" + weatherData.Result.Hourly.Description
 
@@ -180,6 +223,8 @@ func (c *CaiyunConfig) Fetch(location string, numdays int) iface.Data {
 		x := int(weatherData.Result.Realtime.Wind.Direction)
 		return &x
 	}()
+	// with unit=metric:v2 the api returns the wind speed in km/h and the
+	// visibility in km
 	res.Current.WindspeedKmph = func() *float32 {
 		x := float32(weatherData.Result.Realtime.Wind.Speed)
 		return &x
@@ -201,18 +246,28 @@ func (c *CaiyunConfig) Fetch(location string, numdays int) iface.Data {
 		return &x
 	}()
 	res.Current.VisibleDistM = func() *float32 {
-		x := float32(weatherData.Result.Realtime.Visibility)
+		x := float32(weatherData.Result.Realtime.Visibility) * 1000
 		return &x
 	}()
-	res.Current.Time = func() time.Time {
-		loc, err := time.LoadLocation(weatherData.Timezone)
-		if err != nil {
-			panic(err)
+	res.Current.Time = time.Now().In(loc)
+	if minutely := weatherData.Result.Minutely; len(minutely.Precipitation2H) > 0 {
+		// the minutely series starts at the time of the request, in mm/h
+		start := time.Now()
+		if weatherData.ServerTime > 0 {
+			start = time.Unix(int64(weatherData.ServerTime), 0)
 		}
-		return time.Now().In(loc)
-	}()
+		res.Nowcast = &iface.Nowcast{
+			Start: start.Truncate(time.Minute),
+			Step:  time.Minute,
+			Desc:  minutely.Description,
+		}
+		for _, mmh := range minutely.Precipitation2H {
+			res.Nowcast.PrecipM = append(res.Nowcast.PrecipM, float32(mmh)/1000)
+		}
+	}
+
 	dailyDataSlice := []iface.Day{}
-	for i := 0; i < numdays; i++ {
+	for i := 0; i < numdays && i < len(weatherData.Result.Daily.Temperature); i++ {
 		weatherDailyData := weatherData.Result.Daily
 
 		dailyData := iface.Day{
@@ -228,7 +283,7 @@ func (c *CaiyunConfig) Fetch(location string, numdays int) iface.Data {
 
 		dailyData.Astronomy = iface.Astro{
 			Sunrise: func() time.Time {
-				s := strings.Split(weatherDailyData.Astro[i].Sunset.Time, ":")
+				s := strings.Split(weatherDailyData.Astro[i].Sunrise.Time, ":")
 				hourStr := s[0]
 				minuteStr := s[1]
 				hour, err := strconv.Atoi(hourStr)
@@ -259,6 +314,31 @@ func (c *CaiyunConfig) Fetch(location string, numdays int) iface.Data {
 			}(),
 		}
 
+		dailyData.Summary = &iface.Summary{
+			MinTempC: func() *float32 {
+				x := float32(weatherDailyData.Temperature[i].Min)
+				return &x
+			}(),
+			MaxTempC: func() *float32 {
+				x := float32(weatherDailyData.Temperature[i].Max)
+				return &x
+			}(),
+		}
+		if i < len(weatherDailyData.Precipitation) {
+			// the daily average is a rate in mm/h
+			x := float32(weatherDailyData.Precipitation[i].Avg) * 24 / 1000
+			dailyData.Summary.PrecipM = &x
+		}
+		if i < len(weatherDailyData.Wind) {
+			x := float32(weatherDailyData.Wind[i].Max.Speed)
+			dailyData.Summary.MaxWindspeedKmph = &x
+		}
+		if i < len(weatherDailyData.Skycon) {
+			if code, ok := SkyconToIfaceCode[weatherDailyData.Skycon[i].Value]; ok {
+				dailyData.Summary.Code = code
+			}
+		}
+
 		dateStr := weatherDailyData.Temperature[i].Date[0:10]
 
 		weatherHourlyData := weatherData.Result.Hourly
@@ -273,11 +353,11 @@ func (c *CaiyunConfig) Fetch(location string, numdays int) iface.Data {
 					return &x
 				}(),
 				VisibleDistM: func() *float32 {
-					x := float32(weatherHourlyData.Visibility[index].Value)
+					x := float32(weatherHourlyData.Visibility[index].Value) * 1000
 					return &x
 				}(),
 				Humidity: func() *int {
-					x := int(weatherHourlyData.Humidity[index].Value)
+					x := int(weatherHourlyData.Humidity[index].Value * 100)
 					return &x
 				}(),
 				WindspeedKmph: func() *float32 {
@@ -317,11 +397,21 @@ func (c *CaiyunConfig) Fetch(location string, numdays int) iface.Data {
 	}
 	res.Forecast = dailyDataSlice
 
+	for _, alert := range weatherData.Result.Alert.Content {
+		res.Alerts = append(res.Alerts, iface.Alert{
+			Title:       alert.Title,
+			Description: alert.Description,
+			Severity:    caiyunAlertSeverity(alert.Code),
+			Sender:      alert.Source,
+			Start:       time.Unix(int64(alert.Pubtimestamp), 0),
+		})
+	}
+
 	res.GeoLoc = &iface.LatLon{
 		Latitude:  float32(weatherData.Location[0]),
 		Longitude: float32(weatherData.Location[1]),
 	}
-	return res
+	return res, nil
 }
 
 func init() {
@@ -0,0 +1,197 @@
+package backends
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+	"github.com/schachmat/wego/mqtt/mqtttest"
+)
+
+// fixtureDir holds the recorded responses for every backend in a directory
+// named like the backend. A response is stored as HOST_BASE.json, where BASE is
+// the last element of the request path, for example api.met.no_compact.json.
+// Characters other than letters, digits, dots, dashes and underscores are
+// replaced by underscores. This is the format of wego -record, so recordings
+// can be added as fixtures.
+const fixtureDir = "testdata/conformance"
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// fixtureTransport sends all requests to the test server instead, prefixing
+// the path with the original host.
+type fixtureTransport struct {
+	server *httptest.Server
+	next   http.RoundTripper
+}
+
+func (t fixtureTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	req := r.Clone(r.Context())
+	req.URL.Scheme = "http"
+	req.URL.Host = t.server.Listener.Addr().String()
+	req.URL.Path = "/" + r.URL.Host + r.URL.Path
+	req.Host = ""
+	return t.next.RoundTrip(req)
+}
+
+// replay serves the fixtures of backend through an httptest.Server and routes
+// all http requests of the default transport to it until the test finishes.
+func replay(t *testing.T, backend string) {
+	dir := filepath.Join(fixtureDir, backend)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+		name := unsafeFileChars.ReplaceAllString(host+"_"+path.Base(r.URL.Path), "_") + ".json"
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("no fixture %s for the request %s", name, r.URL)
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(b)
+	}))
+
+	old := http.DefaultTransport
+	http.DefaultTransport = fixtureTransport{server, old}
+	t.Cleanup(func() {
+		http.DefaultTransport = old
+		server.Close()
+	})
+}
+
+// checkCond reports the violations of the documented iface.Cond ranges.
+func checkCond(t *testing.T, where string, c iface.Cond) {
+	t.Helper()
+	if p := c.ChanceOfRainPercent; p != nil && (*p < 0 || *p > 100) {
+		t.Errorf("%s: ChanceOfRainPercent %d is not in [0, 100]", where, *p)
+	}
+	if p := c.PrecipM; p != nil && *p < 0 {
+		t.Errorf("%s: PrecipM %v is negative", where, *p)
+	}
+	if p := c.VisibleDistM; p != nil && *p < 0 {
+		t.Errorf("%s: VisibleDistM %v is negative", where, *p)
+	}
+	if p := c.WindspeedKmph; p != nil && *p < 0 {
+		t.Errorf("%s: WindspeedKmph %v is negative", where, *p)
+	}
+	if p := c.WinddirDegree; p != nil && (*p < 0 || *p > 359) {
+		t.Errorf("%s: WinddirDegree %d is not in [0, 359]", where, *p)
+	}
+	if p := c.Humidity; p != nil && (*p < 0 || *p > 100) {
+		t.Errorf("%s: Humidity %d is not in [0, 100]", where, *p)
+	}
+}
+
+// checkData reports the violations of the iface contract in data fetched for
+// numdays days.
+func checkData(t *testing.T, data iface.Data, numdays int) {
+	t.Helper()
+	if len(data.Forecast) == 0 {
+		t.Errorf("no forecast for %d days", numdays)
+	} else if len(data.Forecast) > numdays {
+		t.Errorf("got %d days of forecast, want at most %d", len(data.Forecast), numdays)
+	}
+	if _, warnings := iface.Normalize(data, numdays); len(warnings) > 0 {
+		t.Errorf("the data needs normalization:\n%s", strings.Join(warnings, "\n"))
+	}
+	checkCond(t, "current", data.Current)
+	for i, day := range data.Forecast {
+		for j, slot := range day.Slots {
+			where := day.Date.Format("Jan 02") + " slot " + slot.Time.Format("15:04")
+			checkCond(t, where, slot)
+			if j > 0 && slot.Time.Before(day.Slots[j-1].Time) {
+				t.Errorf("day %d: slot %d at %v is before the previous one", i, j, slot.Time)
+			}
+		}
+	}
+}
+
+// TestConformance fetches recorded responses with every registered backend and
+// checks the result against the iface contract. New backends need fixtures in
+// testdata/conformance.
+func TestConformance(t *testing.T) {
+	defer func(old *flag.FlagSet) { flag.CommandLine = old }(flag.CommandLine)
+	flag.CommandLine = flag.NewFlagSet("wego", flag.ContinueOnError)
+
+	names := make([]string, 0, len(iface.AllBackends))
+	for name, be := range iface.AllBackends {
+		names = append(names, name)
+		be.Setup()
+	}
+	sort.Strings(names)
+
+	// the mqtt backend reads retained sensor values from a local broker
+	broker := mqtttest.NewBroker()
+	defer broker.Close()
+	broker.Retain("garden/temperature", "18.4")
+	broker.Retain("zigbee2mqtt/garden", `{"humidity":71,"battery":96}`)
+
+	flag.VisitAll(func(f *flag.Flag) {
+		switch {
+		case strings.HasSuffix(f.Name, "-api-key"):
+			f.Value.Set("test-key")
+		case strings.HasSuffix(f.Name, "-debug"):
+			f.Value.Set("false")
+		case strings.HasSuffix(f.Name, "-nowcast"):
+			f.Value.Set("true")
+		case f.Name == "pws-url":
+			f.Value.Set("http://192.168.1.10/get_livedata_info")
+		case f.Name == "mqtt-sensor-broker":
+			f.Value.Set(broker.Addr())
+		case f.Name == "mqtt-sensor-temperature":
+			f.Value.Set("garden/temperature")
+		case f.Name == "mqtt-sensor-humidity":
+			f.Value.Set("zigbee2mqtt/garden")
+		case f.Name == "mqtt-sensor-humidity-key":
+			f.Value.Set("humidity")
+		}
+	})
+
+	for _, name := range names {
+		be := iface.AllBackends[name]
+		t.Run(name, func(t *testing.T) {
+			if _, err := os.Stat(filepath.Join(fixtureDir, name)); err != nil {
+				t.Fatalf("no fixtures in %s", filepath.Join(fixtureDir, name))
+			}
+			replay(t, name)
+
+			// backends reading files get the forecast.json fixture
+			location := "59.329,18.068"
+			var caps iface.Capabilities
+			if cb, ok := be.(iface.CapableBackend); ok {
+				caps = cb.Capabilities()
+				for _, f := range caps.LocationFormats {
+					if f == iface.LocationFile {
+						location = filepath.Join(fixtureDir, name, "forecast.json")
+					}
+				}
+			}
+			for _, numdays := range []int{1, 3} {
+				data, err := be.Fetch(location, numdays)
+				if err != nil {
+					t.Fatalf("Fetch(%q, %d): %v", location, numdays, err)
+				}
+				checkData(t, data, numdays)
+				if data.Nowcast != nil && !caps.Nowcast {
+					t.Errorf("got a nowcast, but the capabilities do not declare it")
+				}
+			}
+			if hb, ok := be.(iface.HistoricalBackend); ok {
+				data, err := hb.History(location, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+				if err != nil {
+					t.Fatalf("History(%q): %v", location, err)
+				}
+				checkData(t, data, 1)
+			}
+		})
+	}
+}
@@ -2,8 +2,8 @@ package backends
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
-	"log"
 
 	"github.com/schachmat/wego/iface"
 )
@@ -18,15 +18,15 @@ func (c *jsnConfig) Setup() {
 // read it as json content to fill the data. The numdays argument will only work
 // to further limit the amount of days in the output. It obviously cannot
 // produce more data than is available in the file.
-func (c *jsnConfig) Fetch(loc string, numdays int) (ret iface.Data) {
+func (c *jsnConfig) Fetch(loc string, numdays int) (ret iface.Data, err error) {
 	b, err := os.ReadFile(loc)
 	if err != nil {
-		log.Fatal(err)
+		return ret, err
 	}
 
 	err = json.Unmarshal(b, &ret)
 	if err != nil {
-		log.Fatal(err)
+		return ret, fmt.Errorf("Unable to parse %s: %v", loc, err)
 	}
 
 	if len(ret.Forecast) > numdays {
@@ -35,6 +35,16 @@ func (c *jsnConfig) Fetch(loc string, numdays int) (ret iface.Data) {
 	return
 }
 
+func (c *jsnConfig) Capabilities() iface.Capabilities {
+	return iface.Capabilities{
+		Fields:          []string{"Desc", "TempC", "FeelsLikeC", "ChanceOfRainPercent", "PrecipM", "VisibleDistM", "WindspeedKmph", "WindGustKmph", "WinddirDegree", "Humidity"},
+		Region:          "the forecast in the file",
+		LocationFormats: []string{iface.LocationFile},
+		Alerts:          true,
+		Nowcast:         true,
+	}
+}
+
 func init() {
 	iface.AllBackends["json"] = &jsnConfig{}
 }
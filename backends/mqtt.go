@@ -0,0 +1,188 @@
+package backends
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+	"github.com/schachmat/wego/mqtt"
+)
+
+// mqttConfig reads the current conditions from sensors publishing to an MQTT
+// broker, like those of Zigbee2MQTT, Tasmota or rtl_433, and the forecast
+// from another backend.
+type mqttConfig struct {
+	broker   string
+	user     string
+	password string
+	topics   map[string]*string
+	keys     map[string]*string
+	timeout  time.Duration
+	forecast string
+	debug    bool
+}
+
+// mqttFields are the measurements read from sensor topics with the unit
+// assumed for values without one.
+var mqttFields = []struct {
+	name, unit, desc string
+}{
+	{"temperature", "C", "temperature in °C"},
+	{"humidity", "%", "relative humidity in %"},
+	{"wind", "km/h", "wind speed in km/h"},
+	{"gust", "km/h", "wind gusts in km/h"},
+	{"winddir", "", "wind direction in degrees"},
+	{"rain", "mm/h", "precipitation in mm/h"},
+}
+
+func (c *mqttConfig) Setup() {
+	flag.StringVar(&c.broker, "mqtt-sensor-broker", "localhost:1883", "mqtt backend: `HOST:PORT` of the broker")
+	flag.StringVar(&c.user, "mqtt-sensor-user", "", "mqtt backend: `USER` name for the broker")
+	flag.StringVar(&c.password, "mqtt-sensor-password", "", "mqtt backend: `PASSWORD` for the broker, sent unencrypted as the connection is plain TCP")
+	c.topics = make(map[string]*string)
+	c.keys = make(map[string]*string)
+	for _, f := range mqttFields {
+		c.topics[f.name] = flag.String("mqtt-sensor-"+f.name, "", "mqtt backend: `TOPIC` of the "+f.desc+", without wildcards")
+		c.keys[f.name] = flag.String("mqtt-sensor-"+f.name+"-key", "", "mqtt backend: `KEY` of the "+f.desc+" in a json payload, nested keys separated by dots")
+	}
+	flag.DurationVar(&c.timeout, "mqtt-sensor-timeout", 10*time.Second, "mqtt backend: `DURATION` to wait for the sensors without retained values")
+	flag.StringVar(&c.forecast, "mqtt-sensor-forecast", "openmeteo", "mqtt backend: `BACKEND` providing the forecast")
+	flag.BoolVar(&c.debug, "mqtt-sensor-debug", false, "mqtt backend: print the received messages")
+}
+
+// Capabilities are those of the forecast backend, as the sensors only add the
+// current conditions.
+func (c *mqttConfig) Capabilities() iface.Capabilities {
+	if be, ok := iface.AllBackends[c.forecast].(iface.CapableBackend); ok && be != iface.Backend(c) {
+		return be.Capabilities()
+	}
+	return iface.Capabilities{
+		Region:          "the location of the sensors",
+		LocationFormats: []string{iface.LocationCoordinates, iface.LocationPostcode, iface.LocationName},
+	}
+}
+
+// mqttValue returns the value of a sensor payload. The payload is either the
+// value, optionally followed by its unit, or a json object if key is set.
+// Keys of nested objects are separated by dots. Values in unknown units are
+// left out.
+func mqttValue(payload []byte, key, unit string) *float32 {
+	if key == "" {
+		v, _ := pwsConvert(string(payload), unit)
+		return v
+	}
+	var v interface{}
+	if err := json.Unmarshal(payload, &v); err != nil {
+		return nil
+	}
+	for _, k := range strings.Split(key, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[k]
+	}
+	switch v := v.(type) {
+	case float64:
+		ret, _ := pwsConvert(strconv.FormatFloat(v, 'f', -1, 64), unit)
+		return ret
+	case string:
+		ret, _ := pwsConvert(v, unit)
+		return ret
+	}
+	return nil
+}
+
+// receive subscribes to the sensor topics and returns the measurements. It
+// waits until every topic has sent a value or the timeout passes.
+func (c *mqttConfig) receive() (ret iface.Cond, err error) {
+	type source struct{ field, key, unit string }
+	sources := make(map[string][]source)
+	var filters []string
+	for _, f := range mqttFields {
+		topic := *c.topics[f.name]
+		if topic == "" {
+			continue
+		}
+		if strings.ContainsAny(topic, "+#") {
+			return ret, fmt.Errorf("The topic %s of the %s sensor must not contain the wildcards + or #", topic, f.name)
+		}
+		key := ""
+		if k := c.keys[f.name]; k != nil {
+			key = *k
+		}
+		if _, ok := sources[topic]; !ok {
+			filters = append(filters, topic)
+		}
+		sources[topic] = append(sources[topic], source{f.name, key, f.unit})
+	}
+	if len(filters) == 0 {
+		return ret, fmt.Errorf("Set the topic of at least one sensor, like -mqtt-sensor-temperature")
+	}
+
+	client, err := mqtt.Dial(c.broker, mqtt.Options{ClientID: fmt.Sprintf("wego-%d", os.Getpid()), Username: c.user, Password: c.password, Timeout: c.timeout})
+	if err != nil {
+		return ret, fmt.Errorf("Unable to connect to the broker %s: %v", c.broker, err)
+	}
+	defer client.Close()
+	if err := client.Subscribe(filters...); err != nil {
+		return ret, fmt.Errorf("Unable to subscribe to the sensors: %v", err)
+	}
+	client.SetDeadline(time.Now().Add(c.timeout))
+
+	values := make(map[string]*float32)
+	seen := make(map[string]bool)
+	for len(seen) < len(filters) {
+		m, err := client.Receive()
+		if err != nil {
+			if len(seen) == 0 {
+				return ret, fmt.Errorf("No sensor sent a value within %v: %v", c.timeout, err)
+			}
+			break
+		}
+		if c.debug {
+			log.Printf("mqtt message %s: %s\n", m.Topic, m.Payload)
+		}
+		for _, s := range sources[m.Topic] {
+			values[s.field] = mqttValue(m.Payload, s.key, s.unit)
+			seen[m.Topic] = true
+		}
+	}
+
+	ret.Time = time.Now()
+	ret.TempC = values["temperature"]
+	ret.Humidity = pwsInt(values["humidity"])
+	ret.WindspeedKmph = values["wind"]
+	ret.WindGustKmph = values["gust"]
+	ret.WinddirDegree = pwsInt(values["winddir"])
+	ret.PrecipM = values["rain"]
+	return ret, nil
+}
+
+func (c *mqttConfig) Fetch(location string, numdays int) (iface.Data, error) {
+	be, ok := iface.AllBackends[c.forecast]
+	if !ok || be == iface.Backend(c) {
+		return iface.Data{}, fmt.Errorf("The mqtt backend needs another backend for the forecast, choose one with -mqtt-sensor-forecast instead of \"%s\".", c.forecast)
+	}
+	sensors, err := c.receive()
+	if err != nil {
+		return iface.Data{}, fmt.Errorf("Failed to read the sensors: %v", err)
+	}
+
+	ret, err := be.Fetch(location, numdays)
+	if err != nil {
+		return ret, err
+	}
+	ret.Current = pwsMerge(ret.Current, sensors)
+	return ret, nil
+}
+
+func init() {
+	iface.AllBackends["mqtt"] = &mqttConfig{}
+}
@@ -0,0 +1,84 @@
+package backends
+
+import (
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/mqtt"
+	"github.com/schachmat/wego/mqtt/mqtttest"
+)
+
+func TestMqttValue(t *testing.T) {
+	f := func(v float32) *float32 { return &v }
+	for _, tt := range []struct {
+		payload, key, unit string
+		want               *float32
+	}{
+		{"21.5", "", "C", f(21.5)},
+		{"70.7 °F", "", "C", f(21.5)},
+		{`{"temperature":21.5,"humidity":61}`, "humidity", "%", f(61)},
+		{`{"wind":{"speed":"5 m/s"}}`, "wind.speed", "km/h", f(18)},
+		{`{"battery":96}`, "humidity", "%", nil},
+		{"offline", "", "C", nil},
+	} {
+		got := mqttValue([]byte(tt.payload), tt.key, tt.unit)
+		if (got == nil) != (tt.want == nil) || (got != nil && !near(got, *tt.want)) {
+			t.Errorf("mqttValue(%s, %q) = %v, want %v", tt.payload, tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestMqttReceive(t *testing.T) {
+	b := mqtttest.NewBroker()
+	defer b.Close()
+	b.Retain("garden/temperature", "18.4")
+	b.Retain("rtl_433/ws:1/wind", "3.2")
+
+	topic := func(s string) *string { return &s }
+	c := &mqttConfig{broker: b.Addr(), timeout: 5 * time.Second, topics: map[string]*string{
+		"temperature": topic("garden/temperature"),
+		"humidity":    topic("tele/garden/SENSOR"),
+		"wind":        topic("rtl_433/ws:1/wind"),
+		"gust":        topic(""),
+		"winddir":     topic(""),
+		"rain":        topic(""),
+	}, keys: map[string]*string{
+		"humidity": topic("AM2301.Humidity"),
+	}}
+	// the humidity is not retained and only arrives with the next update
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		pub, err := mqtt.Dial(b.Addr(), mqtt.Options{ClientID: "tasmota"})
+		if err != nil {
+			return
+		}
+		pub.Publish("tele/garden/SENSOR", []byte(`{"AM2301":{"Temperature":18.3,"Humidity":64.6}}`), false)
+		pub.Close()
+	}()
+
+	cond, err := c.receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(cond.TempC, 18.4) || cond.Humidity == nil || *cond.Humidity != 65 || !near(cond.WindspeedKmph, 3.2) {
+		t.Errorf("got %+v", cond)
+	}
+
+	// missing sensors are left out after the timeout
+	c.timeout = 200 * time.Millisecond
+	*c.topics["wind"] = "garden/wind"
+	cond, err = c.receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !near(cond.TempC, 18.4) || cond.WindspeedKmph != nil {
+		t.Errorf("got %+v after the timeout", cond)
+	}
+
+	for _, wildcard := range []string{"garden/+", "garden/#"} {
+		*c.topics["wind"] = wildcard
+		if _, err := c.receive(); err == nil {
+			t.Errorf("the topic %s with a wildcard was accepted", wildcard)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+package backends
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type openMeteoConfig struct {
+	debug bool
+}
+
+type openMeteoResponse struct {
+	Latitude         float32 `json:"latitude"`
+	Longitude        float32 `json:"longitude"`
+	UTCOffsetSeconds int     `json:"utc_offset_seconds"`
+	Current          struct {
+		Time                string   `json:"time"`
+		Temperature         *float32 `json:"temperature_2m"`
+		ApparentTemperature *float32 `json:"apparent_temperature"`
+		Humidity            *int     `json:"relative_humidity_2m"`
+		Precipitation       *float32 `json:"precipitation"`
+		WeatherCode         *int     `json:"weather_code"`
+		WindSpeed           *float32 `json:"wind_speed_10m"`
+		WindDirection       *float32 `json:"wind_direction_10m"`
+		WindGusts           *float32 `json:"wind_gusts_10m"`
+	} `json:"current"`
+	Hourly struct {
+		Time                     []string   `json:"time"`
+		Temperature              []*float32 `json:"temperature_2m"`
+		ApparentTemperature      []*float32 `json:"apparent_temperature"`
+		Humidity                 []*int     `json:"relative_humidity_2m"`
+		PrecipitationProbability []*int     `json:"precipitation_probability"`
+		Precipitation            []*float32 `json:"precipitation"`
+		WeatherCode              []*int     `json:"weather_code"`
+		Visibility               []*float32 `json:"visibility"`
+		WindSpeed                []*float32 `json:"wind_speed_10m"`
+		WindDirection            []*float32 `json:"wind_direction_10m"`
+		WindGusts                []*float32 `json:"wind_gusts_10m"`
+	} `json:"hourly"`
+	Daily struct {
+		Time                        []string   `json:"time"`
+		WeatherCode                 []*int     `json:"weather_code"`
+		TemperatureMax              []*float32 `json:"temperature_2m_max"`
+		TemperatureMin              []*float32 `json:"temperature_2m_min"`
+		Sunrise                     []string   `json:"sunrise"`
+		Sunset                      []string   `json:"sunset"`
+		SunshineDuration            []*float32 `json:"sunshine_duration"`
+		PrecipitationSum            []*float32 `json:"precipitation_sum"`
+		PrecipitationProbabilityMax []*int     `json:"precipitation_probability_max"`
+		WindSpeedMax                []*float32 `json:"wind_speed_10m_max"`
+		WindGustsMax                []*float32 `json:"wind_gusts_10m_max"`
+	} `json:"daily"`
+	Error  bool   `json:"error"`
+	Reason string `json:"reason"`
+}
+
+const (
+	// see https://open-meteo.com/en/docs
+	openMeteoURI     = "https://api.open-meteo.com/v1/forecast?latitude=%s&longitude=%s&forecast_days=%d&timezone=auto&current=%s&hourly=%s&daily=%s"
+	openMeteoCurrent = "temperature_2m,apparent_temperature,relative_humidity_2m,precipitation,weather_code,wind_speed_10m,wind_direction_10m,wind_gusts_10m"
+	openMeteoHourly  = "temperature_2m,apparent_temperature,relative_humidity_2m,precipitation_probability,precipitation,weather_code,visibility,wind_speed_10m,wind_direction_10m,wind_gusts_10m"
+	openMeteoDaily   = "weather_code,temperature_2m_max,temperature_2m_min,sunrise,sunset,sunshine_duration,precipitation_sum,precipitation_probability_max,wind_speed_10m_max,wind_gusts_10m_max"
+	openMeteoMaxDays = 16
+
+	// the archive has neither probabilities nor visibility
+	openMeteoArchiveURI    = "https://archive-api.open-meteo.com/v1/archive?latitude=%s&longitude=%s&start_date=%s&end_date=%s&timezone=auto&hourly=%s&daily=%s"
+	openMeteoArchiveHourly = "temperature_2m,apparent_temperature,relative_humidity_2m,precipitation,weather_code,wind_speed_10m,wind_direction_10m,wind_gusts_10m"
+	openMeteoArchiveDaily  = "weather_code,temperature_2m_max,temperature_2m_min,sunrise,sunset,sunshine_duration,precipitation_sum,wind_speed_10m_max,wind_gusts_10m_max"
+)
+
+// openMeteoCodes maps the WMO weather interpretation codes used by open-meteo.
+var openMeteoCodes = map[int]struct {
+	code iface.WeatherCode
+	desc string
+}{
+	0:  {iface.CodeSunny, "Clear sky"},
+	1:  {iface.CodeSunny, "Mainly clear"},
+	2:  {iface.CodePartlyCloudy, "Partly cloudy"},
+	3:  {iface.CodeVeryCloudy, "Overcast"},
+	45: {iface.CodeFog, "Fog"},
+	48: {iface.CodeFog, "Depositing rime fog"},
+	51: {iface.CodeLightRain, "Light drizzle"},
+	53: {iface.CodeLightRain, "Moderate drizzle"},
+	55: {iface.CodeLightRain, "Dense drizzle"},
+	56: {iface.CodeLightSleet, "Light freezing drizzle"},
+	57: {iface.CodeLightSleet, "Dense freezing drizzle"},
+	61: {iface.CodeLightRain, "Slight rain"},
+	63: {iface.CodeLightRain, "Moderate rain"},
+	65: {iface.CodeHeavyRain, "Heavy rain"},
+	66: {iface.CodeLightSleet, "Light freezing rain"},
+	67: {iface.CodeLightSleet, "Heavy freezing rain"},
+	71: {iface.CodeLightSnow, "Slight snow fall"},
+	73: {iface.CodeLightSnow, "Moderate snow fall"},
+	75: {iface.CodeHeavySnow, "Heavy snow fall"},
+	77: {iface.CodeLightSnow, "Snow grains"},
+	80: {iface.CodeLightShowers, "Slight rain showers"},
+	81: {iface.CodeLightShowers, "Moderate rain showers"},
+	82: {iface.CodeHeavyShowers, "Violent rain showers"},
+	85: {iface.CodeLightSnowShowers, "Slight snow showers"},
+	86: {iface.CodeHeavySnowShowers, "Heavy snow showers"},
+	95: {iface.CodeThunderyShowers, "Thunderstorm"},
+	96: {iface.CodeThunderyHeavyRain, "Thunderstorm with slight hail"},
+	99: {iface.CodeThunderyHeavyRain, "Thunderstorm with heavy hail"},
+}
+
+func (c *openMeteoConfig) Setup() {
+	flag.BoolVar(&c.debug, "openmeteo-debug", false, "open-meteo backend: print raw requests and responses")
+}
+
+func (c *openMeteoConfig) Capabilities() iface.Capabilities {
+	return iface.Capabilities{
+		Fields:          []string{"Desc", "TempC", "FeelsLikeC", "ChanceOfRainPercent", "PrecipM", "VisibleDistM", "WindspeedKmph", "WindGustKmph", "WinddirDegree", "Humidity"},
+		MaxDays:         openMeteoMaxDays,
+		Resolution:      time.Hour,
+		Region:          "worldwide",
+		LocationFormats: []string{iface.LocationCoordinates},
+	}
+}
+
+func (c *openMeteoConfig) fetch(url string) (*openMeteoResponse, error) {
+	if c.debug {
+		log.Printf("open-meteo request %s\n", url)
+	}
+	resp, err := iface.HTTPClient.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get (%s): %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read response body (%s): %v", url, err)
+	}
+	if c.debug {
+		log.Printf("open-meteo response\n%s\n", body)
+	}
+
+	var response openMeteoResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("Unable to parse response (%s): %v", url, err)
+	}
+	if response.Error {
+		return nil, fmt.Errorf("Erroneous response (%s): %s", url, response.Reason)
+	}
+	return &response, nil
+}
+
+func (c *openMeteoConfig) Fetch(location string, numdays int) (ret iface.Data, err error) {
+	if iface.LocationFormat(location) != iface.LocationCoordinates {
+		return ret, fmt.Errorf("The open-meteo backend only supports latitude,longitude pairs as location.\nInstead of `%s` try `59.329,18.068` for example to get a forecast for Stockholm.", location)
+	}
+	if numdays > openMeteoMaxDays {
+		numdays = openMeteoMaxDays
+	}
+
+	s := strings.Split(location, ",")
+	days := numdays
+	if days < 1 {
+		days = 1
+	}
+	resp, err := c.fetch(fmt.Sprintf(openMeteoURI, s[0], s[1], days, openMeteoCurrent, openMeteoHourly, openMeteoDaily))
+	if err != nil {
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+
+	times := openMeteoTimes{loc: time.FixedZone("", resp.UTCOffsetSeconds)}
+	cur := resp.Current
+	ret.Current = iface.Cond{
+		Time:          times.parse(cur.Time),
+		TempC:         cur.Temperature,
+		FeelsLikeC:    cur.ApparentTemperature,
+		Humidity:      cur.Humidity,
+		PrecipM:       openMeteoMM(cur.Precipitation),
+		WindspeedKmph: cur.WindSpeed,
+		WindGustKmph:  cur.WindGusts,
+		WinddirDegree: openMeteoDegree(cur.WindDirection),
+	}
+	ret.Current.Code, ret.Current.Desc = openMeteoCode(cur.WeatherCode)
+	if times.err != nil {
+		return ret, times.err
+	}
+	if ret.Forecast, err = openMeteoDays(resp, numdays); err != nil {
+		return ret, err
+	}
+
+	ret.GeoLoc = &iface.LatLon{Latitude: resp.Latitude, Longitude: resp.Longitude}
+	ret.Location = location + " (Forecast provided by open-meteo.com)"
+	return ret, nil
+}
+
+// History looks up the weather of date in the archive of open-meteo, which
+// starts in 1940 and lags a few days behind.
+func (c *openMeteoConfig) History(location string, date time.Time) (ret iface.Data, err error) {
+	if iface.LocationFormat(location) != iface.LocationCoordinates {
+		return ret, fmt.Errorf("The open-meteo backend only supports latitude,longitude pairs as location.\nInstead of `%s` try `59.329,18.068` for example to get the weather of Stockholm.", location)
+	}
+
+	s := strings.Split(location, ",")
+	day := date.Format("2006-01-02")
+	resp, err := c.fetch(fmt.Sprintf(openMeteoArchiveURI, s[0], s[1], day, day, openMeteoArchiveHourly, openMeteoArchiveDaily))
+	if err != nil {
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+
+	if ret.Forecast, err = openMeteoDays(resp, 1); err != nil {
+		return ret, err
+	}
+	if len(ret.Forecast) == 0 || ret.Forecast[0].Summary.MaxTempC == nil {
+		return ret, fmt.Errorf("The open-meteo archive has no data for %s yet, it lags about five days behind.", day)
+	}
+	ret.GeoLoc = &iface.LatLon{Latitude: resp.Latitude, Longitude: resp.Longitude}
+	ret.Location = location + " (Weather history provided by open-meteo.com)"
+	return ret, nil
+}
+
+// openMeteoTimes parses the timestamps of a response, which are local times
+// without offset. The first timestamp failing to parse is kept in err.
+type openMeteoTimes struct {
+	loc *time.Location
+	err error
+}
+
+func (p *openMeteoTimes) parse(s string) time.Time {
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, p.loc); err == nil {
+			return t
+		}
+	}
+	if p.err == nil {
+		p.err = fmt.Errorf("Failed to parse timestamp %q", s)
+	}
+	return time.Time{}
+}
+
+// openMeteoDays returns at most numdays days of the hourly and daily values of
+// resp.
+func openMeteoDays(resp *openMeteoResponse, numdays int) (ret []iface.Day, err error) {
+	times := openMeteoTimes{loc: time.FixedZone("", resp.UTCOffsetSeconds)}
+	parseTime := times.parse
+	h := resp.Hourly
+	d := resp.Daily
+	for i, date := range d.Time {
+		if len(ret) == numdays {
+			break
+		}
+		day := iface.Day{
+			Date: parseTime(date),
+			Summary: &iface.Summary{
+				MinTempC:               openMeteoValue(d.TemperatureMin, i),
+				MaxTempC:               openMeteoValue(d.TemperatureMax, i),
+				PrecipM:                openMeteoMM(openMeteoValue(d.PrecipitationSum, i)),
+				MaxChanceOfRainPercent: openMeteoValue(d.PrecipitationProbabilityMax, i),
+				MaxWindspeedKmph:       openMeteoValue(d.WindSpeedMax, i),
+				MaxWindGustKmph:        openMeteoValue(d.WindGustsMax, i),
+			},
+		}
+		day.Summary.Code, _ = openMeteoCode(openMeteoValue(d.WeatherCode, i))
+		if secs := openMeteoValue(d.SunshineDuration, i); secs != nil {
+			hours := *secs / 3600
+			day.Summary.SunshineHours = &hours
+		}
+		if i < len(d.Sunrise) && i < len(d.Sunset) {
+			day.Astronomy.Sunrise = parseTime(d.Sunrise[i])
+			day.Astronomy.Sunset = parseTime(d.Sunset[i])
+		}
+
+		for j, t := range h.Time {
+			if !strings.HasPrefix(t, date) {
+				continue
+			}
+			slot := iface.Cond{
+				Time:                parseTime(t),
+				TempC:               openMeteoValue(h.Temperature, j),
+				FeelsLikeC:          openMeteoValue(h.ApparentTemperature, j),
+				Humidity:            openMeteoValue(h.Humidity, j),
+				ChanceOfRainPercent: openMeteoValue(h.PrecipitationProbability, j),
+				PrecipM:             openMeteoMM(openMeteoValue(h.Precipitation, j)),
+				VisibleDistM:        openMeteoValue(h.Visibility, j),
+				WindspeedKmph:       openMeteoValue(h.WindSpeed, j),
+				WindGustKmph:        openMeteoValue(h.WindGusts, j),
+				WinddirDegree:       openMeteoDegree(openMeteoValue(h.WindDirection, j)),
+			}
+			slot.Code, slot.Desc = openMeteoCode(openMeteoValue(h.WeatherCode, j))
+			day.Slots = append(day.Slots, slot)
+		}
+		ret = append(ret, day)
+	}
+	return ret, times.err
+}
+
+// openMeteoValue returns the i-th element of values, or nil if the api did not
+// return that many values.
+func openMeteoValue[T any](values []*T, i int) *T {
+	if i < len(values) {
+		return values[i]
+	}
+	return nil
+}
+
+// openMeteoMM converts an amount of precipitation in millimeters to meters.
+func openMeteoMM(mm *float32) *float32 {
+	if mm == nil {
+		return nil
+	}
+	m := *mm / 1000
+	return &m
+}
+
+func openMeteoDegree(deg *float32) *int {
+	if deg == nil {
+		return nil
+	}
+	d := int(*deg) % 360
+	return &d
+}
+
+func openMeteoCode(code *int) (iface.WeatherCode, string) {
+	if code == nil {
+		return iface.CodeUnknown, ""
+	}
+	if cond, ok := openMeteoCodes[*code]; ok {
+		return cond.code, cond.desc
+	}
+	return iface.CodeUnknown, ""
+}
+
+func init() {
+	iface.AllBackends["openmeteo"] = &openMeteoConfig{}
+}
@@ -7,7 +7,6 @@ import (
 	"github.com/schachmat/wego/iface"
 	"io"
 	"log"
-	"net/http"
 	"regexp"
 	"strings"
 	"time"
@@ -17,6 +16,7 @@ type openWeatherConfig struct {
 	apiKey string
 	lang   string
 	debug  bool
+	daily  bool
 }
 
 type openWeatherResponse struct {
@@ -24,10 +24,10 @@ type openWeatherResponse struct {
 	City struct {
 		Name    string `json:"name"`
 		Country string `json:"country"`
-		TimeZone int64 `json: "timezone"`
+		TimeZone int64 `json:"timezone"`
 		// sunrise/sunset are once per call
-		SunRise int64 `json: "sunrise"`
-		SunSet int64 `json: "sunset"`
+		SunRise int64 `json:"sunrise"`
+		SunSet int64 `json:"sunset"`
 	} `json:"city"`
 	List []dataBlock `json:"list"`
 }
@@ -46,8 +46,8 @@ type dataBlock struct {
 	} `json:"weather"`
 
 	Wind struct {
-		Speed float32 `json:"speed"`
-		Deg   float32 `json:"deg"`
+		Speed float32  `json:"speed"`
+		Deg   *float32 `json:"deg"`
 	} `json:"wind"`
 
 	Rain struct {
@@ -55,18 +55,140 @@ type dataBlock struct {
 	} `json:"rain"`
 }
 
+// openWeatherDailyResponse is the response of the daily forecast, which covers
+// up to 16 days.
+type openWeatherDailyResponse struct {
+	Cod  string `json:"cod"`
+	List []struct {
+		Dt      int64 `json:"dt"`
+		Sunrise int64 `json:"sunrise"`
+		Sunset  int64 `json:"sunset"`
+		Temp    struct {
+			Min float32 `json:"min"`
+			Max float32 `json:"max"`
+		} `json:"temp"`
+		Weather []struct {
+			ID int `json:"id"`
+		} `json:"weather"`
+		Speed float32  `json:"speed"`
+		Gust  *float32 `json:"gust"`
+		Pop   *float32 `json:"pop"`
+		Rain  float32  `json:"rain"`
+		Snow  float32  `json:"snow"`
+	} `json:"list"`
+}
+
 const (
-	openweatherURI = "http://api.openweathermap.org/data/2.5/forecast?%s&appid=%s&units=metric&lang=%s"
+	openweatherURI      = "http://api.openweathermap.org/data/2.5/forecast?%s&appid=%s&units=metric&lang=%s"
+	openweatherDailyURI = "http://api.openweathermap.org/data/2.5/forecast/daily?%s&appid=%s&units=metric&lang=%s&cnt=%d"
 )
 
+var openWeatherCodes = map[int]iface.WeatherCode{
+	200: iface.CodeThunderyShowers,
+	201: iface.CodeThunderyShowers,
+	210: iface.CodeThunderyShowers,
+	230: iface.CodeThunderyShowers,
+	231: iface.CodeThunderyShowers,
+	202: iface.CodeThunderyHeavyRain,
+	211: iface.CodeThunderyHeavyRain,
+	212: iface.CodeThunderyHeavyRain,
+	221: iface.CodeThunderyHeavyRain,
+	232: iface.CodeThunderyHeavyRain,
+	300: iface.CodeLightRain,
+	301: iface.CodeLightRain,
+	310: iface.CodeLightRain,
+	311: iface.CodeLightRain,
+	313: iface.CodeLightRain,
+	321: iface.CodeLightRain,
+	302: iface.CodeHeavyRain,
+	312: iface.CodeHeavyRain,
+	314: iface.CodeHeavyRain,
+	500: iface.CodeLightShowers,
+	501: iface.CodeLightShowers,
+	502: iface.CodeHeavyShowers,
+	503: iface.CodeHeavyShowers,
+	504: iface.CodeHeavyShowers,
+	511: iface.CodeLightSleet,
+	520: iface.CodeLightShowers,
+	521: iface.CodeLightShowers,
+	522: iface.CodeHeavyShowers,
+	531: iface.CodeHeavyShowers,
+	600: iface.CodeLightSnow,
+	601: iface.CodeLightSnow,
+	602: iface.CodeHeavySnow,
+	611: iface.CodeLightSleet,
+	612: iface.CodeLightSleetShowers,
+	615: iface.CodeLightSleet,
+	616: iface.CodeLightSleet,
+	620: iface.CodeLightSnowShowers,
+	621: iface.CodeLightSnowShowers,
+	622: iface.CodeHeavySnowShowers,
+	701: iface.CodeFog,
+	711: iface.CodeFog,
+	721: iface.CodeFog,
+	741: iface.CodeFog,
+	731: iface.CodeUnknown, // sand, dust whirls
+	751: iface.CodeUnknown, // sand
+	761: iface.CodeUnknown, // dust
+	762: iface.CodeUnknown, // volcanic ash
+	771: iface.CodeUnknown, // squalls
+	781: iface.CodeUnknown, // tornado
+	800: iface.CodeSunny,
+	801: iface.CodePartlyCloudy,
+	802: iface.CodeCloudy,
+	803: iface.CodeVeryCloudy,
+	804: iface.CodeVeryCloudy,
+	900: iface.CodeUnknown, // tornado
+	901: iface.CodeUnknown, // tropical storm
+	902: iface.CodeUnknown, // hurricane
+	903: iface.CodeUnknown, // cold
+	904: iface.CodeUnknown, // hot
+	905: iface.CodeUnknown, // windy
+	906: iface.CodeUnknown, // hail
+	951: iface.CodeUnknown, // calm
+	952: iface.CodeUnknown, // light breeze
+	953: iface.CodeUnknown, // gentle breeze
+	954: iface.CodeUnknown, // moderate breeze
+	955: iface.CodeUnknown, // fresh breeze
+	956: iface.CodeUnknown, // strong breeze
+	957: iface.CodeUnknown, // high wind, near gale
+	958: iface.CodeUnknown, // gale
+	959: iface.CodeUnknown, // severe gale
+	960: iface.CodeUnknown, // storm
+	961: iface.CodeUnknown, // violent storm
+	962: iface.CodeUnknown, // hurricane
+}
+
 func (c *openWeatherConfig) Setup() {
 	flag.StringVar(&c.apiKey, "owm-api-key", "", "openweathermap backend: the api `KEY` to use")
 	flag.StringVar(&c.lang, "owm-lang", "en", "openweathermap backend: the `LANGUAGE` to request from openweathermap")
 	flag.BoolVar(&c.debug, "owm-debug", false, "openweathermap backend: print raw requests and responses")
+	flag.BoolVar(&c.daily, "owm-daily", false, "openweathermap backend: add daily summaries for up to 16 days (needs a paid subscription)")
 }
 
-func (c *openWeatherConfig) fetch(url string) (*openWeatherResponse, error) {
-	res, err := http.Get(url)
+func (c *openWeatherConfig) Capabilities() iface.Capabilities {
+	maxDays := 5
+	if c.daily {
+		maxDays = 16
+	}
+	return iface.Capabilities{
+		Fields:          []string{"Desc", "TempC", "FeelsLikeC", "PrecipM", "WindspeedKmph", "WinddirDegree", "Humidity"},
+		MaxDays:         maxDays,
+		Resolution:      3 * time.Hour,
+		Region:          "worldwide",
+		KeyFlag:         "owm-api-key",
+		LocationFormats: []string{iface.LocationCoordinates, iface.LocationPostcode, iface.LocationName},
+		LanguageFlag:    "owm-lang",
+		Languages: []string{"af", "al", "ar", "az", "bg", "ca", "cz", "da", "de", "el", "en", "es", "eu", "fa", "fi", "fr", "gl", "he", "hi", "hr",
+			"hu", "id", "it", "ja", "kr", "la", "lt", "mk", "nl", "no", "pl", "pt", "pt_br", "ro", "ru", "se", "sk", "sl", "sp", "sq",
+			"sr", "sv", "th", "tr", "ua", "uk", "vi", "zh_cn", "zh_tw", "zu"},
+	}
+}
+
+// get requests url and unmarshals the response into resp. It returns the raw
+// response body for error messages.
+func (c *openWeatherConfig) get(url string, resp interface{}) ([]byte, error) {
+	res, err := iface.HTTPClient.Get(url)
 	if c.debug {
 		fmt.Printf("Fetching %s\n", url)
 	}
@@ -83,10 +205,30 @@ func (c *openWeatherConfig) fetch(url string) (*openWeatherResponse, error) {
 		fmt.Printf("Response (%s):\n%s\n", url, string(body))
 	}
 
-	var resp openWeatherResponse
-	if err = json.Unmarshal(body, &resp); err != nil {
+	if err = json.Unmarshal(body, resp); err != nil {
 		return nil, fmt.Errorf("Unable to unmarshal response (%s): %v\nThe json body is: %s", url, err, string(body))
 	}
+	return body, nil
+}
+
+func (c *openWeatherConfig) fetch(url string) (*openWeatherResponse, error) {
+	var resp openWeatherResponse
+	body, err := c.get(url, &resp)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Cod != "200" {
+		return nil, fmt.Errorf("Erroneous response body: %s", string(body))
+	}
+	return &resp, nil
+}
+
+func (c *openWeatherConfig) fetchDaily(url string) (*openWeatherDailyResponse, error) {
+	var resp openWeatherDailyResponse
+	body, err := c.get(url, &resp)
+	if err != nil {
+		return nil, err
+	}
 	if resp.Cod != "200" {
 		return nil, fmt.Errorf("Erroneous response body: %s", string(body))
 	}
@@ -124,117 +266,81 @@ func (c *openWeatherConfig) parseDaily(dataInfo []dataBlock, numdays int) []ifac
 	return forecast
 }
 
-func (c *openWeatherConfig) parseCond(dataInfo dataBlock) (iface.Cond, error) {
-	var ret iface.Cond
-	codemap := map[int]iface.WeatherCode{
-		200: iface.CodeThunderyShowers,
-		201: iface.CodeThunderyShowers,
-		210: iface.CodeThunderyShowers,
-		230: iface.CodeThunderyShowers,
-		231: iface.CodeThunderyShowers,
-		202: iface.CodeThunderyHeavyRain,
-		211: iface.CodeThunderyHeavyRain,
-		212: iface.CodeThunderyHeavyRain,
-		221: iface.CodeThunderyHeavyRain,
-		232: iface.CodeThunderyHeavyRain,
-		300: iface.CodeLightRain,
-		301: iface.CodeLightRain,
-		310: iface.CodeLightRain,
-		311: iface.CodeLightRain,
-		313: iface.CodeLightRain,
-		321: iface.CodeLightRain,
-		302: iface.CodeHeavyRain,
-		312: iface.CodeHeavyRain,
-		314: iface.CodeHeavyRain,
-		500: iface.CodeLightShowers,
-		501: iface.CodeLightShowers,
-		502: iface.CodeHeavyShowers,
-		503: iface.CodeHeavyShowers,
-		504: iface.CodeHeavyShowers,
-		511: iface.CodeLightSleet,
-		520: iface.CodeLightShowers,
-		521: iface.CodeLightShowers,
-		522: iface.CodeHeavyShowers,
-		531: iface.CodeHeavyShowers,
-		600: iface.CodeLightSnow,
-		601: iface.CodeLightSnow,
-		602: iface.CodeHeavySnow,
-		611: iface.CodeLightSleet,
-		612: iface.CodeLightSleetShowers,
-		615: iface.CodeLightSleet,
-		616: iface.CodeLightSleet,
-		620: iface.CodeLightSnowShowers,
-		621: iface.CodeLightSnowShowers,
-		622: iface.CodeHeavySnowShowers,
-		701: iface.CodeFog,
-		711: iface.CodeFog,
-		721: iface.CodeFog,
-		741: iface.CodeFog,
-		731: iface.CodeUnknown, // sand, dust whirls
-		751: iface.CodeUnknown, // sand
-		761: iface.CodeUnknown, // dust
-		762: iface.CodeUnknown, // volcanic ash
-		771: iface.CodeUnknown, // squalls
-		781: iface.CodeUnknown, // tornado
-		800: iface.CodeSunny,
-		801: iface.CodePartlyCloudy,
-		802: iface.CodeCloudy,
-		803: iface.CodeVeryCloudy,
-		804: iface.CodeVeryCloudy,
-		900: iface.CodeUnknown, // tornado
-		901: iface.CodeUnknown, // tropical storm
-		902: iface.CodeUnknown, // hurricane
-		903: iface.CodeUnknown, // cold
-		904: iface.CodeUnknown, // hot
-		905: iface.CodeUnknown, // windy
-		906: iface.CodeUnknown, // hail
-		951: iface.CodeUnknown, // calm
-		952: iface.CodeUnknown, // light breeze
-		953: iface.CodeUnknown, // gentle breeze
-		954: iface.CodeUnknown, // moderate breeze
-		955: iface.CodeUnknown, // fresh breeze
-		956: iface.CodeUnknown, // strong breeze
-		957: iface.CodeUnknown, // high wind, near gale
-		958: iface.CodeUnknown, // gale
-		959: iface.CodeUnknown, // severe gale
-		960: iface.CodeUnknown, // storm
-		961: iface.CodeUnknown, // violent storm
-		962: iface.CodeUnknown, // hurricane
+// addDaily sets the summaries of the days in forecast from the daily forecast
+// and appends the later days up to numdays without slots.
+func (c *openWeatherConfig) addDaily(forecast []iface.Day, daily *openWeatherDailyResponse, numdays int) []iface.Day {
+	for _, d := range daily.List {
+		low, high := d.Temp.Min, d.Temp.Max
+		precip := (d.Rain + d.Snow) / 1000
+		wind := d.Speed * 3.6
+		summary := &iface.Summary{
+			MinTempC:         &low,
+			MaxTempC:         &high,
+			PrecipM:          &precip,
+			MaxWindspeedKmph: &wind,
+		}
+		if d.Gust != nil {
+			gust := *d.Gust * 3.6
+			summary.MaxWindGustKmph = &gust
+		}
+		if d.Pop != nil {
+			pop := int(*d.Pop * 100)
+			summary.MaxChanceOfRainPercent = &pop
+		}
+		if len(d.Weather) > 0 {
+			summary.Code = openWeatherCodes[d.Weather[0].ID]
+		}
+
+		date := time.Unix(d.Dt, 0)
+		i := 0
+		for i < len(forecast) && forecast[i].Date.Format("2006-01-02") != date.Format("2006-01-02") {
+			i++
+		}
+		if i == len(forecast) {
+			if len(forecast) >= numdays {
+				break
+			}
+			forecast = append(forecast, iface.Day{Date: date})
+		}
+		forecast[i].Summary = summary
+		forecast[i].Astronomy.Sunrise = time.Unix(d.Sunrise, 0)
+		forecast[i].Astronomy.Sunset = time.Unix(d.Sunset, 0)
 	}
+	return forecast
+}
 
+func (c *openWeatherConfig) parseCond(dataInfo dataBlock) (iface.Cond, error) {
+	var ret iface.Cond
 	ret.Code = iface.CodeUnknown
 	ret.Desc = dataInfo.Weather[0].Description
 	ret.Humidity = &(dataInfo.Main.Humidity)
 	ret.TempC = &(dataInfo.Main.TempC)
 	ret.FeelsLikeC = &(dataInfo.Main.FeelsLikeC)
-	if &dataInfo.Wind.Deg != nil {
-		p := int(dataInfo.Wind.Deg)
+	if dataInfo.Wind.Deg != nil {
+		p := int(*dataInfo.Wind.Deg)
 		ret.WinddirDegree = &p
 	}
-	if &(dataInfo.Wind.Speed) != nil && (dataInfo.Wind.Speed) > 0 {
-		windSpeed := (dataInfo.Wind.Speed * 3.6)
-		ret.WindspeedKmph = &(windSpeed)
-	}
-	if val, ok := codemap[dataInfo.Weather[0].ID]; ok {
+	windSpeed := (dataInfo.Wind.Speed * 3.6)
+	ret.WindspeedKmph = &(windSpeed)
+	if val, ok := openWeatherCodes[dataInfo.Weather[0].ID]; ok {
 		ret.Code = val
 	}
 
-	if &dataInfo.Rain.MM3h != nil {
-		mmh := (dataInfo.Rain.MM3h / 1000) / 3
-		ret.PrecipM = &mmh
-	}
+	// the rain is only present if there is any
+	mmh := (dataInfo.Rain.MM3h / 1000) / 3
+	ret.PrecipM = &mmh
 
 	ret.Time = time.Unix(dataInfo.Dt, 0)
 
 	return ret, nil
 }
 
-func (c *openWeatherConfig) Fetch(location string, numdays int) iface.Data {
+func (c *openWeatherConfig) Fetch(location string, numdays int) (iface.Data, error) {
 	var ret iface.Data
 	loc := ""
 
 	if len(c.apiKey) == 0 {
-		log.Fatal("No openweathermap.org API key specified.\nYou have to register for one at https://home.openweathermap.org/users/sign_up")
+		return ret, fmt.Errorf("No openweathermap.org API key specified.\nYou have to register for one at https://home.openweathermap.org/users/sign_up")
 	}
 	if matched, err := regexp.MatchString(`^-?[0-9]*(\.[0-9]+)?,-?[0-9]*(\.[0-9]+)?$`, location); matched && err == nil {
 		s := strings.Split(location, ",")
@@ -247,17 +353,20 @@ func (c *openWeatherConfig) Fetch(location string, numdays int) iface.Data {
 
 	resp, err := c.fetch(fmt.Sprintf(openweatherURI, loc, c.apiKey, c.lang))
 	if err != nil {
-		log.Fatalf("Failed to fetch weather data: %v\n", err)
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+	if len(resp.List) == 0 {
+		return ret, fmt.Errorf("Failed to fetch weather data: the response has no forecast")
 	}
 	ret.Current, err = c.parseCond(resp.List[0])
 	ret.Location = fmt.Sprintf("%s, %s", resp.City.Name, resp.City.Country)
 
 	if err != nil {
-		log.Fatalf("Failed to fetch weather data: %v\n", err)
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
 	}
 
 	if numdays == 0 {
-		return ret
+		return ret, nil
 	}
 	ret.Forecast = c.parseDaily(resp.List, numdays)
 
@@ -268,7 +377,15 @@ func (c *openWeatherConfig) Fetch(location string, numdays int) iface.Data {
 		ret.Forecast[0].Astronomy.Sunset = time.Unix(resp.City.SunSet, 0)
 	}
 
-	return ret
+	if c.daily {
+		daily, err := c.fetchDaily(fmt.Sprintf(openweatherDailyURI, loc, c.apiKey, c.lang, numdays))
+		if err != nil {
+			return ret, fmt.Errorf("Failed to fetch daily weather data: %v", err)
+		}
+		ret.Forecast = c.addDaily(ret.Forecast, daily, numdays)
+	}
+
+	return ret, nil
 }
 
 func init() {
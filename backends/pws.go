@@ -0,0 +1,351 @@
+package backends
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// pwsConfig reads the current conditions from a personal weather station in
+// the local network and the forecast from another backend.
+type pwsConfig struct {
+	url      string
+	kind     string
+	listen   string
+	timeout  time.Duration
+	forecast string
+	debug    bool
+}
+
+// pwsEcowittResponse is the live data of an Ecowitt gateway. The values are
+// strings which may contain their unit.
+type pwsEcowittResponse struct {
+	CommonList []pwsEcowittValue `json:"common_list"`
+	Rain       []pwsEcowittValue `json:"rain"`
+}
+
+type pwsEcowittValue struct {
+	ID   string `json:"id"`
+	Val  string `json:"val"`
+	Unit string `json:"unit"`
+}
+
+// pwsWeewxResponse is a current.json file generated by a WeeWX template, with
+// the observation types of WeeWX as keys.
+type pwsWeewxResponse struct {
+	Current map[string]struct {
+		Value *float64 `json:"value"`
+		Units string   `json:"units"`
+	} `json:"current"`
+}
+
+func (c *pwsConfig) Setup() {
+	flag.StringVar(&c.url, "pws-url", "", "pws backend: `URL` of the live data of the station, like http://192.168.1.10/get_livedata_info of an Ecowitt gateway or the current.json of WeeWX")
+	flag.StringVar(&c.kind, "pws-type", "ecowitt", "pws backend: `TYPE` of the station, one of ecowitt, weewx and push")
+	flag.StringVar(&c.listen, "pws-listen", ":8125", "pws backend: `ADDRESS` to receive the pushes of Ecowitt or Ambient stations on with -pws-type push")
+	flag.DurationVar(&c.timeout, "pws-timeout", 2*time.Minute, "pws backend: `DURATION` to wait for a push of the station")
+	flag.StringVar(&c.forecast, "pws-forecast", "openmeteo", "pws backend: `BACKEND` providing the forecast")
+	flag.BoolVar(&c.debug, "pws-debug", false, "pws backend: print raw requests and responses")
+}
+
+// Capabilities are those of the forecast backend, as the station only adds the
+// current conditions.
+func (c *pwsConfig) Capabilities() iface.Capabilities {
+	if be, ok := iface.AllBackends[c.forecast].(iface.CapableBackend); ok && be != iface.Backend(c) {
+		return be.Capabilities()
+	}
+	return iface.Capabilities{
+		Region:          "the location of the station",
+		LocationFormats: []string{iface.LocationCoordinates, iface.LocationPostcode, iface.LocationName},
+	}
+}
+
+func (c *pwsConfig) get() ([]byte, error) {
+	if c.url == "" {
+		return nil, fmt.Errorf("Set the address of your station with -pws-url")
+	}
+	if c.debug {
+		log.Printf("pws request %s\n", c.url)
+	}
+	resp, err := iface.HTTPClient.Get(c.url)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to get (%s): %v", c.url, err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read response body (%s): %v", c.url, err)
+	}
+	if c.debug {
+		log.Printf("pws response\n%s\n", body)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Erroneous response (%s): %s", c.url, resp.Status)
+	}
+	return body, nil
+}
+
+func (c *pwsConfig) fetchEcowitt() (ret iface.Cond, err error) {
+	body, err := c.get()
+	if err != nil {
+		return ret, err
+	}
+	var resp pwsEcowittResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ret, fmt.Errorf("Unable to parse response (%s): %v", c.url, err)
+	}
+
+	ret.Time = time.Now()
+	for _, v := range append(resp.CommonList, resp.Rain...) {
+		switch v.ID {
+		case "0x02":
+			ret.TempC = c.convert(v.Val, v.Unit)
+		case "3":
+			ret.FeelsLikeC = c.convert(v.Val, v.Unit)
+		case "0x03":
+			ret.DewPointC = c.convert(v.Val, v.Unit)
+		case "0x07":
+			ret.Humidity = pwsInt(c.convert(v.Val, v.Unit))
+		case "0x0A":
+			ret.WinddirDegree = pwsInt(c.convert(v.Val, v.Unit))
+		case "0x0B":
+			ret.WindspeedKmph = c.convert(v.Val, v.Unit)
+		case "0x0C":
+			ret.WindGustKmph = c.convert(v.Val, v.Unit)
+		case "0x0E":
+			ret.PrecipM = c.convert(v.Val, v.Unit)
+		}
+	}
+	return ret, nil
+}
+
+func (c *pwsConfig) fetchWeewx() (ret iface.Cond, err error) {
+	body, err := c.get()
+	if err != nil {
+		return ret, err
+	}
+	var resp pwsWeewxResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ret, fmt.Errorf("Unable to parse response (%s): %v", c.url, err)
+	}
+
+	value := func(name string) *float32 {
+		v, ok := resp.Current[name]
+		if !ok || v.Value == nil {
+			return nil
+		}
+		return c.convert(strconv.FormatFloat(*v.Value, 'f', -1, 64), v.Units)
+	}
+	ret.Time = time.Now()
+	if t := resp.Current["dateTime"].Value; t != nil {
+		ret.Time = time.Unix(int64(*t), 0)
+	}
+	ret.TempC = value("outTemp")
+	ret.FeelsLikeC = value("appTemp")
+	ret.DewPointC = value("dewpoint")
+	ret.Humidity = pwsInt(value("outHumidity"))
+	ret.WinddirDegree = pwsInt(value("windDir"))
+	ret.WindspeedKmph = value("windSpeed")
+	ret.WindGustKmph = value("windGust")
+	ret.PrecipM = value("rainRate")
+	return ret, nil
+}
+
+// pwsPushCond converts the fields pushed by Ecowitt and Ambient stations,
+// which always use imperial units.
+func pwsPushCond(form url.Values) (ret iface.Cond) {
+	value := func(name, unit string) *float32 {
+		if !form.Has(name) {
+			return nil
+		}
+		// the units are fixed, so there are no conversion errors
+		v, _ := pwsConvert(form.Get(name), unit)
+		return v
+	}
+	ret.Time = time.Now()
+	if t, err := time.Parse("2006-01-02 15:04:05", form.Get("dateutc")); err == nil {
+		ret.Time = t
+	}
+	ret.TempC = value("tempf", "F")
+	ret.FeelsLikeC = value("feelsLike", "F")
+	ret.DewPointC = value("dewPoint", "F")
+	ret.Humidity = pwsInt(value("humidity", "%"))
+	ret.WinddirDegree = pwsInt(value("winddir", ""))
+	ret.WindspeedKmph = value("windspeedmph", "mph")
+	ret.WindGustKmph = value("windgustmph", "mph")
+	ret.PrecipM = value("rainratein", "in/h")
+	if ret.PrecipM == nil {
+		ret.PrecipM = value("hourlyrainin", "in/h")
+	}
+	return ret
+}
+
+// receive waits on ln for the next push of the station. Ecowitt stations post
+// a form, Ambient stations send the same fields in the query.
+func (c *pwsConfig) receive(ln net.Listener) (iface.Cond, error) {
+	conds := make(chan iface.Cond, 1)
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || !r.Form.Has("tempf") {
+			http.Error(w, "no weather data", http.StatusBadRequest)
+			return
+		}
+		if c.debug {
+			log.Printf("pws push %s\n", r.Form.Encode())
+		}
+		select {
+		case conds <- pwsPushCond(r.Form):
+		default:
+		}
+		io.WriteString(w, "OK")
+	})}
+	go server.Serve(ln)
+	defer server.Shutdown(context.Background())
+
+	select {
+	case cond := <-conds:
+		return cond, nil
+	case <-time.After(c.timeout):
+		return iface.Cond{}, fmt.Errorf("The station did not push its data to %s within %v", ln.Addr(), c.timeout)
+	}
+}
+
+// pwsConvert parses a value which may be followed by its unit, like "11.2
+// km/h", and converts it to the unit of the matching iface.Cond field. The
+// unit argument is used if the value has none. Values which are not numbers
+// are nil, values in units it can not convert are nil with an error.
+func pwsConvert(val, unit string) (*float32, error) {
+	val = strings.TrimSpace(val)
+	end := len(val)
+	for i, r := range val {
+		if !strings.ContainsRune("+-.0123456789", r) {
+			end = i
+			break
+		}
+	}
+	v, err := strconv.ParseFloat(val[:end], 32)
+	if err != nil {
+		return nil, nil
+	}
+	if u := strings.TrimSpace(val[end:]); u != "" {
+		unit = u
+	}
+
+	ret := float32(v)
+	switch strings.ToLower(unit) {
+	case "", "c", "°c", "℃", "%", "°", "km/h":
+	case "f", "°f", "℉":
+		ret = (ret - 32) / 1.8
+	case "m/s":
+		ret *= 3.6
+	case "mph":
+		ret *= 1.609
+	case "knots", "kn", "knot":
+		ret *= 1.852
+	case "mm/hr", "mm/h", "mm":
+		ret /= 1000
+	case "cm/hr", "cm/h", "cm":
+		ret /= 100
+	case "in/hr", "in/h", "in":
+		ret *= 0.0254
+	default:
+		return nil, fmt.Errorf("Unable to convert %q, the unit %q is unknown", val, unit)
+	}
+	return &ret, nil
+}
+
+// convert is pwsConvert, logging values in unknown units in debug mode.
+func (c *pwsConfig) convert(val, unit string) *float32 {
+	v, err := pwsConvert(val, unit)
+	if err != nil && c.debug {
+		log.Printf("pws: %v\n", err)
+	}
+	return v
+}
+
+func pwsInt(v *float32) *int {
+	if v == nil {
+		return nil
+	}
+	i := int(*v + 0.5)
+	return &i
+}
+
+// pwsMerge returns the conditions measured by the station, completed with
+// the weather code, description and the values a station can not measure
+// from the forecast backend.
+func pwsMerge(provider, station iface.Cond) iface.Cond {
+	provider.Time = station.Time
+	if station.TempC != nil {
+		// felt and further temperatures of the provider do not match the
+		// measured temperature, missing ones are derived from it later
+		provider.TempC = station.TempC
+		provider.FeelsLikeC = station.FeelsLikeC
+		provider.DewPointC = station.DewPointC
+		provider.HeatIndexC, provider.HumidexC, provider.WetBulbC = nil, nil, nil
+	}
+	if station.PrecipM != nil {
+		provider.PrecipM = station.PrecipM
+	}
+	if station.WindspeedKmph != nil {
+		provider.WindspeedKmph = station.WindspeedKmph
+	}
+	if station.WindGustKmph != nil {
+		provider.WindGustKmph = station.WindGustKmph
+	}
+	if station.WinddirDegree != nil {
+		provider.WinddirDegree = station.WinddirDegree
+	}
+	if station.Humidity != nil {
+		provider.Humidity = station.Humidity
+	}
+	provider.Derived = nil
+	return provider
+}
+
+func (c *pwsConfig) Fetch(location string, numdays int) (iface.Data, error) {
+	be, ok := iface.AllBackends[c.forecast]
+	if !ok || be == iface.Backend(c) {
+		return iface.Data{}, fmt.Errorf("The pws backend needs another backend for the forecast, choose one with -pws-forecast instead of \"%s\".", c.forecast)
+	}
+
+	var station iface.Cond
+	var err error
+	switch c.kind {
+	case "ecowitt":
+		station, err = c.fetchEcowitt()
+	case "weewx":
+		station, err = c.fetchWeewx()
+	case "push":
+		var ln net.Listener
+		if ln, err = net.Listen("tcp", c.listen); err == nil {
+			station, err = c.receive(ln)
+		}
+	default:
+		err = fmt.Errorf("Unknown station type \"%s\", use one of ecowitt, weewx and push", c.kind)
+	}
+	if err != nil {
+		return iface.Data{}, fmt.Errorf("Failed to get the data of the station: %v", err)
+	}
+
+	ret, err := be.Fetch(location, numdays)
+	if err != nil {
+		return ret, err
+	}
+	ret.Current = pwsMerge(ret.Current, station)
+	return ret, nil
+}
+
+func init() {
+	iface.AllBackends["pws"] = &pwsConfig{}
+}
@@ -0,0 +1,103 @@
+package backends
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// near reports whether p is set and close to want.
+func near(p *float32, want float32) bool {
+	return p != nil && *p > want-0.01 && *p < want+0.01
+}
+
+func TestPwsWeewx(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"generation": {"generator": "WeeWX 5.1"}, "current": {
+			"dateTime": {"value": 1720951200, "units": "unix epoch time"},
+			"outTemp": {"value": 70.3, "units": "°F"},
+			"outHumidity": {"value": 61, "units": "%"},
+			"windSpeed": {"value": 3.1, "units": "m/s"},
+			"windGust": {"value": null, "units": "m/s"},
+			"windDir": {"value": 228, "units": "°"},
+			"rainRate": {"value": 1.2, "units": "mm/h"}}}`)
+	}))
+	defer server.Close()
+
+	c := &pwsConfig{url: server.URL + "/current.json"}
+	cond, err := c.fetchWeewx()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cond.Time.Equal(time.Unix(1720951200, 0)) || !near(cond.TempC, 21.28) || *cond.Humidity != 61 ||
+		!near(cond.WindspeedKmph, 11.16) || cond.WindGustKmph != nil || *cond.WinddirDegree != 228 || !near(cond.PrecipM, 0.0012) {
+		t.Errorf("got %+v", cond)
+	}
+}
+
+func TestPwsConvert(t *testing.T) {
+	f := func(v float32) *float32 { return &v }
+	for _, tt := range []struct {
+		val, unit string
+		want      *float32
+		err       bool
+	}{
+		{"21.3", "C", f(21.3), false},
+		{"70.3 °F", "C", f(21.28), false},
+		{"61%", "", f(61), false},
+		{"12", "cm/h", f(0.12), false},
+		{"12 mm/Hr", "", f(0.012), false},
+		{"--", "C", nil, false},
+		{"3 bft", "km/h", nil, true},
+		{"1013 hPa", "", nil, true},
+	} {
+		got, err := pwsConvert(tt.val, tt.unit)
+		if (got == nil) != (tt.want == nil) || (got != nil && !near(got, *tt.want)) || (err != nil) != tt.err {
+			t.Errorf("pwsConvert(%q, %q) = %v, %v, want %v", tt.val, tt.unit, got, err, tt.want)
+		}
+	}
+}
+
+func TestPwsPush(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &pwsConfig{timeout: 10 * time.Second}
+	go func() {
+		form := url.Values{"PASSKEY": {"123"}, "dateutc": {"2024-07-14 10:00:00"}, "tempf": {"50"}, "humidity": {"80"},
+			"windspeedmph": {"10"}, "winddir": {"90"}, "rainratein": {"0.1"}}
+		resp, err := http.Post("http://"+ln.Addr().String()+"/data/report/", "application/x-www-form-urlencoded", strings.NewReader(form.Encode()))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+
+	cond, err := c.receive(ln)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cond.Time.Equal(time.Date(2024, 7, 14, 10, 0, 0, 0, time.UTC)) || !near(cond.TempC, 10) || *cond.Humidity != 80 ||
+		!near(cond.WindspeedKmph, 16.09) || *cond.WinddirDegree != 90 || !near(cond.PrecipM, 0.00254) {
+		t.Errorf("got %+v", cond)
+	}
+}
+
+func TestPwsMerge(t *testing.T) {
+	v := func(f float32) *float32 { return &f }
+	provider := iface.Cond{Code: iface.CodeSunny, Desc: "Clear sky", TempC: v(25), FeelsLikeC: v(27), VisibleDistM: v(10000), WindspeedKmph: v(20)}
+	station := iface.Cond{Time: time.Unix(1720951200, 0), TempC: v(21)}
+
+	got := pwsMerge(provider, station)
+	if got.Code != iface.CodeSunny || got.Desc != "Clear sky" || !got.Time.Equal(station.Time) || *got.TempC != 21 ||
+		got.FeelsLikeC != nil || *got.VisibleDistM != 10000 || *got.WindspeedKmph != 20 {
+		t.Errorf("got %+v", got)
+	}
+}
@@ -5,8 +5,6 @@ import (
 	"fmt"
 	"github.com/schachmat/wego/iface"
 	"io"
-	"log"
-	"net/http"
 	"regexp"
 	"strings"
 	"time"
@@ -85,7 +83,7 @@ func (c *smhiConfig) Setup() {
 }
 
 func (c *smhiConfig) fetch(url string) (*smhiResponse, error) {
-	resp, err := http.Get(url)
+	resp, err := iface.HTTPClient.Get(url)
 	if err != nil {
 		return nil, fmt.Errorf("Unable to get (%s): %v", url, err)
 	} else if resp.StatusCode != 200 {
@@ -112,9 +110,19 @@ func (c *smhiConfig) fetch(url string) (*smhiResponse, error) {
 
 }
 
-func (c *smhiConfig) Fetch(location string, numDays int) (ret iface.Data) {
+func (c *smhiConfig) Capabilities() iface.Capabilities {
+	return iface.Capabilities{
+		Fields:          []string{"Desc", "TempC", "PrecipM", "VisibleDistM", "WindspeedKmph", "WindGustKmph", "WinddirDegree", "Humidity"},
+		MaxDays:         10,
+		Resolution:      time.Hour,
+		Region:          "the nordic countries",
+		LocationFormats: []string{iface.LocationCoordinates},
+	}
+}
+
+func (c *smhiConfig) Fetch(location string, numDays int) (ret iface.Data, err error) {
 	if matched, err := regexp.MatchString(`^-?[0-9]*(\.[0-9]+)?,-?[0-9]*(\.[0-9]+)?$`, location); !matched || err != nil {
-		log.Fatalf("Error: The smhi backend only supports latitude,longitude pairs as location.\nInstead of `%s` try `59.329,18.068` for example to get a forecast for Stockholm.", location)
+		return ret, fmt.Errorf("The smhi backend only supports latitude,longitude pairs as location.\nInstead of `%s` try `59.329,18.068` for example to get a forecast for Stockholm.", location)
 	}
 
 	s := strings.Split(location, ",")
@@ -122,72 +130,81 @@ func (c *smhiConfig) Fetch(location string, numDays int) (ret iface.Data) {
 
 	resp, err := c.fetch(requestUrl)
 	if err != nil {
-		log.Fatalf("Failed to fetch weather data: %v\n", err)
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
 	}
 
-	ret.Current = c.parseCurrent(resp)
-	ret.Forecast = c.parseForecast(resp, numDays)
-	coordinates := resp.Geometry.Coordinates
-	ret.GeoLoc = &iface.LatLon{Latitude: coordinates[0][1], Longitude: coordinates[0][0]}
+	if ret.Current, err = c.parseCurrent(resp); err != nil {
+		return ret, err
+	}
+	if ret.Forecast, err = c.parseForecast(resp, numDays); err != nil {
+		return ret, err
+	}
+	if coordinates := resp.Geometry.Coordinates; len(coordinates) > 0 && len(coordinates[0]) == 2 {
+		ret.GeoLoc = &iface.LatLon{Latitude: coordinates[0][1], Longitude: coordinates[0][0]}
+	}
 	ret.Location = location + " (Forecast provided by SMHI)"
-	return ret
+	return ret, nil
 }
-func (c *smhiConfig) parseForecast(response *smhiResponse, numDays int) (days []iface.Day) {
+func (c *smhiConfig) parseForecast(response *smhiResponse, numDays int) (days []iface.Day, err error) {
 	if numDays > 10 {
 		numDays = 10
 	}
 
-	var currentTime time.Time = time.Now()
-	var dayCount = 0
-
-	var day iface.Day
-	day.Date = time.Now()
+	var day *iface.Day
 	for _, prediction := range response.TimeSeries {
-		if dayCount == numDays {
-			break
-		}
-
 		ts, err := time.Parse(time.RFC3339, prediction.ValidTime)
 		if err != nil {
-			log.Fatalf("Failed to parse timestamp: %v\n", err)
+			return nil, fmt.Errorf("Failed to parse timestamp: %v", err)
 		}
 
-		if ts.Day() != currentTime.Day() {
-			dayCount += 1
-			currentTime = ts
-			days = append(days, day)
-			day = iface.Day{Date: ts}
+		if day == nil || ts.Day() != day.Date.Day() {
+			if day != nil {
+				days = append(days, *day)
+			}
+			if len(days) == numDays {
+				return days, nil
+			}
+			day = &iface.Day{Date: ts}
 		}
-		day.Slots = append(day.Slots, c.parsePrediction(prediction))
+		slot, err := c.parsePrediction(prediction)
+		if err != nil {
+			return nil, err
+		}
+		day.Slots = append(day.Slots, slot)
+	}
+	if day != nil {
+		days = append(days, *day)
 	}
 
-	return days
+	return days, nil
 }
 
-func (c *smhiConfig) parseCurrent(forecast *smhiResponse) (cnd iface.Cond) {
-	if len(forecast.TimeSeries) < 0 {
-		log.Fatalln("Failed to fetch weather data: No Forecast in response")
+func (c *smhiConfig) parseCurrent(forecast *smhiResponse) (cnd iface.Cond, err error) {
+	if len(forecast.TimeSeries) == 0 {
+		return cnd, fmt.Errorf("Failed to fetch weather data: No Forecast in response")
 	}
 	var currentPrediction *smhiTimeSeries = forecast.TimeSeries[0]
 	var currentTime time.Time = time.Now().UTC()
 
+	// use the latest prediction which is not in the future
 	for _, prediction := range forecast.TimeSeries {
 		ts, err := time.Parse(time.RFC3339, prediction.ValidTime)
 		if err != nil {
-			log.Fatalf("Failed to parse timestamp: %v\n", err)
+			return cnd, fmt.Errorf("Failed to parse timestamp: %v", err)
 		}
 
 		if ts.After(currentTime) {
 			break
 		}
+		currentPrediction = prediction
 	}
 	return c.parsePrediction(currentPrediction)
 }
 
-func (c *smhiConfig) parsePrediction(prediction *smhiTimeSeries) (cnd iface.Cond) {
+func (c *smhiConfig) parsePrediction(prediction *smhiTimeSeries) (cnd iface.Cond, err error) {
 	ts, err := time.Parse(time.RFC3339, prediction.ValidTime)
 	if err != nil {
-		log.Fatalf("Failed to parse timestamp: %v\n", err)
+		return cnd, fmt.Errorf("Failed to parse timestamp: %v", err)
 	}
 	cnd.Time = ts
 
@@ -223,7 +240,7 @@ func (c *smhiConfig) parsePrediction(prediction *smhiTimeSeries) (cnd iface.Cond
 		}
 	}
 
-	return cnd
+	return cnd, nil
 }
 
 func init() {
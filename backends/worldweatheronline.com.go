@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"log"
-	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
@@ -41,8 +41,11 @@ type wwoDay struct {
 		Sunrise  string
 		Sunset   string
 	}
-	Date   string
-	Hourly []wwoCond
+	Date     string
+	Hourly   []wwoCond
+	MaxtempC *float32 `json:"maxtempC,string"`
+	MintempC *float32 `json:"mintempC,string"`
+	SunHour  *float32 `json:"sunHour,string"`
 }
 
 type wwoResponse struct {
@@ -182,6 +185,10 @@ func wwoParseDay(day wwoDay, index int) (ret iface.Day) {
 		ret.Date = date
 	}
 
+	if day.MaxtempC != nil || day.MintempC != nil || day.SunHour != nil {
+		ret.Summary = &iface.Summary{MinTempC: day.MintempC, MaxTempC: day.MaxtempC, SunshineHours: day.SunHour}
+	}
+
 	if day.Hourly != nil && len(day.Hourly) > 0 {
 		for _, slot := range day.Hourly {
 			ret.Slots = append(ret.Slots, wwoParseCond(slot, date))
@@ -253,10 +260,24 @@ func (c *wwoConfig) Setup() {
 	flag.BoolVar(&c.debug, "wwo-debug", false, "worldweatheronline backend: print raw requests and responses")
 }
 
+func (c *wwoConfig) Capabilities() iface.Capabilities {
+	return iface.Capabilities{
+		Fields:          []string{"Desc", "TempC", "FeelsLikeC", "ChanceOfRainPercent", "PrecipM", "VisibleDistM", "WindspeedKmph", "WindGustKmph", "WinddirDegree"},
+		MaxDays:         14,
+		Resolution:      3 * time.Hour,
+		Region:          "worldwide",
+		KeyFlag:         "wwo-api-key",
+		LocationFormats: []string{iface.LocationCoordinates, iface.LocationPostcode, iface.LocationName},
+		LanguageFlag:    "wwo-lang",
+		Languages: []string{"ar", "bg", "bn", "cs", "da", "de", "el", "en", "es", "fi", "fr", "hi", "hu", "it", "ja", "jv", "ko", "mr", "nl", "pa",
+			"pl", "pt", "ro", "ru", "si", "sk", "sr", "sv", "ta", "te", "tr", "uk", "ur", "vi", "zh", "zh_cmn", "zh_hsn", "zh_tw", "zh_wuu", "zh_yue", "zu"},
+	}
+}
+
 func (c *wwoConfig) getCoordinatesFromAPI(queryParams []string, res chan *iface.LatLon) {
 	var coordResp wwoCoordinateResp
 	requri := wwoSuri + strings.Join(queryParams, "&")
-	hres, err := http.Get(requri)
+	hres, err := iface.HTTPClient.Get(requri)
 	if err != nil {
 		log.Println("Unable to fetch geo location:", err)
 		res <- nil
@@ -296,14 +317,15 @@ func (c *wwoConfig) getCoordinatesFromAPI(queryParams []string, res chan *iface.
 	res <- &iface.LatLon{Latitude: *r[0].Latitude, Longitude: *r[0].Longitude}
 }
 
-func (c *wwoConfig) Fetch(loc string, numdays int) iface.Data {
+func (c *wwoConfig) Fetch(loc string, numdays int) (iface.Data, error) {
 	var params []string
 	var resp wwoResponse
 	var ret iface.Data
-	coordChan := make(chan *iface.LatLon)
+	// buffered, so the geo location request finishes when Fetch fails early
+	coordChan := make(chan *iface.LatLon, 1)
 
 	if len(c.apiKey) == 0 {
-		log.Fatal("No API key specified. Setup instructions are in the README.")
+		return ret, fmt.Errorf("No API key specified. Setup instructions are in the README.")
 	}
 	params = append(params, "key="+c.apiKey)
 
@@ -321,17 +343,18 @@ func (c *wwoConfig) Fetch(loc string, numdays int) iface.Data {
 	}
 	requri := wwoWuri + strings.Join(params, "&")
 
-	res, err := http.Get(requri)
+	res, err := iface.HTTPClient.Get(requri)
 	if err != nil {
-		log.Fatal("Unable to get weather data: ", err)
-	} else if res.StatusCode != 200 {
-		log.Fatal("Unable to get weather data: http status ", res.StatusCode)
+		return ret, fmt.Errorf("Unable to get weather data: %v", err)
 	}
 	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		return ret, fmt.Errorf("Unable to get weather data: http status %d", res.StatusCode)
+	}
 
 	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		log.Fatal(err)
+		return ret, err
 	}
 
 	if c.debug {
@@ -351,9 +374,9 @@ func (c *wwoConfig) Fetch(loc string, numdays int) iface.Data {
 
 	if resp.Data.Req == nil || len(resp.Data.Req) < 1 {
 		if resp.Data.Err != nil && len(resp.Data.Err) >= 1 {
-			log.Fatal(resp.Data.Err[0].Msg)
+			return ret, fmt.Errorf("%s", resp.Data.Err[0].Msg)
 		}
-		log.Fatal("Malformed response.")
+		return ret, fmt.Errorf("Malformed response.")
 	}
 
 	ret.Location = resp.Data.Req[0].Type + ": " + resp.Data.Req[0].Query
@@ -365,11 +388,14 @@ func (c *wwoConfig) Fetch(loc string, numdays int) iface.Data {
 
 	if resp.Data.Days != nil && numdays > 0 {
 		for i, day := range resp.Data.Days {
+			if i == numdays {
+				break
+			}
 			ret.Forecast = append(ret.Forecast, wwoParseDay(day, i))
 		}
 	}
 
-	return ret
+	return ret, nil
 }
 
 func init() {
@@ -2,6 +2,7 @@ package backends
 
 import (
 	"encoding/json"
+	"flag"
 	"fmt"
 	"github.com/schachmat/wego/iface"
 	"io"
@@ -13,9 +14,10 @@ import (
 )
 
 type yrConfig struct {
-	apiKey string
-	lang   string
-	debug  bool
+	apiKey  string
+	lang    string
+	debug   bool
+	nowcast bool
 }
 
 type yrResponse struct {
@@ -30,6 +32,8 @@ type yrResponse struct {
 			Units     struct {
 				AirTemperature string `json:"air_temperature"`
 			} `json:"units"`
+			// RadarCoverage is only set by the nowcast
+			RadarCoverage string `json:"radar_coverage"`
 		} `json:"meta"`
 		TimeSeries []timeSeriesBlock `json:"timeseries"`
 	} `json:"properties"`
@@ -46,6 +50,8 @@ type timeSeriesBlock struct {
 				RelativeHumidity      float32 `json:"relative_humidity"`
 				WindFromDirection     float32 `json:"wind_from_direction"`
 				WindSpeed             float32 `json:"wind_speed"`
+				// PrecipitationRate is only set by the nowcast
+				PrecipitationRate *float32 `json:"precipitation_rate"`
 			} `json:"details"`
 		} `json:"instant"`
 		Next12Hours struct {
@@ -165,15 +171,55 @@ type moonResponse struct {
 }
 
 const (
-	yrURI       = "https://api.met.no/weatherapi/locationforecast/2.0/compact?"
-	geonamesURI = "http://api.geonames.org/searchJSON?"
-	sunURI      = "https://api.met.no/weatherapi/sunrise/3.0/"
+	yrURI        = "https://api.met.no/weatherapi/locationforecast/2.0/compact?"
+	yrNowcastURI = "https://api.met.no/weatherapi/nowcast/2.0/complete?"
+	geonamesURI  = "http://api.geonames.org/searchJSON?"
+	sunURI       = "https://api.met.no/weatherapi/sunrise/3.0/"
 )
 
 func (c *yrConfig) Setup() {
 	//flag.StringVar(&c.apiKey, "wwo-api-key", "", "worldweatheronline backend: the api `KEY` to use")
 	//flag.StringVar(&c.language, "wwo-lang", "en", "worldweatheronline backend: the `LANGUAGE` to request from worldweatheronline")
 	//flag.BoolVar(&c.debug, "wwo-debug", false, "worldweatheronline backend: print raw requests and responses")
+	flag.BoolVar(&c.nowcast, "yr-nowcast", false, "yr backend: also request the precipitation nowcast, the nowcast command always does")
+}
+
+func (c *yrConfig) Capabilities() iface.Capabilities {
+	return iface.Capabilities{
+		Fields:          []string{"TempC", "PrecipM", "WindspeedKmph", "WinddirDegree", "Humidity"},
+		MaxDays:         9,
+		Resolution:      time.Hour,
+		Region:          "worldwide",
+		LocationFormats: []string{iface.LocationCoordinates, iface.LocationName},
+		Nowcast:         true,
+		NowcastFlag:     "yr-nowcast",
+	}
+}
+
+// nowcastParser returns the precipitation nowcast, or nil if the location is
+// not covered by the radar.
+func (c *yrConfig) nowcastParser(resp *yrResponse) *iface.Nowcast {
+	if resp.Properties.Meta.RadarCoverage != "ok" {
+		return nil
+	}
+	var ret *iface.Nowcast
+	for i, block := range resp.Properties.TimeSeries {
+		t, err := time.Parse(time.RFC3339, block.Time)
+		rate := block.Data.Instant.Details.PrecipitationRate
+		if err != nil || rate == nil {
+			break
+		}
+		if ret == nil {
+			ret = &iface.Nowcast{Start: t}
+		} else if i == 1 {
+			ret.Step = t.Sub(ret.Start)
+		}
+		ret.PrecipM = append(ret.PrecipM, *rate/1000)
+	}
+	if ret == nil || ret.Step <= 0 {
+		return nil
+	}
+	return ret
 }
 
 func (c *yrConfig) conditionParser(dayInfo timeSeriesBlock) (iface.Cond, error) {
@@ -252,12 +298,15 @@ func (c *yrConfig) conditionParser(dayInfo timeSeriesBlock) (iface.Cond, error)
 	temp := dayInfo.Data.Instant.Details.AirTemperature
 	ret.TempC = &temp
 
-	if &dayInfo.Data.Next6Hours.Details.PrecipitationAmount != nil {
-		mmh := dayInfo.Data.Next6Hours.Details.PrecipitationAmount / 1000
-		ret.PrecipM = &mmh
+	// the amounts are in mm for the next hour, or for the next six hours in
+	// the later part of the forecast
+	mmh := dayInfo.Data.Next6Hours.Details.PrecipitationAmount / 6 / 1000
+	if dayInfo.Data.Next1Hours.Summary.SymbolCode != "" {
+		mmh = dayInfo.Data.Next1Hours.Details.PrecipitationAmount / 1000
 	}
+	ret.PrecipM = &mmh
 
-	WindKmph := dayInfo.Data.Instant.Details.WindSpeed / 3.6
+	WindKmph := dayInfo.Data.Instant.Details.WindSpeed * 3.6 // convert m/s to km/h
 	ret.WindspeedKmph = &WindKmph
 
 	WindDeg := int(dayInfo.Data.Instant.Details.WindFromDirection)
@@ -310,7 +359,7 @@ func (c *yrConfig) dayParser(series []timeSeriesBlock, numDays int, coordinates
 
 func (c *yrConfig) geonameParser(url string) (geoName string, geoCoordinates string, err error) {
 	//Create a new HTTP client
-	client := &http.Client{}
+	client := iface.HTTPClient
 
 	// Create a new HTTP GET request
 	req, err := http.NewRequest("GET", url, nil)
@@ -356,7 +405,7 @@ func (c *yrConfig) geonameParser(url string) (geoName string, geoCoordinates str
 func (c *yrConfig) moonParser(url string, coord string, day string) (sunRise time.Time, sunSet time.Time, err error) {
 	// Sun
 	//Create a new HTTP client
-	client := &http.Client{}
+	client := iface.HTTPClient
 
 	var emptyTime time.Time
 
@@ -408,7 +457,7 @@ func (c *yrConfig) moonParser(url string, coord string, day string) (sunRise tim
 func (c *yrConfig) sunParser(url string, coord string, day string) (sunRise time.Time, sunSet time.Time, err error) {
 	// Sun
 	//Create a new HTTP client
-	client := &http.Client{}
+	client := iface.HTTPClient
 
 	var emptyTime time.Time
 
@@ -464,7 +513,7 @@ func (c *yrConfig) fetch(url string) (*yrResponse, error) {
 	}
 
 	// Create a new HTTP client
-	client := &http.Client{}
+	client := iface.HTTPClient
 
 	// Create a new HTTP GET request
 	req, err := http.NewRequest("GET", url, nil)
@@ -506,7 +555,7 @@ func (c *yrConfig) fetch(url string) (*yrResponse, error) {
 	return &resp, nil
 }
 
-func (c *yrConfig) Fetch(location string, numdays int) iface.Data {
+func (c *yrConfig) Fetch(location string, numdays int) (iface.Data, error) {
 	//var params []string
 	//var resp yrResponse
 	var ret iface.Data
@@ -523,26 +572,36 @@ func (c *yrConfig) Fetch(location string, numdays int) iface.Data {
 		qLocation := fmt.Sprintf("%sq=%s&maxRows=1&username=yrforwego", geonamesURI, location)
 		retName, coord, err := c.geonameParser(qLocation)
 		if err != nil {
-			log.Fatalf("Failed to find location: %s\n", err)
-		} else {
-			loc = coord
-			name = retName
+			return ret, fmt.Errorf("Failed to find location: %s", err)
 		}
+		loc = coord
+		name = retName
 	}
 
 	resp, err := c.fetch(yrURI + loc)
 	if err != nil {
-		log.Fatalf("Failed to fetch weather data: %v\n", err)
+		return ret, fmt.Errorf("Failed to fetch weather data: %v", err)
+	}
+	if len(resp.Properties.TimeSeries) == 0 {
+		return ret, fmt.Errorf("Failed to fetch weather data: the response has no forecast")
 	}
 	ret.Current, _ = c.conditionParser(resp.Properties.TimeSeries[0])
 	ret.Location = fmt.Sprintf("%s", name)
 
+	// the nowcast is an extra request and only available close to the nordic
+	// radars
+	if c.nowcast {
+		if nowcast, err := c.fetch(yrNowcastURI + loc); err == nil {
+			ret.Nowcast = c.nowcastParser(nowcast)
+		}
+	}
+
 	if numdays == 0 {
-		return ret
+		return ret, nil
 	}
 	ret.Forecast = c.dayParser(resp.Properties.TimeSeries, numdays, loc)
 
-	return ret
+	return ret, nil
 }
 
 func init() {
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// preset is a named group of flag settings. Bookmarks additionally carry the
+// location they stand for.
+type preset struct {
+	name     string
+	location string
+	options  [][2]string
+}
+
+// presetList is a flag.Value holding bookmarks or profiles. Entries look like
+// NAME=LOCATION|FLAG=VALUE|... for bookmarks and NAME=FLAG=VALUE|... for
+// profiles and are separated by semicolons, because ingo writes the whole list
+// back to a single line of the config file. Setting an entry with an existing
+// name replaces the old one.
+type presetList struct {
+	bookmarks bool
+	entries   []preset
+}
+
+func (l *presetList) String() string {
+	var ret []string
+	for _, p := range l.entries {
+		var parts []string
+		if l.bookmarks {
+			parts = append(parts, p.location)
+		}
+		for _, o := range p.options {
+			parts = append(parts, o[0]+"="+o[1])
+		}
+		ret = append(ret, p.name+"="+strings.Join(parts, "|"))
+	}
+	return strings.Join(ret, ";")
+}
+
+func (l *presetList) Set(value string) error {
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, rest, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return fmt.Errorf("%q must look like NAME=...", entry)
+		}
+
+		p := preset{name: name}
+		parts := strings.Split(rest, "|")
+		if l.bookmarks {
+			p.location = strings.TrimSpace(parts[0])
+			if p.location == "" {
+				return fmt.Errorf("bookmark %q has no location", name)
+			}
+			parts = parts[1:]
+		}
+		for _, part := range parts {
+			k, v, ok := strings.Cut(part, "=")
+			k = strings.TrimSpace(k)
+			if !ok || k == "" {
+				return fmt.Errorf("option %q of %q must look like FLAG=VALUE", part, name)
+			}
+			p.options = append(p.options, [2]string{k, strings.TrimSpace(v)})
+		}
+
+		l.put(p)
+	}
+	return nil
+}
+
+// put adds p to the list, replacing an entry with the same name.
+func (l *presetList) put(p preset) {
+	for i := range l.entries {
+		if l.entries[i].name == p.name {
+			l.entries[i] = p
+			return
+		}
+	}
+	l.entries = append(l.entries, p)
+}
+
+func (l *presetList) get(name string) (preset, bool) {
+	for _, p := range l.entries {
+		if p.name == name {
+			return p, true
+		}
+	}
+	return preset{}, false
+}
+
+// flagRecorder marks the flags it is set through.
+type flagRecorder struct {
+	isBool bool
+	value  flag.Value
+	set    map[flag.Value]bool
+}
+
+func (r *flagRecorder) String() string   { return "" }
+func (r *flagRecorder) IsBoolFlag() bool { return r.isBool }
+func (r *flagRecorder) Set(string) error {
+	r.set[r.value] = true
+	return nil
+}
+
+// commandLineFlags returns the values of all flags given on the command line.
+// flag.Visit can not tell them apart from the settings of the config file,
+// because ingo applies those with flag.Set as well. With subcommand set, the
+// flags following the first argument are included.
+func commandLineFlags(subcommand bool) map[flag.Value]bool {
+	set := make(map[flag.Value]bool)
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	flag.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		fs.Var(&flagRecorder{ok && b.IsBoolFlag(), f.Value, set}, f.Name, "")
+	})
+	fs.Parse(os.Args[1:])
+	if subcommand && fs.NArg() > 0 {
+		fs.Parse(fs.Args()[1:])
+	}
+	return set
+}
+
+// apply sets the options of p, skipping flags given on the command line and
+// the ones listed in skip.
+func (p preset) apply(cli map[flag.Value]bool, skip ...string) error {
+	for _, o := range p.options {
+		f := flag.Lookup(o[0])
+		if f == nil {
+			return fmt.Errorf("%q sets unknown flag %q", p.name, o[0])
+		}
+		skipped := cli[f.Value]
+		for _, s := range skip {
+			if f.Value == flag.Lookup(s).Value {
+				skipped = true
+			}
+		}
+		if skipped {
+			continue
+		}
+		if err := f.Value.Set(o[1]); err != nil {
+			return fmt.Errorf("%q: invalid value %q for flag %q: %v", p.name, o[1], o[0], err)
+		}
+	}
+	return nil
+}
+
+// applyProfile applies the profile name to the flags, except those in one of
+// the explicit sets, like the flags given on the command line.
+func (l *presetList) applyProfile(name string, explicit ...map[flag.Value]bool) error {
+	p, ok := l.get(name)
+	if !ok {
+		return fmt.Errorf("Could not find profile \"%s\"", name)
+	}
+	skip := make(map[flag.Value]bool)
+	for _, set := range explicit {
+		for v := range set {
+			skip[v] = true
+		}
+	}
+	if err := p.apply(skip); err != nil {
+		return fmt.Errorf("Error in profile %v", err)
+	}
+	return nil
+}
+
+// option returns the value p sets for the flag with the given name or any of
+// its aliases.
+func (p preset) option(name string) (string, bool) {
+	want := flag.Lookup(name).Value
+	for _, o := range p.options {
+		if f := flag.Lookup(o[0]); f != nil && f.Value == want {
+			return o[1], true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPresetListRoundTrip(t *testing.T) {
+	l := &presetList{bookmarks: true}
+	if err := l.Set("home=59.91,10.75;cabin=61.1,8.3|backend=smhi|days=5"); err != nil {
+		t.Fatal(err)
+	}
+	// ingo applies repeated config lines one after another
+	if err := l.Set(" office = Stockholm ; home=Oslo|units=imperial"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "home=Oslo|units=imperial;cabin=61.1,8.3|backend=smhi|days=5;office=Stockholm"
+	if got := l.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+
+	again := &presetList{bookmarks: true}
+	if err := again.Set(l.String()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(again.entries, l.entries) {
+		t.Errorf("round trip changed the entries: %v != %v", again.entries, l.entries)
+	}
+
+	cabin, ok := l.get("cabin")
+	if !ok || cabin.location != "61.1,8.3" || len(cabin.options) != 2 {
+		t.Errorf("get(cabin) = %v, %v", cabin, ok)
+	}
+}
+
+func TestPresetListErrors(t *testing.T) {
+	tests := []struct {
+		bookmarks bool
+		value     string
+	}{
+		{true, "home"},
+		{true, "=Oslo"},
+		{true, "home=|days=3"},
+		{true, "home=Oslo|days"},
+		{false, "sailing=frontend"},
+	}
+	for _, tt := range tests {
+		l := &presetList{bookmarks: tt.bookmarks}
+		if err := l.Set(tt.value); err == nil {
+			t.Errorf("Set(%q) succeeded, want an error", tt.value)
+		}
+	}
+
+	l := &presetList{}
+	if err := l.Set("sailing=frontend=graph|graph-height=12"); err != nil {
+		t.Fatal(err)
+	}
+	if got := l.String(); got != "sailing=frontend=graph|graph-height=12" {
+		t.Errorf("String() = %q", got)
+	}
+}
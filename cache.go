@@ -0,0 +1,189 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// diskCache stores fetched forecasts in files below dir, so repeated runs
+// within maxAge do not query the backend again.
+type diskCache struct {
+	dir    string
+	maxAge time.Duration
+}
+
+type cacheEntry struct {
+	Backend  string
+	Location string
+	Days     int
+	Fetched  time.Time
+	Data     iface.Data
+}
+
+func defaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "wego"), nil
+}
+
+// file returns the cache file for q. The options of the backend, like its
+// language, are part of the name, but not stored in the file as they may
+// contain api keys.
+func (c *diskCache) file(q query) string {
+	key := []string{q.backend, strings.ToLower(q.location), fmt.Sprint(q.numdays)}
+	if s, ok := configSections["backends."+q.backend]; ok {
+		var names []string
+		for name := range s.flags {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			key = append(key, name+"="+s.flags[name].Value.String())
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:12])+".json")
+}
+
+// get returns the cached forecast for q if it is younger than maxAge.
+func (c *diskCache) get(q query) (iface.Data, bool) {
+	if c.maxAge <= 0 {
+		return iface.Data{}, false
+	}
+	b, err := os.ReadFile(c.file(q))
+	if err != nil {
+		return iface.Data{}, false
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(b, &e); err != nil || time.Since(e.Fetched) > c.maxAge {
+		return iface.Data{}, false
+	}
+	return e.Data, true
+}
+
+func (c *diskCache) put(q query, data iface.Data) error {
+	if c.maxAge <= 0 {
+		return nil
+	}
+	b, err := json.Marshal(cacheEntry{q.backend, q.location, q.numdays, time.Now(), data})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+	// write to a temporary file first, so concurrent runs never read a
+	// partially written entry
+	tmp, err := os.CreateTemp(c.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.file(q))
+}
+
+// entries returns all cached forecasts, ordered by the time they were fetched.
+func (c *diskCache) entries() ([]cacheEntry, error) {
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var ret []cacheEntry
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		var e cacheEntry
+		if err := json.Unmarshal(b, &e); err != nil {
+			continue
+		}
+		ret = append(ret, e)
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Fetched.Before(ret[j].Fetched) })
+	return ret, nil
+}
+
+// clear removes all cache files and returns how many there were.
+func (c *diskCache) clear() (int, error) {
+	files, err := filepath.Glob(filepath.Join(c.dir, "*.json"))
+	if err != nil {
+		return 0, err
+	}
+	for _, f := range files {
+		if err := os.Remove(f); err != nil {
+			return 0, err
+		}
+	}
+	return len(files), nil
+}
+
+// cacheCommand runs the cache subcommand.
+func (a *app) cacheCommand(args []string) error {
+	flags := flag.NewFlagSet("cache", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: wego cache list|clear|path\n\nForecasts are cached for -cache-max-age (currently %v).\n", a.cache.maxAge)
+	}
+	flags.Parse(args)
+
+	switch flags.Arg(0) {
+	case "", "list":
+		entries, err := a.cache.entries()
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 {
+			fmt.Fprintln(os.Stderr, "The cache is empty.")
+			return nil
+		}
+		out, closeOut, err := a.writer()
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			state := "fresh"
+			if age := time.Since(e.Fetched); age > a.cache.maxAge {
+				state = "expired"
+			}
+			fmt.Fprintf(out, "%-20s %-30s %2d days  fetched %s (%s)\n", e.Backend, e.Location, e.Days, e.Fetched.Format("2006-01-02 15:04"), state)
+		}
+		return closeOut()
+	case "clear":
+		n, err := a.cache.clear()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Removed %d cached forecasts.\n", n)
+	case "path":
+		out, closeOut, err := a.writer()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, a.cache.dir)
+		return closeOut()
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown cache command %q", flags.Arg(0))
+	}
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// countingBackend counts its fetches.
+type countingBackend struct{ fetches *int }
+
+func (countingBackend) Setup() {}
+func (b countingBackend) Fetch(location string, numdays int) (iface.Data, error) {
+	*b.fetches++
+	return iface.Data{Location: location}, nil
+}
+
+func TestDiskCache(t *testing.T) {
+	c := &diskCache{t.TempDir(), time.Hour}
+	oslo, bergen := query{"Oslo", "test", 3}, query{"Bergen", "test", 3}
+
+	if _, ok := c.get(oslo); ok {
+		t.Errorf("got a forecast from the empty cache")
+	}
+	for _, q := range []query{oslo, bergen} {
+		if err := c.put(q, iface.Data{Location: q.location + " (cached)"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, ok := c.get(query{"OSLO", "test", 3}); !ok || data.Location != "Oslo (cached)" {
+		t.Errorf("got %q, %v for a cached location", data.Location, ok)
+	}
+	if _, ok := c.get(query{"Oslo", "test", 2}); ok {
+		t.Errorf("got a forecast for another number of days")
+	}
+
+	entries, err := c.entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var locations []string
+	for _, e := range entries {
+		locations = append(locations, e.Location)
+	}
+	sort.Strings(locations)
+	if !reflect.DeepEqual(locations, []string{"Bergen", "Oslo"}) {
+		t.Errorf("got the entries %v", locations)
+	}
+
+	c.maxAge = time.Nanosecond
+	if _, ok := c.get(oslo); ok {
+		t.Errorf("got an expired forecast")
+	}
+
+	if n, err := c.clear(); err != nil || n != 2 {
+		t.Errorf("clear() = %d, %v, want 2 removed forecasts", n, err)
+	}
+	if entries, err := c.entries(); err != nil || len(entries) != 0 {
+		t.Errorf("got %d entries after clearing the cache, %v", len(entries), err)
+	}
+
+	// without a maximum age nothing is cached
+	c.maxAge = 0
+	if err := c.put(oslo, iface.Data{}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := c.entries(); len(entries) != 0 {
+		t.Errorf("the disabled cache stored %d entries", len(entries))
+	}
+}
+
+func TestCacheCommand(t *testing.T) {
+	fetches := 0
+	iface.AllBackends["test"] = countingBackend{&fetches}
+	defer delete(iface.AllBackends, "test")
+
+	dir := t.TempDir()
+	date, strict, output := "", false, filepath.Join(dir, "out")
+	a := &app{date: &date, strict: &strict, output: &output, cache: &diskCache{filepath.Join(dir, "cache"), time.Hour},
+		history: &historyStore{dir, false}, archive: &forecastArchive{dir, false}}
+	run := func(args ...string) string {
+		t.Helper()
+		if err := a.cacheCommand(args); err != nil {
+			t.Fatal(err)
+		}
+		b, err := os.ReadFile(output)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	qs := []query{{"Oslo", "test", 3}}
+	for i := 0; i < 2; i++ {
+		if _, err := a.fetch(qs); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("the backend was asked %d times, want the second fetch from the cache", fetches)
+	}
+
+	if out := run("list"); !strings.Contains(out, "test") || !strings.Contains(out, "Oslo") || !strings.Contains(out, "(fresh)") {
+		t.Errorf("list gave %q", out)
+	}
+	a.cache.maxAge = time.Nanosecond
+	if out := run(); !strings.Contains(out, "(expired)") {
+		t.Errorf("list gave %q for an expired forecast", out)
+	}
+	if out := run("path"); out != a.cache.dir+"\n" {
+		t.Errorf("path gave %q", out)
+	}
+
+	os.Remove(output)
+	if err := a.cacheCommand([]string{"clear"}); err != nil {
+		t.Fatal(err)
+	}
+	if entries, _ := a.cache.entries(); len(entries) != 0 {
+		t.Errorf("%d forecasts are left after clear", len(entries))
+	}
+	if err := a.cacheCommand([]string{"list"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(output); err == nil {
+		t.Errorf("list wrote an output for the empty cache")
+	}
+	if err := a.cacheCommand([]string{"purge"}); err == nil {
+		t.Errorf("unknown cache command was accepted")
+	}
+}
+
+func TestGlobalFlagsAfterCommand(t *testing.T) {
+	defer func(old *flag.FlagSet) { flag.CommandLine = old }(flag.CommandLine)
+	defer func(old []string) { os.Args = old }(os.Args)
+
+	tests := []struct {
+		args    []string
+		name    string
+		rest    []string
+		reparse bool
+		days    int
+	}{
+		{[]string{"Oslo"}, "forecast", []string{"Oslo"}, false, 3},
+		{[]string{"-days", "2", "now", "Oslo"}, "now", []string{"Oslo"}, true, 2},
+		{[]string{"hourly", "-days", "5", "Oslo", "Bergen"}, "hourly", []string{"Oslo", "Bergen"}, true, 5},
+		{[]string{"forecast", "-d", "1", "2"}, "forecast", []string{"2"}, true, 1},
+		// the flags of other commands are left to them
+		{[]string{"serve", "-listen", ":8081"}, "serve", []string{"-listen", ":8081"}, false, 3},
+	}
+	for _, tt := range tests {
+		flag.CommandLine = flag.NewFlagSet("wego", flag.ContinueOnError)
+		days := flag.Int("days", 3, "")
+		flag.IntVar(days, "d", 3, "")
+		os.Args = append([]string{"wego"}, tt.args...)
+		if err := flag.CommandLine.Parse(tt.args); err != nil {
+			t.Fatal(err)
+		}
+
+		name, rest, reparse := selectCommand()
+		if name != tt.name || !reflect.DeepEqual(rest, tt.rest) || reparse != tt.reparse || *days != tt.days {
+			t.Errorf("%v: got %s %v reparse=%v days=%d, want %s %v reparse=%v days=%d", tt.args, name, rest, reparse, *days, tt.name, tt.rest, tt.reparse, tt.days)
+		}
+		// the flags given after the command name precede the config files
+		cli := commandLineFlags(reparse)
+		if got := cli[flag.Lookup("days").Value]; got != (tt.days != 3) {
+			t.Errorf("%v: days given on the command line is %v", tt.args, got)
+		}
+	}
+}
@@ -0,0 +1,562 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mattn/go-colorable"
+	"github.com/schachmat/wego/derive"
+	"github.com/schachmat/wego/frontends"
+	"github.com/schachmat/wego/iface"
+	"golang.org/x/term"
+)
+
+// queries resolves the positional arguments of a command. A number of one or
+// two digits sets the number of days, bookmarks are replaced by their location
+// and settings and every other argument is a location. Without any, the
+// location flag is used.
+func (a *app) queries(args []string) ([]query, error) {
+	var locations []string
+	for _, arg := range args {
+		if v, err := strconv.Atoi(arg); err == nil && len(arg) <= 2 {
+			*a.numdays = v
+			a.cli[flag.Lookup("days").Value] = true
+		} else {
+			locations = append(locations, arg)
+		}
+	}
+	if len(locations) == 0 {
+		locations = []string{*a.location}
+	}
+
+	// the backend and days of a bookmark only apply to its own location, its
+	// other settings apply to the whole output
+	qs := make([]query, len(locations))
+	for i, loc := range locations {
+		qs[i] = query{loc, *a.backend, *a.numdays}
+		bm, ok := a.bookmarks.get(loc)
+		if !ok {
+			continue
+		}
+		if err := bm.apply(a.cli, "backend", "days"); err != nil {
+			return nil, fmt.Errorf("Error in bookmark %v", err)
+		}
+		qs[i].location = bm.location
+		if v, ok := bm.option("backend"); ok && !a.cli[flag.Lookup("backend").Value] {
+			qs[i].backend = v
+		}
+		if v, ok := bm.option("days"); ok && !a.cli[flag.Lookup("days").Value] {
+			days, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("Error in bookmark \"%s\": invalid number of days \"%s\"", loc, v)
+			}
+			qs[i].numdays = days
+		}
+	}
+	return qs, nil
+}
+
+// fetch gets the forecasts of all queries concurrently from their backends or
+// the cache, keeping their order. With -date it gets the weather of that day
+// instead.
+func (a *app) fetch(qs []query) ([]iface.Data, error) {
+	for _, q := range qs {
+		if _, ok := iface.AllBackends[q.backend]; !ok {
+			return nil, fmt.Errorf("Could not find selected backend \"%s\"", q.backend)
+		}
+	}
+
+	for _, q := range qs {
+		if err := checkQuery(q); err != nil {
+			return nil, err
+		}
+	}
+
+	rs := make([]iface.Data, len(qs))
+	fresh := make([]bool, len(qs))
+	if *a.date != "" {
+		var err error
+		if rs, err = a.fetchHistory(qs, *a.date); err != nil {
+			return nil, err
+		}
+		for i := range qs {
+			qs[i].numdays = 1
+		}
+	} else {
+		var wg sync.WaitGroup
+		errs := make([]error, len(qs))
+		for i, q := range qs {
+			if data, ok := a.cache.get(q); ok {
+				rs[i] = data
+				continue
+			}
+			fresh[i] = true
+			wg.Add(1)
+			go func(i int, q query) {
+				defer wg.Done()
+				if rs[i], errs[i] = fetchBackend(q); errs[i] != nil {
+					return
+				}
+				if err := a.cache.put(q, rs[i]); err != nil {
+					log.Printf("Unable to cache the forecast: %v", err)
+				}
+			}(i, q)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// frontends rely on clean data, in strict mode every fix is an error
+	var problems []string
+	for i := range rs {
+		var warnings []string
+		rs[i], warnings = iface.Normalize(rs[i], qs[i].numdays)
+		for _, w := range warnings {
+			problems = append(problems, qs[i].backend+": "+w)
+		}
+		if fresh[i] {
+			if err := a.history.add(qs[i], rs[i]); err != nil {
+				log.Printf("Unable to record the history: %v", err)
+			}
+			if err := a.archive.add(qs[i], rs[i]); err != nil {
+				log.Printf("Unable to archive the forecast: %v", err)
+			}
+		}
+		rs[i] = derive.Data(rs[i])
+		rs[i].Backend = qs[i].backend
+	}
+	if *a.strict && len(problems) > 0 {
+		return nil, fmt.Errorf("The weather data violates the backend contract:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return rs, nil
+}
+
+// fetchBackend gets the forecast of q from its backend. A panic of the backend
+// on a malformed response is returned as an error, so it does not take down
+// long running commands like serve.
+func fetchBackend(q query) (data iface.Data, err error) {
+	defer recoverBackend(q.backend, &err)
+	return iface.AllBackends[q.backend].Fetch(q.location, q.numdays)
+}
+
+// recoverBackend stores a panic of the backend name in err. It must be
+// deferred directly.
+func recoverBackend(name string, err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("The %s backend failed: %v", name, r)
+	}
+}
+
+// locationExamples shows the accepted location formats in error messages.
+var locationExamples = map[string]string{
+	iface.LocationCoordinates: "coordinates like \"59.329,18.068\"",
+	iface.LocationPostcode:    "postcodes like \"10115\"",
+	iface.LocationName:        "place names like \"Stockholm\"",
+	iface.LocationFile:        "file names like \"forecast.json\"",
+}
+
+// checkQuery returns an error if the backend of q can not answer it, so users
+// get a helpful message before anything is fetched. Backends without
+// capabilities are not checked.
+func checkQuery(q query) error {
+	be, ok := iface.AllBackends[q.backend].(iface.CapableBackend)
+	if !ok {
+		return nil
+	}
+	caps := be.Capabilities()
+
+	if caps.MaxDays > 0 && q.numdays > caps.MaxDays {
+		return fmt.Errorf("The %s backend forecasts at most %d days, not %d. Use -days %d or less.", q.backend, caps.MaxDays, q.numdays, caps.MaxDays)
+	}
+	if !caps.AcceptsLocation(q.location) {
+		var examples []string
+		for _, f := range caps.LocationFormats {
+			examples = append(examples, locationExamples[f])
+		}
+		return fmt.Errorf("The %s backend does not accept the location \"%s\", only %s.", q.backend, q.location, strings.Join(examples, " or "))
+	}
+	if caps.KeyFlag != "" {
+		if f := flag.Lookup(caps.KeyFlag); f != nil && f.Value.String() == "" {
+			return fmt.Errorf("The %s backend needs an api key. Set it with -%s or %s.", q.backend, caps.KeyFlag, envName(caps.KeyFlag))
+		}
+	}
+	if caps.LanguageFlag != "" {
+		if f := flag.Lookup(caps.LanguageFlag); f != nil && f.Value.String() != "" && !contains(caps.Languages, f.Value.String()) {
+			return fmt.Errorf("The %s backend does not support the language \"%s\" of -%s. Choose one of: %s", q.backend, f.Value, caps.LanguageFlag, strings.Join(caps.Languages, ", "))
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *app) unit() iface.UnitSystem {
+	unit, _ := parseUnitSystem(*a.unitSystem)
+	return unit
+}
+
+// writer returns the output file or stdout and a function to close it.
+func (a *app) writer() (io.Writer, func() error, error) {
+	if *a.output == "" {
+		return colorable.NewColorableStdout(), func() error { return nil }, nil
+	}
+	f, err := os.Create(*a.output)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to create output file: %v", err)
+	}
+	return f, f.Close, nil
+}
+
+// colors returns out if it writes to a terminal and otherwise a writer
+// stripping the ANSI colors, which only clutter files and pipes.
+func (a *app) colors(out io.Writer) io.Writer {
+	if *a.output == "" && term.IsTerminal(int(os.Stdout.Fd())) {
+		return out
+	}
+	return colorable.NewNonColorable(out)
+}
+
+func (a *app) forecast(args []string) error {
+	qs, err := a.queries(args)
+	if err != nil {
+		return err
+	}
+
+	if *a.interactive {
+		if *a.date != "" {
+			return fmt.Errorf("The interactive mode shows only forecasts, it can not be combined with -date")
+		}
+		// only cycle through the backends which can answer the query
+		backends := make(map[string]iface.Backend)
+		for name, be := range iface.AllBackends {
+			if checkQuery(query{qs[0].location, name, qs[0].numdays}) == nil {
+				backends[name] = be
+			}
+		}
+		ui := &frontends.Interactive{
+			Backends: backends,
+			Fetch: func(backend, location string, numdays int) (iface.Data, error) {
+				rs, err := a.fetch([]query{{location, backend, numdays}})
+				if err != nil {
+					return iface.Data{}, err
+				}
+				return rs[0], nil
+			},
+			Backend:  qs[0].backend,
+			Location: qs[0].location,
+			NumDays:  qs[0].numdays,
+			Unit:     a.unit(),
+			In:       os.Stdin,
+			Out:      colorable.NewColorableStdout(),
+		}
+		return ui.Run()
+	}
+
+	rs, err := a.fetch(qs)
+	if err != nil {
+		return err
+	}
+
+	// get selected frontend and render the weather data with it
+	fe, ok := iface.AllFrontends[*a.frontend]
+	if !ok {
+		return fmt.Errorf("Could not find selected frontend \"%s\"", *a.frontend)
+	}
+	out, closeOut, err := a.writer()
+	if err != nil {
+		return err
+	}
+	if mfe, ok := fe.(iface.MultiFrontend); ok && len(rs) > 1 {
+		err = mfe.RenderMulti(out, rs, a.unit())
+	} else {
+		for i, r := range rs {
+			if i > 0 {
+				fmt.Fprintln(out)
+			}
+			if err = fe.Render(out, r, a.unit()); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		closeOut()
+		return fmt.Errorf("Unable to render the weather data: %v", err)
+	}
+	return closeOut()
+}
+
+// text runs a command printing plain text for each location, separated by
+// empty lines if separate is set.
+func (a *app) text(args []string, numdays int, separate bool, print func(w io.Writer, r iface.Data)) error {
+	qs, err := a.queries(args)
+	if err != nil {
+		return err
+	}
+	if numdays > 0 {
+		for i := range qs {
+			qs[i].numdays = numdays
+		}
+	}
+	rs, err := a.fetch(qs)
+	if err != nil {
+		return err
+	}
+
+	out, closeOut, err := a.writer()
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	for i, r := range rs {
+		if i > 0 && separate {
+			fmt.Fprintln(w)
+		}
+		print(w, r)
+	}
+	if err := w.Flush(); err != nil {
+		closeOut()
+		return err
+	}
+	return closeOut()
+}
+
+// formatCond returns the tab separated description, temperature, wind and
+// precipitation of cond.
+func formatCond(cond iface.Cond, unit iface.UnitSystem) string {
+	temp := "?"
+	_, tu := unit.Temp(0)
+	if cond.TempC != nil {
+		t, _ := unit.Temp(*cond.TempC)
+		temp = fmt.Sprintf("%d", int(t))
+		if cond.FeelsLikeC != nil {
+			fl, _ := unit.Temp(*cond.FeelsLikeC)
+			temp += fmt.Sprintf(" (%d)", int(fl))
+		}
+	}
+
+	wind := ""
+	if cond.WinddirDegree != nil {
+		arrows := []string{"↓", "↙", "←", "↖", "↑", "↗", "→", "↘"}
+		wind = arrows[((*cond.WinddirDegree+22)%360)/45] + " "
+	}
+	if cond.WindspeedKmph != nil {
+		s, su := unit.Speed(*cond.WindspeedKmph)
+		wind += fmt.Sprintf("%d", int(s))
+		if cond.WindGustKmph != nil && *cond.WindGustKmph > *cond.WindspeedKmph {
+			g, _ := unit.Speed(*cond.WindGustKmph)
+			wind += fmt.Sprintf("–%d", int(g))
+		}
+		wind += " " + su
+	}
+
+	rain := ""
+	if cond.PrecipM != nil {
+		v, u := unit.Distance(*cond.PrecipM)
+		rain = fmt.Sprintf("%.1f %s/h", v, u)
+	}
+	if cond.ChanceOfRainPercent != nil {
+		rain += fmt.Sprintf(" %d%%", *cond.ChanceOfRainPercent)
+	}
+
+	return fmt.Sprintf("%s\t%s %s\t%s\t%s", cond.Desc, temp, tu, wind, strings.TrimSpace(rain))
+}
+
+func (a *app) now(args []string) error {
+	return a.text(args, 1, false, func(w io.Writer, r iface.Data) {
+		fmt.Fprintf(w, "%s\t%s\n", r.Location, formatCond(r.Current, a.unit()))
+	})
+}
+
+func (a *app) hourly(args []string) error {
+	return a.text(args, 0, true, func(w io.Writer, r iface.Data) {
+		fmt.Fprintf(w, "Weather for %s\n", r.Location)
+		for _, d := range r.Forecast {
+			fmt.Fprintf(w, "%s\n", d.Date.Format("Mon Jan 02"))
+			for _, s := range d.Slots {
+				fmt.Fprintf(w, "  %s\t%s\n", s.Time.Format("15:04"), formatCond(s, a.unit()))
+			}
+		}
+	})
+}
+
+func (a *app) astro(args []string) error {
+	clock := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format("15:04")
+	}
+	return a.text(args, 0, true, func(w io.Writer, r iface.Data) {
+		fmt.Fprintf(w, "Astronomy for %s\n", r.Location)
+		fmt.Fprintf(w, "\tsunrise\tsunset\tdaylight\tmoonrise\tmoonset\n")
+		for _, d := range r.Forecast {
+			astro := d.Astronomy
+			daylight := "-"
+			if !astro.Sunrise.IsZero() && astro.Sunset.After(astro.Sunrise) {
+				daylight = astro.Sunset.Sub(astro.Sunrise).Truncate(time.Minute).String()
+				daylight = strings.TrimSuffix(daylight, "0s")
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", d.Date.Format("Mon Jan 02"),
+				clock(astro.Sunrise), clock(astro.Sunset), daylight,
+				clock(astro.Moonrise), clock(astro.Moonset))
+		}
+	})
+}
+
+func (a *app) alerts(args []string) error {
+	return a.text(args, 1, true, func(w io.Writer, r iface.Data) {
+		if len(r.Alerts) == 0 {
+			fmt.Fprintf(w, "No alerts for %s\n", r.Location)
+			return
+		}
+		fmt.Fprintf(w, "Alerts for %s\n", r.Location)
+		for _, alert := range r.Alerts {
+			title := alert.Title
+			if alert.Severity != "" {
+				title = "[" + alert.Severity + "] " + title
+			}
+			if alert.Sender != "" {
+				title += " (" + alert.Sender + ")"
+			}
+			fmt.Fprintf(w, "\n%s\n", title)
+			if !alert.Start.IsZero() || !alert.End.IsZero() {
+				period := "  from " + alert.Start.Format("Mon Jan 02 15:04")
+				if !alert.End.IsZero() {
+					period += " until " + alert.End.Format("Mon Jan 02 15:04")
+				}
+				fmt.Fprintln(w, period)
+			}
+			for _, line := range strings.Split(strings.TrimSpace(alert.Description), "\n") {
+				fmt.Fprintf(w, "  %s\n", line)
+			}
+		}
+	})
+}
+
+// resolution formats the time between forecast slots.
+func resolution(d time.Duration) string {
+	if d == 0 {
+		return "-"
+	} else if d == time.Hour {
+		return "hourly"
+	}
+	return "every " + strings.TrimSuffix(strings.TrimSuffix(d.String(), "0s"), "0m")
+}
+
+// capabilitySummary describes the most important capabilities in one line.
+func capabilitySummary(caps iface.Capabilities) string {
+	days := "any number of days"
+	if caps.MaxDays > 0 {
+		days = fmt.Sprintf("up to %d days", caps.MaxDays)
+	}
+	parts := []string{days}
+	if caps.Resolution > 0 {
+		parts = append(parts, resolution(caps.Resolution))
+	}
+	parts = append(parts, caps.Region, strings.Join(caps.LocationFormats, "/"))
+	if caps.KeyFlag != "" {
+		parts = append(parts, "needs -"+caps.KeyFlag)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// backends lists the backends with the api key they need and their options.
+// With -verbose all their capabilities are shown.
+func (a *app) backends(args []string) error {
+	flags := flag.NewFlagSet("backends", flag.ExitOnError)
+	verbose := flags.Bool("verbose", false, "show the capabilities of every backend")
+	flags.Parse(args)
+
+	names := make([]string, 0, len(iface.AllBackends))
+	for name := range iface.AllBackends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	if !*verbose {
+		fmt.Fprintln(w, "BACKEND\tAPI KEY\tOPTIONS")
+	}
+	for i, name := range names {
+		s := configSections["backends."+name]
+		key, options := "-", []string{}
+		for fname, f := range s.flags {
+			if !strings.HasSuffix(fname, "-api-key") {
+				options = append(options, fname)
+			} else if f.Value.String() == "" {
+				key = fname + " (missing)"
+			} else {
+				key = fname + " (set)"
+			}
+		}
+		sort.Strings(options)
+		if name == *a.backend {
+			name += " *"
+		}
+		if !*verbose {
+			fmt.Fprintf(w, "%s\t%s\t%s\n", name, key, strings.Join(options, ", "))
+			continue
+		}
+
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintln(w, name)
+		fmt.Fprintf(w, "  api key:\t%s\n", key)
+		fmt.Fprintf(w, "  options:\t%s\n", strings.Join(options, ", "))
+		be, ok := iface.AllBackends[names[i]].(iface.CapableBackend)
+		if !ok {
+			fmt.Fprintln(w, "  capabilities:\tunknown")
+			continue
+		}
+		caps := be.Capabilities()
+		days := "unlimited"
+		if caps.MaxDays > 0 {
+			days = fmt.Sprint(caps.MaxDays)
+		}
+		languages := "-"
+		if len(caps.Languages) > 0 {
+			languages = strings.Join(caps.Languages, " ")
+		}
+		alerts, nowcast, history := "no", "no", "no"
+		if caps.Alerts {
+			alerts = "yes"
+		}
+		if caps.Nowcast {
+			nowcast = "yes"
+		}
+		if _, ok := be.(iface.HistoricalBackend); ok {
+			history = "yes"
+		}
+		fmt.Fprintf(w, "  region:\t%s\n", caps.Region)
+		fmt.Fprintf(w, "  days:\t%s\n", days)
+		fmt.Fprintf(w, "  resolution:\t%s\n", resolution(caps.Resolution))
+		fmt.Fprintf(w, "  locations:\t%s\n", strings.Join(caps.LocationFormats, ", "))
+		fmt.Fprintf(w, "  languages:\t%s\n", languages)
+		fmt.Fprintf(w, "  fields:\t%s\n", strings.Join(caps.Fields, ", "))
+		fmt.Fprintf(w, "  alerts:\t%s\n", alerts)
+		fmt.Fprintf(w, "  nowcast:\t%s\n", nowcast)
+		fmt.Fprintf(w, "  history:\t%s\n", history)
+	}
+	return w.Flush()
+}
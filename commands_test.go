@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type capableBackend struct{}
+
+func (capableBackend) Setup()                                {}
+func (capableBackend) Fetch(string, int) (iface.Data, error) { return iface.Data{}, nil }
+func (capableBackend) Capabilities() iface.Capabilities {
+	return iface.Capabilities{
+		MaxDays:         5,
+		KeyFlag:         "test-api-key",
+		LocationFormats: []string{iface.LocationCoordinates},
+		LanguageFlag:    "test-lang",
+		Languages:       []string{"en", "de"},
+	}
+}
+
+func TestCheckQuery(t *testing.T) {
+	defer func(old *flag.FlagSet) { flag.CommandLine = old }(flag.CommandLine)
+	flag.CommandLine = flag.NewFlagSet("wego", flag.ContinueOnError)
+	key := flag.String("test-api-key", "", "")
+	lang := flag.String("test-lang", "en", "")
+
+	iface.AllBackends["test"] = capableBackend{}
+	defer delete(iface.AllBackends, "test")
+
+	*key = "secret"
+	if err := checkQuery(query{"59.329,18.068", "test", 5}); err != nil {
+		t.Errorf("valid query was rejected: %v", err)
+	}
+
+	tests := []struct {
+		q    query
+		want string
+	}{
+		{query{"59.329,18.068", "test", 7}, "at most 5 days"},
+		{query{"Stockholm", "test", 3}, `does not accept the location "Stockholm", only coordinates`},
+		{query{"10115", "test", 3}, "does not accept the location"},
+	}
+	for _, tt := range tests {
+		if err := checkQuery(tt.q); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("checkQuery(%v) = %v, want an error containing %q", tt.q, err, tt.want)
+		}
+	}
+
+	*lang = "xx"
+	if err := checkQuery(query{"59.329,18.068", "test", 3}); err == nil || !strings.Contains(err.Error(), "Choose one of: en, de") {
+		t.Errorf("unsupported language gave %v", err)
+	}
+	*lang, *key = "de", ""
+	if err := checkQuery(query{"59.329,18.068", "test", 3}); err == nil || !strings.Contains(err.Error(), "WEGO_TEST_API_KEY") {
+		t.Errorf("missing api key gave %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// completionFlag is a flag with all its names, the longest one first.
+type completionFlag struct {
+	names  []string
+	usage  string
+	isBool bool
+	// values lists the possible values, or is a shell command printing them
+	// if dynamic is set
+	values  []string
+	dynamic string
+}
+
+// completionFlags returns the flags of the live flag set, grouping the names
+// of each flag.
+func completionFlags() []*completionFlag {
+	byValue := make(map[flag.Value]*completionFlag)
+	var ret []*completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		cf, ok := byValue[f.Value]
+		if !ok {
+			cf = &completionFlag{}
+			b, isBool := f.Value.(interface{ IsBoolFlag() bool })
+			cf.isBool = isBool && b.IsBoolFlag()
+			byValue[f.Value] = cf
+			ret = append(ret, cf)
+		}
+		cf.names = append(cf.names, f.Name)
+		sort.Slice(cf.names, func(i, j int) bool { return len(cf.names[i]) > len(cf.names[j]) })
+		if f.Name == cf.names[0] {
+			_, usage := flag.UnquoteUsage(f)
+			cf.usage = strings.SplitN(usage, "\n", 2)[0]
+		}
+	})
+
+	var backends, frontends []string
+	for name := range iface.AllBackends {
+		backends = append(backends, name)
+	}
+	for name := range iface.AllFrontends {
+		frontends = append(frontends, name)
+	}
+	sort.Strings(backends)
+	sort.Strings(frontends)
+	for _, cf := range ret {
+		switch cf.names[0] {
+		case "backend":
+			cf.values = backends
+		case "frontend":
+			cf.values = frontends
+		case "units":
+			cf.values = []string{"metric", "imperial", "si", "metric-ms"}
+		case "location":
+			cf.dynamic = "wego completion -list bookmarks 2>/dev/null"
+		case "profile":
+			cf.dynamic = "wego completion -list profiles 2>/dev/null"
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].names[0] < ret[j].names[0] })
+	return ret
+}
+
+func completionCommands() []string {
+	var ret []string
+	for name := range commands {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// shellQuote quotes s for all supported shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func completionBash(w io.Writer, flags []*completionFlag) {
+	var all, other []string
+	fmt.Fprint(w, `# bash completion for wego, generated by "wego completion bash"
+_wego() {
+	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"
+	case "${prev#-}" in
+`)
+	for _, cf := range flags {
+		var patterns []string
+		for _, n := range cf.names {
+			all = append(all, "-"+n)
+			patterns = append(patterns, n, "-"+n)
+		}
+		switch {
+		case cf.isBool:
+			continue
+		case cf.dynamic != "":
+			fmt.Fprintf(w, "\t%s)\n\t\tCOMPREPLY=($(compgen -W \"$(%s)\" -- \"$cur\"))\n\t\treturn ;;\n", strings.Join(patterns, "|"), cf.dynamic)
+		case cf.values != nil:
+			fmt.Fprintf(w, "\t%s)\n\t\tCOMPREPLY=($(compgen -W %s -- \"$cur\"))\n\t\treturn ;;\n", strings.Join(patterns, "|"), shellQuote(strings.Join(cf.values, " ")))
+		default:
+			other = append(other, patterns...)
+		}
+	}
+	if len(other) > 0 {
+		// fall back to file names for the values of other flags
+		fmt.Fprintf(w, "\t%s)\n\t\tCOMPREPLY=()\n\t\treturn ;;\n", strings.Join(other, "|"))
+	}
+	fmt.Fprintf(w, `	esac
+	if [[ "$cur" == -* ]]; then
+		COMPREPLY=($(compgen -W %s -- "$cur"))
+		return
+	fi
+	COMPREPLY=($(compgen -W "%s $(wego completion -list bookmarks 2>/dev/null)" -- "$cur"))
+}
+complete -o default -F _wego wego
+`, shellQuote(strings.Join(all, " ")), strings.Join(completionCommands(), " "))
+}
+
+func completionZsh(w io.Writer, flags []*completionFlag) {
+	fmt.Fprint(w, `#compdef wego
+# zsh completion for wego, generated by "wego completion zsh"
+_wego() {
+	local -a flags commands
+	flags=(
+`)
+	for _, cf := range flags {
+		for _, n := range cf.names {
+			fmt.Fprintf(w, "\t\t%s\n", shellQuote("-"+n+":"+cf.usage))
+		}
+	}
+	fmt.Fprint(w, "\t)\n\tcommands=(\n")
+	for _, name := range completionCommands() {
+		usage := commands[name].usage
+		fmt.Fprintf(w, "\t\t%s\n", shellQuote(name+":"+usage[strings.Index(usage, "\t")+1:]))
+	}
+	fmt.Fprint(w, "\t)\n\tcase \"${words[CURRENT-1]#-}\" in\n")
+	var other []string
+	for _, cf := range flags {
+		var patterns []string
+		for _, n := range cf.names {
+			patterns = append(patterns, n, "-"+n)
+		}
+		switch {
+		case cf.isBool:
+			continue
+		case cf.dynamic != "":
+			fmt.Fprintf(w, "\t%s)\n\t\tcompadd -- ${(f)\"$(%s)\"}\n\t\treturn ;;\n", strings.Join(patterns, "|"), cf.dynamic)
+		case cf.values != nil:
+			fmt.Fprintf(w, "\t%s)\n\t\tcompadd -- %s\n\t\treturn ;;\n", strings.Join(patterns, "|"), strings.Join(cf.values, " "))
+		default:
+			other = append(other, patterns...)
+		}
+	}
+	if len(other) > 0 {
+		fmt.Fprintf(w, "\t%s)\n\t\t_files\n\t\treturn ;;\n", strings.Join(other, "|"))
+	}
+	fmt.Fprint(w, `	esac
+	if [[ "$PREFIX" == -* ]]; then
+		_describe 'flag' flags
+		return
+	fi
+	_describe 'command' commands
+	compadd -- ${(f)"$(wego completion -list bookmarks 2>/dev/null)"}
+}
+compdef _wego wego
+`)
+}
+
+func completionFish(w io.Writer, flags []*completionFlag) {
+	cmds := strings.Join(completionCommands(), " ")
+	fmt.Fprintln(w, `# fish completion for wego, generated by "wego completion fish"`)
+	fmt.Fprintln(w, "complete -c wego -f")
+	for _, name := range completionCommands() {
+		usage := commands[name].usage
+		fmt.Fprintf(w, "complete -c wego -n 'not __fish_seen_subcommand_from %s' -a %s -d %s\n", cmds, name, shellQuote(usage[strings.Index(usage, "\t")+1:]))
+	}
+	fmt.Fprintf(w, "complete -c wego -n 'not __fish_seen_subcommand_from %s' -a '(wego completion -list bookmarks 2>/dev/null)'\n", cmds)
+	for _, cf := range flags {
+		line := "complete -c wego"
+		for _, n := range cf.names {
+			line += " -o " + n
+		}
+		switch {
+		case cf.isBool:
+		case cf.dynamic != "":
+			line += " -x -a " + shellQuote("("+cf.dynamic+")")
+		case cf.values != nil:
+			line += " -x -a " + shellQuote(strings.Join(cf.values, " "))
+		default:
+			line += " -r -F"
+		}
+		fmt.Fprintf(w, "%s -d %s\n", line, shellQuote(cf.usage))
+	}
+}
+
+// completion prints a completion script for the given shell. The scripts call
+// "wego completion -list bookmarks" to complete the current bookmarks.
+func (a *app) completion(args []string) error {
+	flags := flag.NewFlagSet("completion", flag.ExitOnError)
+	list := flags.String("list", "", "print the names of all `bookmarks` or `profiles`")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "Usage: wego completion bash|zsh|fish")
+	}
+	flags.Parse(args)
+
+	switch *list {
+	case "":
+	case "bookmarks", "profiles":
+		l := a.bookmarks
+		if *list == "profiles" {
+			l = a.profiles
+		}
+		for _, p := range l.entries {
+			fmt.Println(p.name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unable to list %q, use bookmarks or profiles", *list)
+	}
+
+	switch flags.Arg(0) {
+	case "bash":
+		completionBash(os.Stdout, completionFlags())
+	case "zsh":
+		completionZsh(os.Stdout, completionFlags())
+	case "fish":
+		completionFish(os.Stdout, completionFlags())
+	default:
+		flags.Usage()
+		return fmt.Errorf("unknown shell %q", flags.Arg(0))
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestCompletionScripts(t *testing.T) {
+	defer func(old *flag.FlagSet) { flag.CommandLine = old }(flag.CommandLine)
+	flag.CommandLine = flag.NewFlagSet("wego", flag.ContinueOnError)
+
+	backend := flag.String("backend", "openweathermap", "`BACKEND` to be used")
+	flag.StringVar(backend, "b", "openweathermap", "`BACKEND` to be used (shorthand)")
+	flag.String("units", "metric", "`UNITSYSTEM` to use for output.\n    \tChoices are: metric, imperial")
+	flag.String("location", "", "`LOCATION` to be queried")
+	flag.Bool("aat-coords", false, "aat-frontend: Show geo coordinate's")
+
+	flags := completionFlags()
+	if len(flags) != 4 {
+		t.Fatalf("got %d flags, want the aliases of backend grouped into 4", len(flags))
+	}
+
+	tests := []struct {
+		shell string
+		gen   func(w *bytes.Buffer)
+		check []string
+		lint  []string
+	}{
+		{"bash", func(w *bytes.Buffer) { completionBash(w, flags) },
+			[]string{"backend|-backend|b|-b)", "'metric imperial si metric-ms'", "wego completion -list bookmarks"},
+			[]string{"bash", "-n"}},
+		{"zsh", func(w *bytes.Buffer) { completionZsh(w, flags) },
+			[]string{"'-backend:BACKEND to be used'", "'-aat-coords:aat-frontend: Show geo coordinate'\\''s'", "compdef _wego wego"},
+			[]string{"zsh", "-n"}},
+		{"fish", func(w *bytes.Buffer) { completionFish(w, flags) },
+			[]string{"complete -c wego -o backend -o b -x -a", "-o location -x -a '(wego completion -list bookmarks 2>/dev/null)'"},
+			[]string{"fish", "-n"}},
+	}
+	for _, tt := range tests {
+		var out bytes.Buffer
+		tt.gen(&out)
+		for _, want := range tt.check {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s script does not contain %q:\n%s", tt.shell, want, out.String())
+			}
+		}
+
+		// check the syntax if the shell is installed
+		if _, err := exec.LookPath(tt.lint[0]); err != nil {
+			continue
+		}
+		cmd := exec.Command(tt.lint[0], tt.lint[1:]...)
+		cmd.Stdin = &out
+		if msg, err := cmd.CombinedOutput(); err != nil {
+			t.Errorf("%s script has syntax errors: %v\n%s", tt.shell, err, msg)
+		}
+	}
+}
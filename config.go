@@ -0,0 +1,518 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/schachmat/ingo"
+)
+
+// configSection holds the flags a backend or frontend registers in its Setup
+// function. In the structured config they are set in the [backends.NAME] and
+// [frontends.NAME] tables, with or without the flag prefix of the plugin.
+type configSection struct {
+	prefix string
+	flags  map[string]*flag.Flag
+}
+
+// configSections are indexed by "backends.NAME" and "frontends.NAME".
+var configSections = make(map[string]*configSection)
+
+// setupPlugin runs the Setup function of a plugin and records the flags it
+// registers in configSections.
+func setupPlugin(section string, setup func()) {
+	before := make(map[string]bool)
+	flag.VisitAll(func(f *flag.Flag) { before[f.Name] = true })
+	setup()
+
+	s := &configSection{flags: make(map[string]*flag.Flag)}
+	flag.VisitAll(func(f *flag.Flag) {
+		if before[f.Name] {
+			return
+		}
+		s.flags[f.Name] = f
+		prefix := f.Name[:strings.Index(f.Name, "-")+1]
+		if len(s.flags) == 1 {
+			s.prefix = prefix
+		} else if prefix != s.prefix {
+			s.prefix = ""
+		}
+	})
+	configSections[section] = s
+}
+
+// lookup returns the flag of the section for key, which may omit the prefix.
+func (s *configSection) lookup(key string) *flag.Flag {
+	if f, ok := s.flags[s.prefix+key]; ok {
+		return f
+	}
+	return s.flags[key]
+}
+
+func (s *configSection) keys() (ret []string) {
+	for name := range s.flags {
+		ret = append(ret, strings.TrimPrefix(name, s.prefix))
+	}
+	return ret
+}
+
+// structuredConfigPath returns $XDG_CONFIG_HOME/wego/config.toml.
+func structuredConfigPath() (string, error) {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "wego", "config.toml"), nil
+}
+
+// legacyConfigPath returns the path of the ingo config file.
+func legacyConfigPath() (string, error) {
+	if p := os.Getenv("WEGORC"); p != "" {
+		return p, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".wegorc"), nil
+}
+
+// parseLegacyConfig reads and updates the ingo config file and parses the
+// command line. A config file which can not be opened for writing, like a
+// missing one in a read-only container, is skipped with a warning, as the
+// settings can come from the environment instead. Every other error is
+// returned.
+func parseLegacyConfig() error {
+	path, err := legacyConfigPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		log.Printf("Unable to use the config file, continuing without it: %v", err)
+		return flag.CommandLine.Parse(os.Args[1:])
+	}
+	f.Close()
+	return ingo.Parse("wego")
+}
+
+// levenshtein returns the edit distance of a and b.
+func levenshtein(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+// suggest returns a hint naming the candidate closest to key, if any is close
+// enough to be a probable typo.
+func suggest(key string, candidates []string) string {
+	sort.Strings(candidates)
+	best, bestDist := "", len(key)/3+2
+	for _, c := range candidates {
+		if d := levenshtein(key, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(", did you mean %q?", best)
+}
+
+// configValue formats a toml value for flag.Value.Set.
+func configValue(key string, v interface{}) (string, error) {
+	switch v := v.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("%s must be a string, number or boolean", key)
+}
+
+func sortedKeys(m map[string]interface{}) (ret []string) {
+	for k := range m {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// configLoader applies a structured config file to the flags.
+type configLoader struct {
+	cli  map[flag.Value]bool
+	errs []error
+}
+
+func (c *configLoader) errorf(format string, a ...interface{}) {
+	c.errs = append(c.errs, fmt.Errorf(format, a...))
+}
+
+func (c *configLoader) table(key string, v interface{}) map[string]interface{} {
+	t, ok := v.(map[string]interface{})
+	if !ok {
+		c.errorf("%s must be a table", key)
+	}
+	return t
+}
+
+// set sets the flag f from key, unless it was given on the command line.
+func (c *configLoader) set(key string, f *flag.Flag, v interface{}) {
+	s, err := configValue(key, v)
+	if err != nil {
+		c.errs = append(c.errs, err)
+		return
+	}
+	if c.cli[f.Value] {
+		return
+	}
+	if err := f.Value.Set(s); err != nil {
+		c.errorf("%s: invalid value %q: %v", key, s, err)
+	}
+}
+
+// options converts a table of flag settings to preset options.
+func (c *configLoader) options(key string, t map[string]interface{}, skip string) (ret [][2]string) {
+	var names []string
+	flag.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+	for _, k := range sortedKeys(t) {
+		if k == skip {
+			continue
+		}
+		if flag.Lookup(k) == nil {
+			c.errorf("%s: unknown flag %q%s", key, k, suggest(k, names))
+			continue
+		}
+		s, err := configValue(key+"."+k, t[k])
+		if err != nil {
+			c.errs = append(c.errs, err)
+			continue
+		}
+		ret = append(ret, [2]string{k, s})
+	}
+	return ret
+}
+
+// presets adds the bookmarks or profiles table to the list of the flag.
+func (c *configLoader) presets(key string, v interface{}) {
+	f := flag.Lookup(key)
+	l := f.Value.(*presetList)
+	t := c.table(key, v)
+	for _, name := range sortedKeys(t) {
+		p := preset{name: name}
+		entryKey := key + "." + name
+		switch e := t[name].(type) {
+		case string:
+			if !l.bookmarks {
+				c.errorf("%s must be a table of flag settings", entryKey)
+				continue
+			}
+			p.location = e
+		case map[string]interface{}:
+			if l.bookmarks {
+				loc, ok := e["location"].(string)
+				if !ok {
+					c.errorf("%s needs a location", entryKey)
+					continue
+				}
+				p.location = loc
+			}
+			skip := ""
+			if l.bookmarks {
+				skip = "location"
+			}
+			p.options = c.options(entryKey, e, skip)
+		default:
+			c.errorf("%s must be a string or a table", entryKey)
+			continue
+		}
+		// entries of the same name given on the command line take precedence
+		if _, ok := l.get(name); ok && c.cli[f.Value] {
+			continue
+		}
+		l.put(p)
+	}
+}
+
+func (c *configLoader) plugins(kind string, v interface{}) {
+	var names []string
+	for name := range configSections {
+		if strings.HasPrefix(name, kind+".") {
+			names = append(names, strings.TrimPrefix(name, kind+"."))
+		}
+	}
+
+	t := c.table(kind, v)
+	for _, name := range sortedKeys(t) {
+		key := kind + "." + name
+		s, ok := configSections[key]
+		if !ok {
+			c.errorf("unknown %s %q%s", strings.TrimSuffix(kind, "s"), name, suggest(name, names))
+			continue
+		}
+		opts := c.table(key, t[name])
+		for _, k := range sortedKeys(opts) {
+			f := s.lookup(k)
+			if f == nil {
+				c.errorf("unknown key %q%s", key+"."+k, suggest(k, s.keys()))
+				continue
+			}
+			c.set(key+"."+k, f, opts[k])
+		}
+	}
+}
+
+// loadStructuredConfig applies the toml config file at path to the flags which
+// were not given on the command line. A missing file is not an error.
+func loadStructuredConfig(path string, cli map[flag.Value]bool) error {
+	var doc map[string]interface{}
+	if _, err := toml.DecodeFile(path, &doc); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if perr, ok := err.(toml.ParseError); ok {
+		return fmt.Errorf("%s: %s", path, perr.ErrorWithPosition())
+	} else if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+
+	names := []string{"backends", "frontends"}
+	flag.VisitAll(func(f *flag.Flag) { names = append(names, f.Name) })
+
+	c := &configLoader{cli: cli}
+	for _, key := range sortedKeys(doc) {
+		switch key {
+		case "backends", "frontends":
+			c.plugins(key, doc[key])
+		case "bookmarks", "profiles":
+			if _, ok := doc[key].(string); !ok {
+				c.presets(key, doc[key])
+				break
+			}
+			fallthrough
+		default:
+			f := flag.Lookup(key)
+			if f == nil {
+				c.errorf("unknown key %q%s", key, suggest(key, names))
+				continue
+			}
+			c.set(key, f, doc[key])
+		}
+	}
+
+	if len(c.errs) > 0 {
+		return fmt.Errorf("%s:\n%v", path, errors.Join(c.errs...))
+	}
+	return nil
+}
+
+// migrateValue converts the config value of f to the matching toml type.
+func migrateValue(f *flag.Flag, val string) interface{} {
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return val
+	}
+	switch getter.Get().(type) {
+	case bool:
+		if b, err := strconv.ParseBool(val); err == nil {
+			return b
+		}
+	case int:
+		if i, err := strconv.Atoi(val); err == nil {
+			return i
+		}
+	}
+	return val
+}
+
+// migratePresets converts a bookmarks or profiles config value to a table.
+func migratePresets(bookmarks bool, val string) (map[string]interface{}, error) {
+	l := &presetList{bookmarks: bookmarks}
+	if err := l.Set(val); err != nil {
+		return nil, err
+	}
+	ret := make(map[string]interface{})
+	for _, p := range l.entries {
+		if bookmarks && len(p.options) == 0 {
+			ret[p.name] = p.location
+			continue
+		}
+		t := make(map[string]interface{})
+		if bookmarks {
+			t["location"] = p.location
+		}
+		for _, o := range p.options {
+			if f := flag.Lookup(o[0]); f != nil {
+				t[o[0]] = migrateValue(f, o[1])
+			} else {
+				t[o[0]] = o[1]
+			}
+		}
+		ret[p.name] = t
+	}
+	return ret, nil
+}
+
+// migrateConfig converts the settings of the legacy config which differ from
+// the defaults to the structured config.
+func migrateConfig(args []string) error {
+	flags := flag.NewFlagSet("config migrate", flag.ExitOnError)
+	force := flags.Bool("force", false, "overwrite an existing structured config file")
+	flags.Parse(args)
+
+	legacy, err := legacyConfigPath()
+	if err != nil {
+		return err
+	}
+	path, err := structuredConfigPath()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil && !*force {
+		return fmt.Errorf("%s exists already, use -force to overwrite it", path)
+	}
+
+	in, err := os.Open(legacy)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	sectionOf := make(map[string]string)
+	for name, s := range configSections {
+		for fname := range s.flags {
+			sectionOf[fname] = name
+		}
+	}
+
+	doc := make(map[string]interface{})
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		i := strings.IndexAny(line, "=:")
+		if strings.HasPrefix(line, "#") || i == -1 {
+			continue
+		}
+		key, val := strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:])
+		f := flag.Lookup(key)
+		if f == nil {
+			log.Printf("Skipping unknown key %q of %s", key, legacy)
+			continue
+		}
+		if val == f.DefValue {
+			continue
+		}
+
+		switch section := sectionOf[key]; {
+		case key == "bookmarks" || key == "profiles":
+			t, err := migratePresets(key == "bookmarks", val)
+			if err != nil {
+				return fmt.Errorf("%s: %s: %v", legacy, key, err)
+			}
+			doc[key] = t
+		case section != "":
+			kind, name, _ := strings.Cut(section, ".")
+			plugins, _ := doc[kind].(map[string]interface{})
+			if plugins == nil {
+				plugins = make(map[string]interface{})
+				doc[kind] = plugins
+			}
+			t, _ := plugins[name].(map[string]interface{})
+			if t == nil {
+				t = make(map[string]interface{})
+				plugins[name] = t
+			}
+			t[strings.TrimPrefix(key, configSections[section].prefix)] = migrateValue(f, val)
+		default:
+			doc[key] = migrateValue(f, val)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "# wego configuration, migrated from %s\n#\n", legacy)
+	fmt.Fprintf(&out, "# Settings here take precedence over %s.\n\n", legacy)
+	enc := toml.NewEncoder(&out)
+	enc.Indent = ""
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// the config may contain api keys
+	if err := os.WriteFile(path, out.Bytes(), 0600); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s\n", path)
+	return nil
+}
+
+// config runs the config subcommand. It runs before the config files are
+// loaded, so broken ones can be checked and replaced.
+func (a *app) config(args []string) error {
+	cmd := ""
+	if len(args) > 0 {
+		cmd = args[0]
+	}
+	switch cmd {
+	case "migrate":
+		if err := migrateConfig(args[1:]); err != nil {
+			return fmt.Errorf("Unable to migrate the config: %v", err)
+		}
+	case "check":
+		path, err := structuredConfigPath()
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(path); err != nil {
+			return err
+		}
+		if err := loadStructuredConfig(path, commandLineFlags(false)); err != nil {
+			return fmt.Errorf("Error parsing config: %v", err)
+		}
+		fmt.Fprintf(os.Stderr, "%s is valid\n", path)
+	default:
+		return fmt.Errorf("Unknown config command %q, use \"migrate\" or \"check\"", cmd)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadStructuredConfig(t *testing.T) {
+	defer func(old *flag.FlagSet) { flag.CommandLine = old }(flag.CommandLine)
+	flag.CommandLine = flag.NewFlagSet("wego", flag.ContinueOnError)
+	configSections = make(map[string]*configSection)
+
+	days := flag.Int("days", 3, "")
+	frontend := flag.String("frontend", "ascii-art-table", "")
+	bookmarks := &presetList{bookmarks: true}
+	flag.Var(bookmarks, "bookmarks", "")
+	var apiKey string
+	var debug bool
+	setupPlugin("backends.test", func() {
+		flag.StringVar(&apiKey, "tst-api-key", "", "")
+		flag.BoolVar(&debug, "tst-debug", false, "")
+	})
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	conf := `
+days = 5
+frontend = "json"
+
+[backends.test]
+api-key = "secret"
+tst-debug = true
+
+[bookmarks]
+home = "59.91,10.75"
+cabin = { location = "61.1,8.3", days = 2 }
+`
+	if err := os.WriteFile(path, []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the frontend was given on the command line
+	cli := map[flag.Value]bool{flag.Lookup("frontend").Value: true}
+	if err := loadStructuredConfig(path, cli); err != nil {
+		t.Fatal(err)
+	}
+	if *days != 5 || *frontend != "ascii-art-table" || apiKey != "secret" || !debug {
+		t.Errorf("got days=%d frontend=%q api key=%q debug=%v", *days, *frontend, apiKey, debug)
+	}
+	if want := "cabin=61.1,8.3|days=2;home=59.91,10.75"; bookmarks.String() != want {
+		t.Errorf("bookmarks = %q, want %q", bookmarks.String(), want)
+	}
+
+	conf = `
+dayz = 4
+[backends.test]
+apikey = "x"
+[backends.tset]
+[frontends.test]
+`
+	if err := os.WriteFile(path, []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+	err := loadStructuredConfig(path, cli)
+	if err == nil {
+		t.Fatal("unknown keys were accepted")
+	}
+	for _, want := range []string{
+		`unknown key "dayz", did you mean "days"?`,
+		`unknown key "backends.test.apikey", did you mean "api-key"?`,
+		`unknown backend "tset", did you mean "test"?`,
+		`unknown frontend "test"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+
+	if err := loadStructuredConfig(filepath.Join(t.TempDir(), "missing.toml"), cli); err != nil {
+		t.Errorf("a missing config file is not an error: %v", err)
+	}
+}
+
+func TestParseLegacyConfig(t *testing.T) {
+	defer func(old *flag.FlagSet) { flag.CommandLine = old }(flag.CommandLine)
+	defer func(old []string) { os.Args = old }(os.Args)
+	os.Args = []string{"wego", "-days", "5", "Oslo"}
+	dir := t.TempDir()
+
+	setup := func() (*string, *int) {
+		flag.CommandLine = flag.NewFlagSet("wego", flag.ContinueOnError)
+		return flag.String("backend", "openweathermap", ""), flag.Int("days", 3, "")
+	}
+
+	// a config file which can not be created is skipped
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WEGORC", filepath.Join(blocker, "wegorc"))
+	backend, days := setup()
+	if err := parseLegacyConfig(); err != nil {
+		t.Fatalf("unusable config file gave %v", err)
+	}
+	if *backend != "openweathermap" || *days != 5 || flag.Arg(0) != "Oslo" {
+		t.Errorf("got backend=%q days=%d args=%v", *backend, *days, flag.Args())
+	}
+
+	rc := filepath.Join(dir, "wegorc")
+	if err := os.WriteFile(rc, []byte("backend=smhi\ndays=2\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("WEGORC", rc)
+	backend, days = setup()
+	if err := parseLegacyConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if *backend != "smhi" || *days != 5 {
+		t.Errorf("got backend=%q days=%d from the config file and command line", *backend, *days)
+	}
+
+	// errors of a usable config file are not skipped
+	if err := parseLegacyConfig(); err == nil {
+		t.Errorf("parsing the flags twice was accepted")
+	}
+}
@@ -0,0 +1,151 @@
+// Package derive computes meteorological quantities which backends do not
+// provide from the temperature, humidity and wind speed they do provide.
+package derive
+
+import (
+	"math"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// DewPointC returns the dew point for the temperature and relative humidity,
+// using the Magnus formula.
+func DewPointC(tempC float32, humidity int) float32 {
+	const a, b = 17.62, 243.12
+	gamma := math.Log(float64(humidity)/100) + a*float64(tempC)/(b+float64(tempC))
+	return float32(b * gamma / (a - gamma))
+}
+
+// HeatIndexC returns the heat index of the US National Weather Service. It is
+// only meaningful above 27°C.
+func HeatIndexC(tempC float32, humidity int) float32 {
+	t, rh := float64(tempC)*1.8+32, float64(humidity)
+	hi := 0.5 * (t + 61 + (t-68)*1.2 + rh*0.094)
+	if (hi+t)/2 >= 80 {
+		hi = -42.379 + 2.04901523*t + 10.14333127*rh - 0.22475541*t*rh -
+			0.00683783*t*t - 0.05481717*rh*rh + 0.00122874*t*t*rh +
+			0.00085282*t*rh*rh - 0.00000199*t*t*rh*rh
+	}
+	return float32((hi - 32) / 1.8)
+}
+
+// WindChillC returns the wind chill temperature of Environment Canada and the
+// US National Weather Service. It is only meaningful at or below 10°C and wind
+// speeds above 4.8 km/h.
+func WindChillC(tempC, windKmph float32) float32 {
+	v := math.Pow(float64(windKmph), 0.16)
+	return float32(13.12 + 0.6215*float64(tempC) - 11.37*v + 0.3965*float64(tempC)*v)
+}
+
+// HumidexC returns the humidex of Environment Canada for the temperature and
+// dew point.
+func HumidexC(tempC, dewPointC float32) float32 {
+	e := 6.11 * math.Exp(5417.7530*(1/273.16-1/(273.15+float64(dewPointC))))
+	return tempC + float32(0.5555*(e-10))
+}
+
+// WetBulbC returns the wet-bulb temperature at sea level pressure, using the
+// approximation of Roland Stull.
+func WetBulbC(tempC float32, humidity int) float32 {
+	t, rh := float64(tempC), float64(humidity)
+	return float32(t*math.Atan(0.151977*math.Sqrt(rh+8.313659)) +
+		math.Atan(t+rh) - math.Atan(rh-1.676331) +
+		0.00391838*math.Pow(rh, 1.5)*math.Atan(0.023101*rh) - 4.686035)
+}
+
+// FeelsLikeC returns the wind chill at or below 10°C, the heat index above
+// 27°C and otherwise the temperature itself. humidity and windKmph may be nil
+// if unknown.
+func FeelsLikeC(tempC float32, humidity *int, windKmph *float32) float32 {
+	if tempC <= 10 && windKmph != nil && *windKmph > 4.8 {
+		return WindChillC(tempC, *windKmph)
+	} else if tempC > 27 && humidity != nil {
+		if hi := HeatIndexC(tempC, *humidity); hi > tempC {
+			return hi
+		}
+	}
+	return tempC
+}
+
+// beaufortLimits are the lowest wind speeds in km/h of the Beaufort forces 1
+// to 12.
+var beaufortLimits = []float32{1.8, 5.8, 12.2, 19.8, 28.8, 38.9, 50, 61.9, 74.9, 88.2, 102.6, 117.7}
+
+var beaufortDescriptions = []string{
+	"calm", "light air", "light breeze", "gentle breeze", "moderate breeze",
+	"fresh breeze", "strong breeze", "near gale", "gale", "strong gale",
+	"storm", "violent storm", "hurricane force",
+}
+
+// Beaufort returns the force from 0 to 12 on the Beaufort scale.
+func Beaufort(windKmph float32) int {
+	force := 0
+	for force < len(beaufortLimits) && windKmph >= beaufortLimits[force] {
+		force++
+	}
+	return force
+}
+
+// BeaufortDescription returns the name of a Beaufort force, like "gale".
+func BeaufortDescription(force int) string {
+	if force < 0 || force >= len(beaufortDescriptions) {
+		return ""
+	}
+	return beaufortDescriptions[force]
+}
+
+// Cond returns c with all derivable fields computed which the backend did not
+// supply. Their names are added to c.Derived.
+func Cond(c iface.Cond) iface.Cond {
+	if c.TempC == nil {
+		return c
+	}
+	derived := c.Derived[:len(c.Derived):len(c.Derived)]
+	set := func(p **float32, name string, v float32) {
+		if *p == nil {
+			*p = &v
+			derived = append(derived, name)
+		}
+	}
+
+	set(&c.FeelsLikeC, "FeelsLikeC", FeelsLikeC(*c.TempC, c.Humidity, c.WindspeedKmph))
+	if c.Humidity != nil && *c.Humidity > 0 {
+		set(&c.DewPointC, "DewPointC", DewPointC(*c.TempC, *c.Humidity))
+		set(&c.WetBulbC, "WetBulbC", WetBulbC(*c.TempC, *c.Humidity))
+		if *c.TempC > 27 {
+			set(&c.HeatIndexC, "HeatIndexC", HeatIndexC(*c.TempC, *c.Humidity))
+		}
+	}
+	if c.DewPointC != nil {
+		set(&c.HumidexC, "HumidexC", HumidexC(*c.TempC, *c.DewPointC))
+	}
+	if len(derived) > 0 {
+		c.Derived = derived
+	}
+	return c
+}
+
+// Data returns data with the derivable fields of the current conditions and
+// all forecast slots computed, and a Summary for every day.
+func Data(data iface.Data) iface.Data {
+	data.Current = Cond(data.Current)
+	days := make([]iface.Day, len(data.Forecast))
+	for i, day := range data.Forecast {
+		if day.Slots != nil {
+			slots := make([]iface.Cond, len(day.Slots))
+			for j, slot := range day.Slots {
+				slots[j] = Cond(slot)
+			}
+			day.Slots = slots
+		}
+		if day.Summary != nil || len(day.Slots) > 0 {
+			s := Summary(day)
+			day.Summary = &s
+		}
+		days[i] = day
+	}
+	if data.Forecast != nil {
+		data.Forecast = days
+	}
+	return data
+}
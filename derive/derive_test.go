@@ -0,0 +1,110 @@
+package derive
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestFormulas(t *testing.T) {
+	tests := []struct {
+		name      string
+		got, want float32
+	}{
+		// reference values from the tables of the respective weather services
+		{"dew point", DewPointC(20, 50), 9.3},
+		{"heat index", HeatIndexC(32, 70), 40.4},
+		{"wind chill", WindChillC(-10, 20), -17.9},
+		{"humidex", HumidexC(30, 15), 34.0},
+		{"wet-bulb", WetBulbC(20, 50), 13.7},
+		{"feels like cold", FeelsLikeC(-10, nil, ptr(float32(20))), -17.9},
+		{"feels like hot", FeelsLikeC(32, ptr(70), nil), 40.4},
+		{"feels like mild", FeelsLikeC(18, ptr(70), ptr(float32(30))), 18},
+		{"feels like calm", FeelsLikeC(5, nil, ptr(float32(3))), 5},
+	}
+	for _, tt := range tests {
+		if math.Abs(float64(tt.got-tt.want)) > 0.1 {
+			t.Errorf("%s: got %.2f, want %.1f", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestBeaufort(t *testing.T) {
+	for _, tt := range []struct {
+		kmph  float32
+		force int
+		desc  string
+	}{
+		{0, 0, "calm"},
+		{5, 1, "light air"},
+		{30, 5, "fresh breeze"},
+		{80, 9, "strong gale"},
+		{150, 12, "hurricane force"},
+	} {
+		if got := Beaufort(tt.kmph); got != tt.force || BeaufortDescription(got) != tt.desc {
+			t.Errorf("Beaufort(%v) = %d %q, want %d %q", tt.kmph, got, BeaufortDescription(got), tt.force, tt.desc)
+		}
+	}
+}
+
+func TestCond(t *testing.T) {
+	measured := float32(31)
+	c := Cond(iface.Cond{TempC: ptr(float32(30)), FeelsLikeC: &measured, Humidity: ptr(60)})
+	if c.FeelsLikeC != &measured {
+		t.Errorf("the feels like temperature of the backend was replaced")
+	}
+	if want := []string{"DewPointC", "WetBulbC", "HeatIndexC", "HumidexC"}; !reflect.DeepEqual(c.Derived, want) {
+		t.Errorf("got derived fields %v, want %v", c.Derived, want)
+	}
+	if c.DewPointC == nil || c.HumidexC == nil || c.WetBulbC == nil || c.HeatIndexC == nil {
+		t.Errorf("not all fields were derived: %+v", c)
+	}
+
+	if c := Cond(iface.Cond{Humidity: ptr(60)}); c.Derived != nil || c.FeelsLikeC != nil {
+		t.Errorf("fields were derived without a temperature: %+v", c)
+	}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestSummary(t *testing.T) {
+	at := func(hour int) time.Time { return time.Date(2024, 7, 14, hour, 0, 0, 0, time.UTC) }
+	day := iface.Day{
+		Date: at(0),
+		Slots: []iface.Cond{
+			{Time: at(0), Code: iface.CodeCloudy, TempC: ptr(float32(12)), PrecipM: ptr(float32(0.001)), ChanceOfRainPercent: ptr(60)},
+			{Time: at(6), Code: iface.CodeSunny, TempC: ptr(float32(15)), WindspeedKmph: ptr(float32(10)), WindGustKmph: ptr(float32(25))},
+			{Time: at(12), Code: iface.CodePartlyCloudy, TempC: ptr(float32(21)), WindspeedKmph: ptr(float32(20))},
+			{Time: at(18), Code: iface.CodeSunny, TempC: ptr(float32(17)), ChanceOfRainPercent: ptr(10)},
+		},
+		Astronomy: iface.Astro{Sunrise: at(4), Sunset: at(20)},
+		Summary:   &iface.Summary{MaxTempC: ptr(float32(22))},
+	}
+	s := Summary(day)
+
+	if *s.MaxTempC != 22 || *s.MinTempC != 12 {
+		t.Errorf("got temperatures %v/%v, want 22/12", *s.MaxTempC, *s.MinTempC)
+	}
+	if math.Abs(float64(*s.PrecipM-0.006)) > 1e-6 {
+		t.Errorf("got precipitation %v, want 0.006", *s.PrecipM)
+	}
+	if *s.MaxChanceOfRainPercent != 60 || *s.MaxWindspeedKmph != 20 || *s.MaxWindGustKmph != 25 {
+		t.Errorf("got maxima %+v", s)
+	}
+	if s.Code != iface.CodeSunny {
+		t.Errorf("got dominant code %d, want %d", s.Code, iface.CodeSunny)
+	}
+	// 6:00 to 12:00 and 18:00 to sunset are sunny, 12:00 to 18:00 half of it
+	if *s.SunshineHours != 11 {
+		t.Errorf("got %v hours of sunshine, want 11", *s.SunshineHours)
+	}
+
+	if s := Summary(iface.Day{}); s.MinTempC != nil || s.SunshineHours != nil {
+		t.Errorf("got a summary for a day without data: %+v", s)
+	}
+}
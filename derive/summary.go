@@ -0,0 +1,118 @@
+package derive
+
+import (
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// sunshine is the fraction of a slot with the given weather code which is
+// counted as sunshine if the slot is between sunrise and sunset.
+var sunshine = map[iface.WeatherCode]float32{
+	iface.CodeSunny:        1,
+	iface.CodePartlyCloudy: 0.5,
+}
+
+// Summary returns the summary of day. The fields which the backend did not
+// supply in day.Summary are computed from the slots. The sunshine hours are an
+// estimate from the weather codes and only computed if the sunrise and sunset
+// are known.
+func Summary(day iface.Day) iface.Summary {
+	var s iface.Summary
+	if day.Summary != nil {
+		s = *day.Summary
+	}
+	if len(day.Slots) == 0 {
+		return s
+	}
+
+	var low, high, wind, gust, precip, sun *float32
+	var chance *int
+	durations := map[iface.WeatherCode]time.Duration{}
+	dominant := iface.CodeUnknown
+	sunrise, sunset := day.Astronomy.Sunrise, day.Astronomy.Sunset
+	for i, c := range day.Slots {
+		dur := slotDuration(day.Slots, i)
+		if c.TempC != nil {
+			if low == nil || *c.TempC < *low {
+				low = c.TempC
+			}
+			if high == nil || *c.TempC > *high {
+				high = c.TempC
+			}
+		}
+		wind = max32(wind, c.WindspeedKmph)
+		gust = max32(gust, c.WindGustKmph)
+		if c.ChanceOfRainPercent != nil && (chance == nil || *c.ChanceOfRainPercent > *chance) {
+			chance = c.ChanceOfRainPercent
+		}
+		if c.PrecipM != nil {
+			// PrecipM is a rate per hour, valid until the next slot
+			precip = add32(precip, *c.PrecipM*float32(dur.Hours()))
+		}
+		if c.Code != iface.CodeUnknown {
+			durations[c.Code] += dur
+			if dominant == iface.CodeUnknown || durations[c.Code] > durations[dominant] {
+				dominant = c.Code
+			}
+		}
+		if !sunrise.IsZero() && sunset.After(sunrise) {
+			start, end := c.Time, c.Time.Add(dur)
+			if start.Before(sunrise) {
+				start = sunrise
+			}
+			if end.After(sunset) {
+				end = sunset
+			}
+			var h float32
+			if end.After(start) {
+				h = sunshine[c.Code] * float32(end.Sub(start).Hours())
+			}
+			sun = add32(sun, h)
+		}
+	}
+
+	fill := func(p **float32, v *float32) {
+		if *p == nil {
+			*p = v
+		}
+	}
+	fill(&s.MinTempC, low)
+	fill(&s.MaxTempC, high)
+	fill(&s.PrecipM, precip)
+	fill(&s.MaxWindspeedKmph, wind)
+	fill(&s.MaxWindGustKmph, gust)
+	fill(&s.SunshineHours, sun)
+	if s.MaxChanceOfRainPercent == nil {
+		s.MaxChanceOfRainPercent = chance
+	}
+	if s.Code == iface.CodeUnknown {
+		s.Code = dominant
+	}
+	return s
+}
+
+// slotDuration returns how long the conditions of the i-th slot are valid: up
+// to the next slot, or as long as the previous one for the last slot.
+func slotDuration(slots []iface.Cond, i int) time.Duration {
+	if i+1 < len(slots) {
+		return slots[i+1].Time.Sub(slots[i].Time)
+	} else if i > 0 {
+		return slots[i].Time.Sub(slots[i-1].Time)
+	}
+	return time.Hour
+}
+
+func max32(a, b *float32) *float32 {
+	if a == nil || (b != nil && *b > *a) {
+		return b
+	}
+	return a
+}
+
+func add32(sum *float32, v float32) *float32 {
+	if sum != nil {
+		v += *sum
+	}
+	return &v
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/schachmat/wego/derive"
+	"github.com/schachmat/wego/frontends"
+	"github.com/schachmat/wego/iface"
+)
+
+// codeSeverity orders the weather codes from pleasant to unpleasant, to tell
+// whether a changed forecast is better or worse.
+var codeSeverity = map[iface.WeatherCode]int{
+	iface.CodeSunny:               0,
+	iface.CodePartlyCloudy:        1,
+	iface.CodeCloudy:              2,
+	iface.CodeVeryCloudy:          3,
+	iface.CodeFog:                 3,
+	iface.CodeLightShowers:        4,
+	iface.CodeLightRain:           5,
+	iface.CodeLightSleetShowers:   5,
+	iface.CodeLightSnowShowers:    5,
+	iface.CodeLightSleet:          6,
+	iface.CodeLightSnow:           6,
+	iface.CodeHeavyShowers:        7,
+	iface.CodeHeavySnowShowers:    7,
+	iface.CodeHeavyRain:           8,
+	iface.CodeHeavySnow:           8,
+	iface.CodeThunderyShowers:     9,
+	iface.CodeThunderySnowShowers: 9,
+	iface.CodeThunderyHeavyRain:   10,
+}
+
+const (
+	colorWorse  = "\033[31m"
+	colorBetter = "\033[32m"
+	colorWarmer = "\033[38;5;208m"
+	colorColder = "\033[38;5;39m"
+	colorReset  = "\033[0m"
+)
+
+// signed formats a change rounded to the given number of decimals with its
+// sign, colored with up if it is positive and down if it is negative. It is
+// empty if the rounded change is zero.
+func signed(v float32, decimals int, unit, up, down string) string {
+	s := fmt.Sprintf("%+.*f", decimals, v)
+	if strings.Trim(s, "+-0.") == "" {
+		return ""
+	}
+	color := up
+	if v < 0 {
+		color = down
+	}
+	if unit != "%" {
+		unit = " " + unit
+	}
+	return color + s + unit + colorReset
+}
+
+// codeChange describes the change from the weather code old to new.
+func codeChange(old, new iface.WeatherCode) (string, int) {
+	o, ok1 := codeSeverity[old]
+	n, ok2 := codeSeverity[new]
+	if !ok1 || !ok2 || o == n {
+		return "", 0
+	} else if n > o {
+		return colorWorse + "worse" + colorReset, 1
+	}
+	return colorBetter + "better" + colorReset, -1
+}
+
+// dayDiff prints the changes of the forecast of a day from old, fetched at
+// fetched, to new and rates the overall trend.
+func dayDiff(w io.Writer, old, new iface.Day, fetched time.Time, unit iface.UnitSystem) {
+	before, after := derive.Summary(old), derive.Summary(new)
+	value := func(p *float32, convert func(float32) (float32, string), decimals int) (float32, string) {
+		if p == nil {
+			return 0, "-"
+		}
+		v, u := convert(*p)
+		if u != "%" {
+			u = " " + u
+		}
+		return v, fmt.Sprintf("%.*f%s", decimals, v, u)
+	}
+	row := func(name string, o, n *float32, convert func(float32) (float32, string), decimals int, up, down string) {
+		if o == nil && n == nil {
+			return
+		}
+		ov, otext := value(o, convert, decimals)
+		nv, ntext := value(n, convert, decimals)
+		change := ""
+		if o != nil && n != nil {
+			_, u := convert(*n)
+			change = signed(nv-ov, decimals, u, up, down)
+		}
+		fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", name, otext, ntext, change)
+	}
+	percent := func(p *int) *float32 {
+		if p == nil {
+			return nil
+		}
+		v := float32(*p)
+		return &v
+	}
+	// millimeters unless imperial units are used
+	precip := func(m float32) (float32, string) {
+		if unit == iface.UnitsImperial {
+			return m / 0.0254, "in"
+		}
+		return m * 1000, "mm"
+	}
+
+	fmt.Fprintf(w, "  \t%s\tnow\t\n", fetched.Format("Mon Jan 02 15:04"))
+	change, trend := codeChange(before.Code, after.Code)
+	fmt.Fprintf(w, "  weather\t%s\t%s\t%s\n", frontends.CodeName(before.Code), frontends.CodeName(after.Code), change)
+	row("high", before.MaxTempC, after.MaxTempC, unit.Temp, 0, colorWarmer, colorColder)
+	row("low", before.MinTempC, after.MinTempC, unit.Temp, 0, colorWarmer, colorColder)
+	row("precipitation", before.PrecipM, after.PrecipM, precip, 1, colorWorse, colorBetter)
+	row("rain chance", percent(before.MaxChanceOfRainPercent), percent(after.MaxChanceOfRainPercent),
+		func(v float32) (float32, string) { return v, "%" }, 0, colorWorse, colorBetter)
+	row("wind", before.MaxWindspeedKmph, after.MaxWindspeedKmph, unit.Speed, 0, colorWorse, colorBetter)
+
+	// a millimeter of rain or ten percent chance more are worse
+	rate := func(delta, threshold float32) {
+		if delta >= threshold {
+			trend++
+		} else if delta <= -threshold {
+			trend--
+		}
+	}
+	if before.PrecipM != nil && after.PrecipM != nil {
+		rate(*after.PrecipM-*before.PrecipM, 0.001)
+	}
+	if p, q := before.MaxChanceOfRainPercent, after.MaxChanceOfRainPercent; p != nil && q != nil {
+		rate(float32(*q-*p), 10)
+	}
+
+	// slots of the same time in both forecasts
+	for _, n := range new.Slots {
+		for _, o := range old.Slots {
+			if !o.Time.Equal(n.Time) {
+				continue
+			}
+			var changes []string
+			if o.TempC != nil && n.TempC != nil {
+				ot, _ := unit.Temp(*o.TempC)
+				nt, u := unit.Temp(*n.TempC)
+				changes = append(changes, signed(nt-ot, 0, u, colorWarmer, colorColder))
+			}
+			if o.ChanceOfRainPercent != nil && n.ChanceOfRainPercent != nil {
+				changes = append(changes, signed(float32(*n.ChanceOfRainPercent-*o.ChanceOfRainPercent), 0, "%", colorWorse, colorBetter))
+			}
+			change, _ := codeChange(o.Code, n.Code)
+			changes = append(changes, change)
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", n.Time.Format("15:04"), slotText(o, unit), slotText(n, unit), strings.Join(strings.Fields(strings.Join(changes, " ")), " "))
+		}
+	}
+
+	switch {
+	case trend > 0:
+		fmt.Fprintln(w, colorWorse+"The forecast got worse."+colorReset)
+	case trend < 0:
+		fmt.Fprintln(w, colorBetter+"The forecast got better."+colorReset)
+	default:
+		fmt.Fprintln(w, "The forecast stayed about the same.")
+	}
+}
+
+// slotText describes the temperature, chance of rain and weather of c briefly.
+func slotText(c iface.Cond, unit iface.UnitSystem) string {
+	var parts []string
+	if c.TempC != nil {
+		t, u := unit.Temp(*c.TempC)
+		parts = append(parts, fmt.Sprintf("%d %s", int(math.Round(float64(t))), u))
+	}
+	if c.ChanceOfRainPercent != nil {
+		parts = append(parts, fmt.Sprintf("%d%%", *c.ChanceOfRainPercent))
+	}
+	if name := frontends.CodeName(c.Code); name != "" {
+		parts = append(parts, name)
+	}
+	return strings.Join(parts, " ")
+}
+
+// findDay returns the day of data with the same date as date.
+func findDay(data iface.Data, date time.Time) (iface.Day, bool) {
+	for _, d := range data.Forecast {
+		if d.Date.Format("2006-01-02") == date.Format("2006-01-02") {
+			return d, true
+		}
+	}
+	return iface.Day{}, false
+}
+
+// diff compares the current forecast of a day, tomorrow by default, with the
+// last archived one from before today.
+func (a *app) diff(args []string) error {
+	if *a.date != "" {
+		return fmt.Errorf("diff compares forecasts, it can not be combined with -date")
+	}
+	y, m, d := time.Now().Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+	target := today.AddDate(0, 0, 1)
+	var rest []string
+	for _, arg := range args {
+		if t, err := time.ParseInLocation("2006-01-02", arg, time.Local); err == nil {
+			target = t
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	if target.Before(today) {
+		return fmt.Errorf("The date %s is in the past, diff compares forecasts of today or later", target.Format("2006-01-02"))
+	}
+
+	qs, err := a.queries(rest)
+	if err != nil {
+		return err
+	}
+	numdays := int(math.Round(target.Sub(today).Hours()/24)) + 1
+	for i := range qs {
+		qs[i].numdays = numdays
+	}
+	rs, err := a.fetch(qs)
+	if err != nil {
+		return err
+	}
+
+	out, closeOut, err := a.writer()
+	if err != nil {
+		return err
+	}
+	// the colors are only in the last column, so stripping them keeps the
+	// table aligned
+	w := tabwriter.NewWriter(a.colors(out), 0, 8, 2, ' ', 0)
+	for i, r := range rs {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s on %s\n", r.Location, target.Format("Mon Jan 02"))
+		day, ok := findDay(r, target)
+		if !ok {
+			fmt.Fprintln(w, "No forecast for that day.")
+			continue
+		}
+		entries, err := a.archive.entries(qs[i].backend, qs[i].location)
+		if err != nil {
+			closeOut()
+			return err
+		}
+		var old *cacheEntry
+		for j := range entries {
+			if entries[j].Fetched.Before(today) {
+				if _, ok := findDay(entries[j].Data, target); ok {
+					old = &entries[j]
+				}
+			}
+		}
+		if old == nil {
+			fmt.Fprintln(w, "No archived forecast for that day from before today. Enable -archive to keep the fetched forecasts.")
+			continue
+		}
+		oldDay, _ := findDay(old.Data, target)
+		dayDiff(w, oldDay, day, old.Fetched, a.unit())
+	}
+	if err := w.Flush(); err != nil {
+		closeOut()
+		return err
+	}
+	return closeOut()
+}
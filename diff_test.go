@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestForecastArchive(t *testing.T) {
+	a := &forecastArchive{filepath.Join(t.TempDir(), "archive"), true}
+	for _, loc := range []string{"Paris", "Oslo", "paris"} {
+		if err := a.add(query{loc, "yr", 3}, iface.Data{Location: loc}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := a.entries("yr", "PARIS")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Data.Location != "Paris" || entries[1].Data.Location != "paris" {
+		t.Errorf("entries of Paris = %+v", entries)
+	}
+	if entries, _ := a.entries("smhi", "Paris"); len(entries) != 0 {
+		t.Errorf("got %d entries of another backend", len(entries))
+	}
+}
+
+func TestDayDiff(t *testing.T) {
+	date := time.Date(2024, 7, 20, 0, 0, 0, 0, time.UTC)
+	day := func(tempC float32, chance int, code iface.WeatherCode) iface.Day {
+		return iface.Day{Date: date, Slots: []iface.Cond{{
+			Time:                date.Add(12 * time.Hour),
+			Code:                code,
+			TempC:               &tempC,
+			ChanceOfRainPercent: &chance,
+		}}}
+	}
+
+	tests := []struct {
+		old, new iface.Day
+		want     []string
+	}{
+		{day(27, 10, iface.CodeSunny), day(24, 70, iface.CodeLightRain), []string{"-3 °C", "+60%", "worse", "The forecast got worse."}},
+		{day(20, 80, iface.CodeHeavyRain), day(21, 20, iface.CodePartlyCloudy), []string{"+1 °C", "-60%", "better", "The forecast got better."}},
+		{day(20, 30, iface.CodeCloudy), day(20, 35, iface.CodeCloudy), []string{"+5%", "The forecast stayed about the same."}},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		dayDiff(&b, tt.old, tt.new, date.Add(-24*time.Hour), iface.UnitsMetric)
+		for _, want := range tt.want {
+			if !strings.Contains(b.String(), want) {
+				t.Errorf("diff does not contain %q:\n%s", want, b.String())
+			}
+		}
+	}
+}
+
+func TestDiffColors(t *testing.T) {
+	// the tests do not write to a terminal, so the colors are always stripped
+	for _, output := range []string{"", "diff.txt"} {
+		a := &app{output: &output}
+		var b bytes.Buffer
+		fmt.Fprint(a.colors(&b), "  rain chance\t10%\t30%\t"+signed(20, 0, "%", colorWorse, colorBetter))
+		if want := "  rain chance\t10%\t30%\t+20%"; b.String() != want {
+			t.Errorf("-o %q: got %q, want %q", output, b.String(), want)
+		}
+	}
+}
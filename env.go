@@ -0,0 +1,100 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+const envPrefix = "WEGO_"
+
+// envName returns the environment variable overriding the flag with the given
+// name, for example WEGO_OWM_API_KEY for owm-api-key.
+func envName(flagName string) string {
+	r := strings.NewReplacer("-", "_", ".", "_")
+	return envPrefix + strings.ToUpper(r.Replace(flagName))
+}
+
+// envFlags maps the environment variable names to the flags they override. Of
+// several names for the same flag only the longest one is used, like in the
+// config files.
+func envFlags() map[string]*flag.Flag {
+	longest := make(map[flag.Value]*flag.Flag)
+	flag.VisitAll(func(f *flag.Flag) {
+		if cur, ok := longest[f.Value]; !ok || utf8.RuneCountInString(f.Name) > utf8.RuneCountInString(cur.Name) {
+			longest[f.Value] = f
+		}
+	})
+	ret := make(map[string]*flag.Flag)
+	for _, f := range longest {
+		ret[envName(f.Name)] = f
+	}
+	return ret
+}
+
+// loadEnvironment applies the WEGO_* variables of environ to the flags which
+// were not given on the command line and returns the flags it set. A variable
+// with the suffix _FILE names a file to read the value from, which is meant for
+// secrets like api keys.
+func loadEnvironment(environ []string, cli map[flag.Value]bool) (map[flag.Value]bool, error) {
+	flags := envFlags()
+	var names []string
+	for name := range flags {
+		names = append(names, name)
+	}
+
+	values := make(map[string]string)
+	files := make(map[string]string)
+	for _, kv := range environ {
+		name, val, _ := strings.Cut(kv, "=")
+		if !strings.HasPrefix(name, envPrefix) {
+			continue
+		}
+		if _, ok := flags[name]; ok {
+			values[name] = val
+		} else if base := strings.TrimSuffix(name, "_FILE"); base != name && flags[base] != nil {
+			files[base] = val
+		} else {
+			log.Printf("Ignoring unknown environment variable %s%s", name, suggest(name, names))
+		}
+	}
+
+	var errs []error
+	for name, path := range files {
+		if _, ok := values[name]; ok {
+			errs = append(errs, fmt.Errorf("%s and %s_FILE are both set", name, name))
+			continue
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s_FILE: %v", name, err))
+			continue
+		}
+		// secret files usually end with a newline
+		values[name] = strings.TrimRight(string(b), "\r\n")
+	}
+
+	var set []string
+	for name := range values {
+		set = append(set, name)
+	}
+	sort.Strings(set)
+	ret := make(map[flag.Value]bool)
+	for _, name := range set {
+		f := flags[name]
+		if cli[f.Value] {
+			continue
+		}
+		if err := f.Value.Set(values[name]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid value %q: %v", name, values[name], err))
+			continue
+		}
+		ret[f.Value] = true
+	}
+	return ret, errors.Join(errs...)
+}
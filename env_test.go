@@ -0,0 +1,91 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadEnvironment(t *testing.T) {
+	defer func(old *flag.FlagSet) { flag.CommandLine = old }(flag.CommandLine)
+	flag.CommandLine = flag.NewFlagSet("wego", flag.ContinueOnError)
+
+	backend := flag.String("backend", "openweathermap", "")
+	flag.StringVar(backend, "b", "openweathermap", "")
+	days := flag.Int("days", 3, "")
+	apiKey := flag.String("owm-api-key", "", "")
+	units := flag.String("units", "metric", "")
+
+	secret := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(secret, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	environ := []string{
+		"HOME=/root",
+		"WEGO_BACKEND=smhi",
+		"WEGO_B=ignored",
+		"WEGO_DAYS=5",
+		"WEGO_OWM_API_KEY_FILE=" + secret,
+		"WEGO_UNITS=imperial",
+	}
+	// the units were given on the command line
+	cli := map[flag.Value]bool{flag.Lookup("units").Value: true}
+	set, err := loadEnvironment(environ, cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *backend != "smhi" || *days != 5 || *apiKey != "s3cret" || *units != "metric" {
+		t.Errorf("got backend=%q days=%d api key=%q units=%q", *backend, *days, *apiKey, *units)
+	}
+	if len(set) != 3 || !set[flag.Lookup("backend").Value] || set[flag.Lookup("units").Value] {
+		t.Errorf("got the set flags %v", set)
+	}
+
+	_, err = loadEnvironment([]string{
+		"WEGO_DAYS=many",
+		"WEGO_OWM_API_KEY=a",
+		"WEGO_OWM_API_KEY_FILE=" + secret,
+	}, cli)
+	if err == nil {
+		t.Fatal("invalid environment was accepted")
+	}
+	for _, want := range []string{
+		`WEGO_DAYS: invalid value "many"`,
+		"WEGO_OWM_API_KEY and WEGO_OWM_API_KEY_FILE are both set",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not contain %q", err, want)
+		}
+	}
+}
+
+func TestEnvironmentPrecedesProfile(t *testing.T) {
+	defer func(old *flag.FlagSet) { flag.CommandLine = old }(flag.CommandLine)
+	flag.CommandLine = flag.NewFlagSet("wego", flag.ContinueOnError)
+	backend := flag.String("backend", "openweathermap", "")
+	days := flag.Int("days", 3, "")
+	units := flag.String("units", "metric", "")
+
+	profiles := &presetList{}
+	if err := profiles.Set("sailing=backend=smhi|days=5|units=imperial"); err != nil {
+		t.Fatal(err)
+	}
+	cli := map[flag.Value]bool{flag.Lookup("units").Value: true}
+	*units = "si"
+	env, err := loadEnvironment([]string{"WEGO_BACKEND=yr"}, cli)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := profiles.applyProfile("sailing", cli, env); err != nil {
+		t.Fatal(err)
+	}
+	if *backend != "yr" || *days != 5 || *units != "si" {
+		t.Errorf("got backend=%q days=%d units=%q, want the environment and command line to take precedence", *backend, *days, *units)
+	}
+	if err := profiles.applyProfile("racing", cli, env); err == nil || !strings.Contains(err.Error(), `Could not find profile "racing"`) {
+		t.Errorf("unknown profile gave %v", err)
+	}
+}
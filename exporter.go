@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// locationList collects the locations of a repeated flag.
+type locationList []string
+
+func (l *locationList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *locationList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// exporter serves the weather of its locations as Prometheus metrics. The
+// forecasts are fetched every interval, the lead times are relative to the
+// time of the scrape.
+type exporter struct {
+	a  *app
+	qs []query
+	fe iface.MultiFrontend
+
+	mu      sync.Mutex
+	rs      []iface.Data
+	fetched time.Time
+}
+
+// update fetches the forecasts of all locations.
+func (e *exporter) update() error {
+	rs, err := e.a.fetch(e.qs)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rs, e.fetched = rs, time.Now()
+	return nil
+}
+
+func (e *exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var out bytes.Buffer
+	e.mu.Lock()
+	err := e.fe.RenderMulti(&out, e.rs, e.a.unit())
+	fetched := e.fetched
+	e.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	fmt.Fprintf(&out, "# HELP wego_exporter_last_fetch_timestamp_seconds Time of the last fetch of the forecasts.\n")
+	fmt.Fprintf(&out, "# TYPE wego_exporter_last_fetch_timestamp_seconds gauge\n")
+	fmt.Fprintf(&out, "wego_exporter_last_fetch_timestamp_seconds %d\n", fetched.Unix())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	out.WriteTo(w)
+}
+
+// exporter runs wego as a Prometheus exporter. args are the command line
+// arguments following the exporter subcommand.
+func (a *app) exporter(args []string) error {
+	if *a.date != "" {
+		return fmt.Errorf("The exporter serves forecasts, it can not be combined with -date")
+	}
+	var locations locationList
+	fs := flag.NewFlagSet("exporter", flag.ExitOnError)
+	listen := fs.String("listen", ":9876", "`ADDRESS` to listen on")
+	interval := fs.Duration("interval", 10*time.Minute, "`DURATION` between fetches of the forecasts")
+	fs.Var(&locations, "location", "`LOCATION` to export, may be repeated")
+	fs.Parse(args)
+	if *interval <= 0 {
+		return fmt.Errorf("The interval must be positive, got %v", *interval)
+	}
+
+	qs, err := a.queries(append(locations, fs.Args()...))
+	if err != nil {
+		return err
+	}
+	e := &exporter{a: a, qs: qs, fe: iface.AllFrontends["prometheus"].(iface.MultiFrontend)}
+	if err := e.update(); err != nil {
+		return err
+	}
+	go func() {
+		for range time.Tick(*interval) {
+			if err := e.update(); err != nil {
+				log.Printf("Unable to update the forecasts: %v", err)
+			}
+		}
+	}()
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	log.Printf("Exporting the weather as Prometheus metrics on http://%s/metrics", *listen)
+	return http.ListenAndServe(*listen, mux)
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+type stationBackend struct{}
+
+func (stationBackend) Setup() {}
+func (stationBackend) Fetch(location string, numdays int) (iface.Data, error) {
+	temp, later := float32(21.5), float32(18)
+	now := time.Now()
+	return iface.Data{
+		Location: location,
+		Current:  iface.Cond{Time: now, TempC: &temp},
+		Forecast: []iface.Day{{Date: now, Slots: []iface.Cond{{Time: now.Add(3 * time.Hour), TempC: &later}}}},
+	}, nil
+}
+
+func TestExporter(t *testing.T) {
+	iface.AllBackends["test"] = stationBackend{}
+	defer delete(iface.AllBackends, "test")
+	// the frontend gets its default settings from its flags
+	defer func(old *flag.FlagSet) { flag.CommandLine = old }(flag.CommandLine)
+	flag.CommandLine = flag.NewFlagSet("wego", flag.ContinueOnError)
+	fe := iface.AllFrontends["prometheus"]
+	fe.Setup()
+
+	date, strict, units := "", false, "metric"
+	dir := t.TempDir()
+	a := &app{date: &date, strict: &strict, unitSystem: &units, cache: &diskCache{dir, 0},
+		history: &historyStore{dir, false}, archive: &forecastArchive{dir, false}}
+
+	e := &exporter{a: a, qs: []query{{"Data \"Center\" 1", "test", 1}}, fe: fe.(iface.MultiFrontend)}
+	if err := e.update(); err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("got the content type %q", ct)
+	}
+	for _, want := range []string{
+		"# TYPE wego_temperature_celsius gauge\n",
+		`wego_temperature_celsius{location="Data \"Center\" 1",backend="test",lead_hours="0"} 21.5` + "\n",
+		`wego_temperature_celsius{location="Data \"Center\" 1",backend="test",lead_hours="3"} 18` + "\n",
+		"wego_exporter_last_fetch_timestamp_seconds ",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("the metrics do not contain %q:\n%s", want, rec.Body)
+		}
+	}
+}
+
+func TestExporterFailedUpdate(t *testing.T) {
+	iface.AllBackends["test"] = stationBackend{}
+	defer delete(iface.AllBackends, "test")
+	defer func(old *flag.FlagSet) { flag.CommandLine = old }(flag.CommandLine)
+	flag.CommandLine = flag.NewFlagSet("wego", flag.ContinueOnError)
+	fe := iface.AllFrontends["prometheus"]
+	fe.Setup()
+
+	date, strict, units := "", false, "metric"
+	dir := t.TempDir()
+	a := &app{date: &date, strict: &strict, unitSystem: &units, cache: &diskCache{dir, 0},
+		history: &historyStore{dir, false}, archive: &forecastArchive{dir, false}}
+	e := &exporter{a: a, qs: []query{{"Oslo", "test", 1}}, fe: fe.(iface.MultiFrontend)}
+	if err := e.update(); err != nil {
+		t.Fatal(err)
+	}
+	fetched := e.fetched
+
+	for _, be := range []iface.Backend{failingBackend{}, failingBackend{panics: true}} {
+		iface.AllBackends["test"] = be
+		if err := e.update(); err == nil {
+			t.Errorf("the update with %#v did not fail", be)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+		for _, want := range []string{
+			`wego_temperature_celsius{location="Oslo",backend="test",lead_hours="0"} 21.5` + "\n",
+			fmt.Sprintf("wego_exporter_last_fetch_timestamp_seconds %d\n", fetched.Unix()),
+		} {
+			if !strings.Contains(rec.Body.String(), want) {
+				t.Errorf("after a failed update the metrics do not contain %q:\n%s", want, rec.Body)
+			}
+		}
+	}
+}
@@ -3,9 +3,8 @@ package frontends
 import (
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"math"
-	"os"
 	"regexp"
 	"strings"
 	"time"
@@ -285,7 +284,8 @@ func (c *aatConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret
 
 	icon, ok := codes[cond.Code]
 	if !ok {
-		log.Fatalln("aat-frontend: The following weather code has no icon:", cond.Code)
+		// Render rejects unknown codes, this only guards direct callers
+		icon = codes[iface.CodeUnknown]
 	}
 
 	desc := cond.Desc
@@ -351,9 +351,15 @@ func (c *aatConfig) printDay(day iface.Day) (ret []string) {
 		}
 	}
 
+	weather, other := formatSummary(day, c.unit)
+	summary := " " + aatPad(strings.Join(weather, ", "), 54) + "┌─────────────┐" + " " + aatPad(strings.Join(other, ", "), 54)
 	dateFmt := "┤ " + day.Date.Format("Mon 02. Jan") + " ├"
+	if len(day.Slots) == 0 {
+		// only the summary is known for this day
+		return []string{summary, "└" + strings.Repeat("─", 54) + dateFmt + strings.Repeat("─", 54) + "┘"}
+	}
 	ret = append([]string{
-		"                                                       ┌─────────────┐                                                       ",
+		summary,
 		"┌──────────────────────────────┬───────────────────────" + dateFmt + "───────────────────────┬──────────────────────────────┐",
 		"│           Morning            │             Noon      └──────┬──────┘    Evening            │            Night             │",
 		"├──────────────────────────────┼──────────────────────────────┼──────────────────────────────┼──────────────────────────────┤"},
@@ -367,31 +373,30 @@ func (c *aatConfig) Setup() {
 	flag.BoolVar(&c.monochrome, "aat-monochrome", false, "aat-frontend: Monochrome output")
 }
 
-func (c *aatConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+func (c *aatConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
+	if err := checkCodes(r); err != nil {
+		return fmt.Errorf("aat-frontend: %v", err)
+	}
 	c.unit = unitSystem
 
-	fmt.Printf("Weather for %s%s\n\n", r.Location, c.formatGeo(r.GeoLoc))
-	stdout := colorable.NewColorableStdout()
+	ew := &errWriter{w: w}
+	var stdout io.Writer = ew
 	if c.monochrome {
-		stdout = colorable.NewNonColorable(os.Stdout)
+		stdout = colorable.NewNonColorable(ew)
 	}
+	fmt.Fprintf(stdout, "Weather for %s%s\n\n", r.Location, c.formatGeo(r.GeoLoc))
 
 	out := c.formatCond(make([]string, 5), r.Current, true)
 	for _, val := range out {
 		fmt.Fprintln(stdout, val)
 	}
 
-	if len(r.Forecast) == 0 {
-		return
-	}
-	if r.Forecast == nil {
-		log.Fatal("No detailed weather forecast available.")
-	}
 	for _, d := range r.Forecast {
 		for _, val := range c.printDay(d) {
 			fmt.Fprintln(stdout, val)
 		}
 	}
+	return ew.err
 }
 
 func init() {
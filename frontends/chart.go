@@ -0,0 +1,463 @@
+package frontends
+
+import (
+	"bytes"
+	"encoding/xml"
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+type chartConfig struct {
+	output string
+	format string
+	width  int
+	height int
+	unit   iface.UnitSystem
+}
+
+type chartPoint struct {
+	x, y float64
+}
+
+// chartCanvas is the small set of drawing primitives the meteogram needs. It is
+// implemented once for SVG and once for PNG output, so the plotting code does
+// not have to know which format it is drawing.
+type chartCanvas interface {
+	line(a, b chartPoint, col color.NRGBA, width float64, dashed bool)
+	rect(x, y, w, h float64, col color.NRGBA)
+	polygon(pts []chartPoint, col color.NRGBA)
+	text(p chartPoint, s string, col color.NRGBA, anchor string)
+}
+
+var (
+	chartBlack     = color.NRGBA{0x20, 0x20, 0x20, 0xff}
+	chartGrid      = color.NRGBA{0xc0, 0xc0, 0xc0, 0xff}
+	chartTemp      = color.NRGBA{0xd6, 0x27, 0x28, 0xff}
+	chartFeelsLike = color.NRGBA{0xff, 0x7f, 0x0e, 0xff}
+	chartPrecip    = color.NRGBA{0x1f, 0x77, 0xb4, 0xff}
+	chartWind      = color.NRGBA{0x2c, 0x3e, 0x50, 0xff}
+)
+
+type svgCanvas struct {
+	buf bytes.Buffer
+}
+
+func svgColor(col color.NRGBA) string {
+	return fmt.Sprintf("rgb(%d,%d,%d)", col.R, col.G, col.B)
+}
+
+func (c *svgCanvas) line(a, b chartPoint, col color.NRGBA, width float64, dashed bool) {
+	dash := ""
+	if dashed {
+		dash = ` stroke-dasharray="6,4"`
+	}
+	fmt.Fprintf(&c.buf, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-opacity="%.2f" stroke-width="%.1f"%s/>`+"\n",
+		a.x, a.y, b.x, b.y, svgColor(col), float64(col.A)/255, width, dash)
+}
+
+func (c *svgCanvas) rect(x, y, w, h float64, col color.NRGBA) {
+	fmt.Fprintf(&c.buf, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" fill-opacity="%.2f"/>`+"\n",
+		x, y, w, h, svgColor(col), float64(col.A)/255)
+}
+
+func (c *svgCanvas) polygon(pts []chartPoint, col color.NRGBA) {
+	coords := make([]string, len(pts))
+	for i, p := range pts {
+		coords[i] = fmt.Sprintf("%.1f,%.1f", p.x, p.y)
+	}
+	fmt.Fprintf(&c.buf, `<polygon points="%s" fill="%s" fill-opacity="%.2f"/>`+"\n",
+		strings.Join(coords, " "), svgColor(col), float64(col.A)/255)
+}
+
+func (c *svgCanvas) text(p chartPoint, s string, col color.NRGBA, anchor string) {
+	var esc bytes.Buffer
+	xml.EscapeText(&esc, []byte(s))
+	fmt.Fprintf(&c.buf, `<text x="%.1f" y="%.1f" fill="%s" text-anchor="%s" font-family="sans-serif" font-size="12">%s</text>`+"\n",
+		p.x, p.y, svgColor(col), anchor, esc.String())
+}
+
+type pngCanvas struct {
+	img *image.RGBA
+}
+
+func (c *pngCanvas) blend(x, y int, col color.NRGBA) {
+	if !image.Pt(x, y).In(c.img.Rect) {
+		return
+	}
+	a := uint32(col.A)
+	dst := c.img.RGBAAt(x, y)
+	mix := func(s, d uint8) uint8 {
+		return uint8((uint32(s)*a + uint32(d)*(255-a)) / 255)
+	}
+	c.img.SetRGBA(x, y, color.RGBA{mix(col.R, dst.R), mix(col.G, dst.G), mix(col.B, dst.B), 0xff})
+}
+
+func (c *pngCanvas) line(a, b chartPoint, col color.NRGBA, width float64, dashed bool) {
+	steps := int(math.Max(math.Abs(b.x-a.x), math.Abs(b.y-a.y))) + 1
+	half := int(width / 2)
+	for i := 0; i <= steps; i++ {
+		if dashed && (i/5)%2 == 1 {
+			continue
+		}
+		x := int(math.Round(a.x + (b.x-a.x)*float64(i)/float64(steps)))
+		y := int(math.Round(a.y + (b.y-a.y)*float64(i)/float64(steps)))
+		for dx := -half; dx <= half; dx++ {
+			for dy := -half; dy <= half; dy++ {
+				c.img.SetRGBA(x+dx, y+dy, color.RGBA{col.R, col.G, col.B, 0xff})
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) rect(x, y, w, h float64, col color.NRGBA) {
+	for py := int(math.Round(y)); py < int(math.Round(y+h)); py++ {
+		for px := int(math.Round(x)); px < int(math.Round(x+w)); px++ {
+			c.blend(px, py, col)
+		}
+	}
+}
+
+func (c *pngCanvas) polygon(pts []chartPoint, col color.NRGBA) {
+	if len(pts) < 3 {
+		return
+	}
+	minY, maxY := pts[0].y, pts[0].y
+	for _, p := range pts {
+		minY, maxY = math.Min(minY, p.y), math.Max(maxY, p.y)
+	}
+	// even-odd scanline fill
+	for py := int(math.Floor(minY)); py <= int(math.Ceil(maxY)); py++ {
+		sy := float64(py) + 0.5
+		var xs []float64
+		for i := range pts {
+			a, b := pts[i], pts[(i+1)%len(pts)]
+			if (a.y <= sy && b.y > sy) || (b.y <= sy && a.y > sy) {
+				xs = append(xs, a.x+(sy-a.y)/(b.y-a.y)*(b.x-a.x))
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			for px := int(math.Round(xs[i])); px < int(math.Round(xs[i+1])); px++ {
+				c.blend(px, py, col)
+			}
+		}
+	}
+}
+
+func (c *pngCanvas) text(p chartPoint, s string, col color.NRGBA, anchor string) {
+	// the builtin bitmap font only covers ASCII
+	s = strings.ReplaceAll(s, "°", "")
+	d := &font.Drawer{
+		Dst:  c.img,
+		Src:  image.NewUniform(col),
+		Face: basicfont.Face7x13,
+	}
+	x := p.x
+	if w := float64(d.MeasureString(s).Round()); anchor == "middle" {
+		x -= w / 2
+	} else if anchor == "end" {
+		x -= w
+	}
+	d.Dot = fixed.P(int(math.Round(x)), int(math.Round(p.y)))
+	d.DrawString(s)
+}
+
+// drawWindBarb draws a standard meteorological wind barb with its staff
+// pointing into the direction the wind is blowing from. Pennants are 50, full
+// barbs 10 and half barbs 5 knots.
+func drawWindBarb(cv chartCanvas, at chartPoint, dirDeg int, kmph float32) {
+	knots := int(math.Round(float64(kmph)/1.852/5)) * 5
+	if knots < 5 {
+		var circle []chartPoint
+		for i := 0; i <= 12; i++ {
+			a := float64(i) * math.Pi / 6
+			circle = append(circle, chartPoint{at.x + 4*math.Cos(a), at.y + 4*math.Sin(a)})
+		}
+		for i := 1; i < len(circle); i++ {
+			cv.line(circle[i-1], circle[i], chartWind, 1, false)
+		}
+		return
+	}
+
+	rad := float64(dirDeg) * math.Pi / 180
+	s := chartPoint{math.Sin(rad), -math.Cos(rad)} // along the staff, outwards
+	p := chartPoint{math.Cos(rad), math.Sin(rad)}  // perpendicular, barb side
+	const staff = 22.0
+	tip := chartPoint{at.x + s.x*staff, at.y + s.y*staff}
+	cv.line(at, tip, chartWind, 1.5, false)
+
+	pos := tip
+	if knots < 10 {
+		// a lone half barb is set off from the end of the staff
+		pos = chartPoint{pos.x - s.x*4, pos.y - s.y*4}
+	}
+	for ; knots >= 50; knots -= 50 {
+		base := chartPoint{pos.x - s.x*6, pos.y - s.y*6}
+		apex := chartPoint{pos.x + p.x*9, pos.y + p.y*9}
+		cv.polygon([]chartPoint{pos, apex, base}, chartWind)
+		pos = chartPoint{base.x - s.x*2, base.y - s.y*2}
+	}
+	for ; knots >= 10; knots -= 10 {
+		end := chartPoint{pos.x + p.x*9 + s.x*3, pos.y + p.y*9 + s.y*3}
+		cv.line(pos, end, chartWind, 1.5, false)
+		pos = chartPoint{pos.x - s.x*4, pos.y - s.y*4}
+	}
+	if knots >= 5 {
+		end := chartPoint{pos.x + p.x*5 + s.x*1.5, pos.y + p.y*5 + s.y*1.5}
+		cv.line(pos, end, chartWind, 1.5, false)
+	}
+}
+
+// precip converts a precipitation rate to the bar unit of the unit system.
+// Bars use a fixed unit so their heights are comparable along the chart.
+func (c *chartConfig) precip(precipM float32) (float64, string) {
+	if c.unit == iface.UnitsImperial {
+		return float64(precipM / 0.0254), "in/h"
+	}
+	return float64(precipM * 1000), "mm/h"
+}
+
+func (c *chartConfig) plot(cv chartCanvas, r iface.Data, slots []iface.Cond) {
+	w, h := float64(c.width), float64(c.height)
+	left, right, top, bottom := 55.0, w-55, 45.0, h-35
+	plotH := bottom - top
+	tempTop, tempBottom := top, top+plotH*0.55
+	precipTop, precipBottom := tempBottom+10, top+plotH*0.82
+	windY := (precipBottom + bottom) / 2
+
+	t0, t1 := slots[0].Time, slots[len(slots)-1].Time
+	span := t1.Sub(t0)
+	if span <= 0 {
+		span = time.Hour
+	}
+	xOf := func(t time.Time) float64 {
+		return left + float64(t.Sub(t0))/float64(span)*(right-left)
+	}
+	slotW := (right - left) / float64(len(slots))
+
+	cv.rect(0, 0, w, h, color.NRGBA{0xff, 0xff, 0xff, 0xff})
+	cv.text(chartPoint{left, 22}, "Weather for "+r.Location, chartBlack, "start")
+
+	// temperature scale
+	minT, maxT := math.Inf(1), math.Inf(-1)
+	for _, s := range slots {
+		for _, v := range []*float32{s.TempC, s.FeelsLikeC} {
+			if v != nil {
+				t, _ := c.unit.Temp(*v)
+				minT, maxT = math.Min(minT, float64(t)), math.Max(maxT, float64(t))
+			}
+		}
+	}
+	if math.IsInf(minT, 1) {
+		minT, maxT = 0, 1
+	}
+	minT, maxT = math.Floor(minT-1), math.Ceil(maxT+1)
+	yOfT := func(t float64) float64 {
+		return tempBottom - (t-minT)/(maxT-minT)*(tempBottom-tempTop)
+	}
+	_, tUnit := c.unit.Temp(0)
+	step := math.Max(1, math.Ceil((maxT-minT)/6))
+	for t := math.Ceil(minT/step) * step; t <= maxT; t += step {
+		y := yOfT(t)
+		cv.line(chartPoint{left, y}, chartPoint{right, y}, chartGrid, 0.5, false)
+		cv.text(chartPoint{left - 6, y + 4}, fmt.Sprintf("%.0f%s", t, tUnit), chartBlack, "end")
+	}
+
+	// precipitation scale
+	_, pUnit := c.precip(0)
+	maxP := 1.0
+	if c.unit == iface.UnitsImperial {
+		maxP = 0.04
+	}
+	for _, s := range slots {
+		if s.PrecipM != nil {
+			v, _ := c.precip(*s.PrecipM)
+			maxP = math.Max(maxP, v)
+		}
+	}
+	yOfP := func(p float64) float64 {
+		return precipBottom - p/maxP*(precipBottom-precipTop)
+	}
+	cv.line(chartPoint{left, precipBottom}, chartPoint{right, precipBottom}, chartGrid, 1, false)
+	cv.text(chartPoint{right + 6, precipTop + 10}, fmt.Sprintf("%.1f", maxP), chartPrecip, "start")
+	cv.text(chartPoint{right + 6, precipTop + 24}, pUnit, chartPrecip, "start")
+
+	// day separators and time ticks
+	for d := time.Date(t0.Year(), t0.Month(), t0.Day(), 0, 0, 0, 0, t0.Location()); !d.After(t1); d = d.AddDate(0, 0, 1) {
+		x := math.Max(left, xOf(d))
+		if !d.Before(t0) {
+			cv.line(chartPoint{x, top}, chartPoint{x, bottom}, chartGrid, 1, false)
+		}
+		if x < right-40 {
+			cv.text(chartPoint{x + 4, top - 6}, d.Format("Mon 02. Jan"), chartBlack, "start")
+		}
+		for hour := 6; hour < 24; hour += 6 {
+			tick := d.Add(time.Duration(hour) * time.Hour)
+			if tick.Before(t0) || tick.After(t1) {
+				continue
+			}
+			x := xOf(tick)
+			cv.line(chartPoint{x, bottom}, chartPoint{x, bottom + 4}, chartBlack, 1, false)
+			cv.text(chartPoint{x, bottom + 17}, tick.Format("15h"), chartBlack, "middle")
+		}
+	}
+
+	// precipitation bars, shaded by their probability
+	for _, s := range slots {
+		x := xOf(s.Time) - slotW*0.35
+		if s.ChanceOfRainPercent != nil {
+			shade := chartPrecip
+			shade.A = uint8(float64(*s.ChanceOfRainPercent) / 100 * 0x40)
+			cv.rect(x, precipTop, slotW*0.7, precipBottom-precipTop, shade)
+		}
+		if s.PrecipM == nil || *s.PrecipM <= 0 {
+			continue
+		}
+		v, _ := c.precip(*s.PrecipM)
+		bar := chartPrecip
+		if s.ChanceOfRainPercent != nil {
+			bar.A = uint8(0x50 + float64(*s.ChanceOfRainPercent)/100*0xaf)
+		}
+		cv.rect(x, yOfP(v), slotW*0.7, precipBottom-yOfP(v), bar)
+	}
+
+	// temperature and felt temperature lines
+	for i := 1; i < len(slots); i++ {
+		a, b := slots[i-1], slots[i]
+		if a.FeelsLikeC != nil && b.FeelsLikeC != nil {
+			ta, _ := c.unit.Temp(*a.FeelsLikeC)
+			tb, _ := c.unit.Temp(*b.FeelsLikeC)
+			cv.line(chartPoint{xOf(a.Time), yOfT(float64(ta))}, chartPoint{xOf(b.Time), yOfT(float64(tb))}, chartFeelsLike, 1.5, true)
+		}
+		if a.TempC != nil && b.TempC != nil {
+			ta, _ := c.unit.Temp(*a.TempC)
+			tb, _ := c.unit.Temp(*b.TempC)
+			cv.line(chartPoint{xOf(a.Time), yOfT(float64(ta))}, chartPoint{xOf(b.Time), yOfT(float64(tb))}, chartTemp, 2, false)
+		}
+	}
+
+	// wind barbs, thinned out so they do not overlap
+	lastX := math.Inf(-1)
+	for _, s := range slots {
+		x := xOf(s.Time)
+		if s.WindspeedKmph == nil || s.WinddirDegree == nil || x-lastX < 30 {
+			continue
+		}
+		drawWindBarb(cv, chartPoint{x, windY}, *s.WinddirDegree, *s.WindspeedKmph)
+		lastX = x
+	}
+
+	// legend
+	lx := right - 300
+	cv.line(chartPoint{lx, 18}, chartPoint{lx + 20, 18}, chartTemp, 2, false)
+	cv.text(chartPoint{lx + 24, 22}, "temp", chartBlack, "start")
+	cv.line(chartPoint{lx + 70, 18}, chartPoint{lx + 90, 18}, chartFeelsLike, 1.5, true)
+	cv.text(chartPoint{lx + 94, 22}, "feels like", chartBlack, "start")
+	cv.rect(lx+170, 12, 10, 10, chartPrecip)
+	cv.text(chartPoint{lx + 184, 22}, "precip", chartBlack, "start")
+	cv.text(chartPoint{lx + 240, 22}, "wind kn", chartWind, "start")
+}
+
+func (c *chartConfig) Setup() {
+	flag.StringVar(&c.output, "chart-output", "wego.svg", "chart frontend: `FILE` to write the meteogram to, - writes it to the output of wego. With several locations, the location is appended to the file name")
+	flag.StringVar(&c.format, "chart-format", "", "chart frontend: image `FORMAT` (svg or png), derived from the output file name if empty, svg if that has no extension")
+	flag.IntVar(&c.width, "chart-width", 1000, "chart frontend: image width in `PIXELS`")
+	flag.IntVar(&c.height, "chart-height", 500, "chart frontend: image height in `PIXELS`")
+}
+
+// render draws the meteogram of r in the configured format.
+func (c *chartConfig) render(r iface.Data) ([]byte, error) {
+	var slots []iface.Cond
+	for _, d := range r.Forecast {
+		slots = append(slots, d.Slots...)
+	}
+	sort.SliceStable(slots, func(i, j int) bool { return slots[i].Time.Before(slots[j].Time) })
+	if len(slots) < 2 {
+		return nil, fmt.Errorf("chart-frontend: Not enough forecast data to draw a meteogram")
+	}
+
+	format := strings.ToLower(c.format)
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(c.output)), ".")
+	}
+	if format == "" {
+		format = "svg"
+	}
+
+	switch format {
+	case "png":
+		cv := &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, c.width, c.height))}
+		c.plot(cv, r, slots)
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, cv.img); err != nil {
+			return nil, fmt.Errorf("chart-frontend: Unable to encode png: %v", err)
+		}
+		return buf.Bytes(), nil
+	case "svg":
+		cv := &svgCanvas{}
+		fmt.Fprintf(&cv.buf, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d">`+"\n",
+			c.width, c.height, c.width, c.height)
+		c.plot(cv, r, slots)
+		cv.buf.WriteString("</svg>\n")
+		return cv.buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("chart-frontend: Unknown image format %q, use svg or png", format)
+}
+
+// write renders the meteogram of r to file, or to w if file is -.
+func (c *chartConfig) write(w io.Writer, r iface.Data, file string) error {
+	out, err := c.render(r)
+	if err != nil {
+		return err
+	}
+	if file == "-" {
+		_, err := w.Write(out)
+		return err
+	}
+	if err := os.WriteFile(file, out, 0644); err != nil {
+		return fmt.Errorf("chart-frontend: Unable to write meteogram: %v", err)
+	}
+	_, err = fmt.Fprintf(w, "Meteogram for %s written to %s\n", r.Location, file)
+	return err
+}
+
+func (c *chartConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
+	c.unit = unitSystem
+	return c.write(w, r, c.output)
+}
+
+// RenderMulti writes the meteogram of every location to its own file, named
+// like the output file with the location appended, as in wego-oslo.svg.
+func (c *chartConfig) RenderMulti(w io.Writer, rs []iface.Data, unitSystem iface.UnitSystem) error {
+	if c.output == "-" {
+		return fmt.Errorf("chart-frontend: Only one meteogram can be written to the output, set -chart-output to a file for several locations")
+	}
+	c.unit = unitSystem
+	ext := filepath.Ext(c.output)
+	for _, r := range rs {
+		file := strings.TrimSuffix(c.output, ext) + "-" + locationSlug(r.Location) + ext
+		if err := c.write(w, r, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func init() {
+	iface.AllFrontends["chart"] = &chartConfig{}
+}
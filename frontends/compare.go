@@ -0,0 +1,149 @@
+package frontends
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-colorable"
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/schachmat/wego/derive"
+	"github.com/schachmat/wego/iface"
+)
+
+type compareConfig struct {
+	monochrome bool
+	unit       iface.UnitSystem
+}
+
+const compareCellWidth = 22
+
+func (c *compareConfig) formatTemp(temp float32) string {
+	t, _ := c.unit.Temp(temp)
+	return graphColorize(fmt.Sprintf("%d", int(math.Round(float64(t)))), graphTempColor(temp))
+}
+
+// formatDay summarizes a day in one table cell: the condition around noon, the
+// highest and lowest temperature and the total amount of precipitation.
+func (c *compareConfig) formatDay(day iface.Day) string {
+	if len(day.Slots) == 0 && day.Summary == nil {
+		return aatPad("", compareCellWidth)
+	}
+
+	// days without slots show the prevailing condition of their summary
+	summary := derive.Summary(day)
+	code, closest := summary.Code, time.Duration(0)
+	// the slot closest to noon in the time zone of the location
+	y, m, d := day.Date.Date()
+	noon := time.Date(y, m, d, 12, 0, 0, 0, day.Date.Location())
+	for i, s := range day.Slots {
+		if i == 0 || s.Time.Sub(noon).Abs() < closest {
+			code, closest = s.Code, s.Time.Sub(noon).Abs()
+		}
+	}
+	icon := emojiIcon(code)
+
+	temp := "?"
+	if summary.MaxTempC != nil || summary.MinTempC != nil {
+		high, low := "?", "?"
+		if summary.MaxTempC != nil {
+			high = c.formatTemp(*summary.MaxTempC)
+		}
+		if summary.MinTempC != nil {
+			low = c.formatTemp(*summary.MinTempC)
+		}
+		_, u := c.unit.Temp(0.0)
+		temp = fmt.Sprintf("%s/%s %s", high, low, u)
+	}
+	var precipM float32
+	if summary.PrecipM != nil {
+		precipM = *summary.PrecipM
+	}
+	v, u := c.unit.Distance(precipM)
+	return aatPad(fmt.Sprintf(" %s %s %.1f %s", icon, temp, v, u), compareCellWidth)
+}
+
+func (c *compareConfig) Setup() {
+	flag.BoolVar(&c.monochrome, "compare-monochrome", false, "compare-frontend: Monochrome output")
+}
+
+func (c *compareConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
+	return c.RenderMulti(w, []iface.Data{r}, unitSystem)
+}
+
+// RenderMulti prints a table with one row per location and one column per day.
+// The days are aligned by their index, the column headers show the dates of
+// the first location.
+func (c *compareConfig) RenderMulti(w io.Writer, rs []iface.Data, unitSystem iface.UnitSystem) error {
+	for _, r := range rs {
+		if err := checkCodes(r); err != nil {
+			return fmt.Errorf("compare-frontend: %s: %v", r.Location, err)
+		}
+	}
+	c.unit = unitSystem
+
+	ew := &errWriter{w: w}
+	var stdout io.Writer = ew
+	if c.monochrome {
+		stdout = colorable.NewNonColorable(ew)
+	}
+
+	locWidth, numdays := len("Location"), 0
+	for _, r := range rs {
+		if l := runewidth.StringWidth(r.Location); l > locWidth {
+			locWidth = l
+		}
+		if len(r.Forecast) > numdays {
+			numdays = len(r.Forecast)
+		}
+	}
+	if locWidth > 30 {
+		locWidth = 30
+	}
+
+	border := func(left, mid, right string) string {
+		ret := left + strings.Repeat("─", locWidth+2)
+		for i := 0; i < numdays; i++ {
+			ret += mid + strings.Repeat("─", compareCellWidth)
+		}
+		return ret + right
+	}
+
+	header := "│ " + runewidth.FillRight("Location", locWidth) + " │"
+	for i := 0; i < numdays; i++ {
+		date := ""
+		for _, r := range rs {
+			if i < len(r.Forecast) {
+				date = r.Forecast[i].Date.Format("Mon Jan 02")
+				break
+			}
+		}
+		header += aatPad(" "+date, compareCellWidth) + "│"
+	}
+
+	fmt.Fprintln(stdout, border("┌", "┬", "┐"))
+	fmt.Fprintln(stdout, header)
+	fmt.Fprintln(stdout, border("├", "┼", "┤"))
+	for _, r := range rs {
+		loc := runewidth.Truncate(runewidth.FillRight(r.Location, locWidth), locWidth, "…")
+		row := "│ " + loc + " │"
+		for i := 0; i < numdays; i++ {
+			if i < len(r.Forecast) {
+				row += c.formatDay(r.Forecast[i])
+			} else {
+				row += aatPad("", compareCellWidth)
+			}
+			row += "│"
+		}
+		fmt.Fprintln(stdout, row)
+	}
+	fmt.Fprintln(stdout, border("└", "┴", "┘"))
+	return ew.err
+}
+
+func init() {
+	iface.AllFrontends["compare"] = &compareConfig{}
+}
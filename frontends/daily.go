@@ -0,0 +1,112 @@
+package frontends
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	runewidth "github.com/mattn/go-runewidth"
+	"github.com/schachmat/wego/derive"
+	"github.com/schachmat/wego/iface"
+)
+
+// dailyConfig prints one line per day from the daily summaries, which is
+// compact enough for long-range forecasts of one to two weeks.
+type dailyConfig struct {
+	unit iface.UnitSystem
+}
+
+// dailyColumns are the titles and widths of the columns.
+var dailyColumns = []struct {
+	title string
+	width int
+}{
+	{"Date", 13}, {"Weather", 23}, {"Temperature", 14}, {"Precipitation", 16}, {"Wind (gusts)", 16}, {"Sunshine", 8},
+}
+
+func (c *dailyConfig) formatTemp(low, high *float32) string {
+	if low == nil || high == nil {
+		return ""
+	}
+	l, u := c.unit.Temp(*low)
+	h, _ := c.unit.Temp(*high)
+	return fmt.Sprintf("%d – %d %s", int(math.Round(float64(l))), int(math.Round(float64(h))), u)
+}
+
+func (c *dailyConfig) formatPrecip(s iface.Summary) string {
+	ret := ""
+	if s.PrecipM != nil {
+		v, u := c.unit.Distance(*s.PrecipM)
+		ret = fmt.Sprintf("%.1f %s", v, u)
+	}
+	if s.MaxChanceOfRainPercent != nil {
+		ret += fmt.Sprintf(" %d%%", *s.MaxChanceOfRainPercent)
+	}
+	return ret
+}
+
+func (c *dailyConfig) formatWind(s iface.Summary) string {
+	if s.MaxWindspeedKmph == nil {
+		return ""
+	}
+	v, u := c.unit.Speed(*s.MaxWindspeedKmph)
+	if s.MaxWindGustKmph != nil {
+		g, _ := c.unit.Speed(*s.MaxWindGustKmph)
+		return fmt.Sprintf("%d (%d) %s", int(math.Round(float64(v))), int(math.Round(float64(g))), u)
+	}
+	return fmt.Sprintf("%d %s", int(math.Round(float64(v))), u)
+}
+
+func (c *dailyConfig) printDay(day iface.Day) string {
+	s := derive.Summary(day)
+	sun := ""
+	if s.SunshineHours != nil {
+		sun = fmt.Sprintf("%.1f h", *s.SunshineHours)
+	}
+	cells := []string{
+		day.Date.Format("Mon 02. Jan"),
+		codeNames[s.Code],
+		c.formatTemp(s.MinTempC, s.MaxTempC),
+		c.formatPrecip(s),
+		c.formatWind(s),
+		sun,
+	}
+	return c.printRow(cells)
+}
+
+func (c *dailyConfig) printRow(cells []string) (ret string) {
+	for i, cell := range cells {
+		if i == len(cells)-1 {
+			return strings.TrimRight(ret+cell, " ")
+		}
+		ret += runewidth.FillRight(cell, dailyColumns[i].width)
+	}
+	return
+}
+
+func (c *dailyConfig) Setup() {
+}
+
+func (c *dailyConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
+	if err := checkCodes(r); err != nil {
+		return fmt.Errorf("daily-frontend: %v", err)
+	}
+	c.unit = unitSystem
+
+	ew := &errWriter{w: w}
+	fmt.Fprintf(ew, "Weather for %s\n\n", r.Location)
+	titles := make([]string, len(dailyColumns))
+	for i, col := range dailyColumns {
+		titles[i] = col.title
+	}
+	fmt.Fprintln(ew, c.printRow(titles))
+	for _, d := range r.Forecast {
+		fmt.Fprintln(ew, c.printDay(d))
+	}
+	return ew.err
+}
+
+func init() {
+	iface.AllFrontends["daily"] = &dailyConfig{}
+}
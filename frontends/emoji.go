@@ -2,11 +2,11 @@ package frontends
 
 import (
 	"fmt"
-	"log"
+	"io"
 	"math"
+	"strings"
 	"time"
 
-	colorable "github.com/mattn/go-colorable"
 	runewidth "github.com/mattn/go-runewidth"
 	"github.com/schachmat/wego/iface"
 )
@@ -52,36 +52,45 @@ func (c *emojiConfig) formatTemp(cond iface.Cond) string {
 	return aatPad(fmt.Sprintf("%s %s", color(t), u), 12)
 }
 
-func (c *emojiConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret []string) {
-	codes := map[iface.WeatherCode]string{
-		iface.CodeUnknown:             "✨",
-		iface.CodeCloudy:              "☁️",
-		iface.CodeFog:                 "🌫",
-		iface.CodeHeavyRain:           "🌧",
-		iface.CodeHeavyShowers:        "🌧",
-		iface.CodeHeavySnow:           "❄️",
-		iface.CodeHeavySnowShowers:    "❄️",
-		iface.CodeLightRain:           "🌦",
-		iface.CodeLightShowers:        "🌦",
-		iface.CodeLightSleet:          "🌧",
-		iface.CodeLightSleetShowers:   "🌧",
-		iface.CodeLightSnow:           "🌨",
-		iface.CodeLightSnowShowers:    "🌨",
-		iface.CodePartlyCloudy:        "⛅️",
-		iface.CodeSunny:               "☀️",
-		iface.CodeThunderyHeavyRain:   "🌩",
-		iface.CodeThunderyShowers:     "⛈",
-		iface.CodeThunderySnowShowers: "⛈",
-		iface.CodeVeryCloudy:          "☁️",
-	}
-
-	icon, ok := codes[cond.Code]
+// emojiCodes are the icons of the weather codes, shared with the compare
+// frontend.
+var emojiCodes = map[iface.WeatherCode]string{
+	iface.CodeUnknown:             "✨",
+	iface.CodeCloudy:              "☁️",
+	iface.CodeFog:                 "🌫",
+	iface.CodeHeavyRain:           "🌧",
+	iface.CodeHeavyShowers:        "🌧",
+	iface.CodeHeavySnow:           "❄️",
+	iface.CodeHeavySnowShowers:    "❄️",
+	iface.CodeLightRain:           "🌦",
+	iface.CodeLightShowers:        "🌦",
+	iface.CodeLightSleet:          "🌧",
+	iface.CodeLightSleetShowers:   "🌧",
+	iface.CodeLightSnow:           "🌨",
+	iface.CodeLightSnowShowers:    "🌨",
+	iface.CodePartlyCloudy:        "⛅️",
+	iface.CodeSunny:               "☀️",
+	iface.CodeThunderyHeavyRain:   "🌩",
+	iface.CodeThunderyShowers:     "⛈",
+	iface.CodeThunderySnowShowers: "⛈",
+	iface.CodeVeryCloudy:          "☁️",
+}
+
+// emojiIcon returns the icon of code, padded to two columns.
+func emojiIcon(code iface.WeatherCode) string {
+	icon, ok := emojiCodes[code]
 	if !ok {
-		log.Fatalln("emoji-frontend: The following weather code has no icon:", cond.Code)
+		// Render rejects unknown codes, this only guards direct callers
+		icon = emojiCodes[iface.CodeUnknown]
 	}
 	if runewidth.StringWidth(icon) == 1 {
 		icon += " "
 	}
+	return icon
+}
+
+func (c *emojiConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret []string) {
+	icon := emojiIcon(cond.Code)
 
 	desc := cond.Desc
 	if !current {
@@ -93,7 +102,7 @@ func (c *emojiConfig) formatCond(cur []string, cond iface.Cond, current bool) (r
 	return
 }
 
-func (c *emojiConfig) printAstro(astro iface.Astro) {
+func (c *emojiConfig) printAstro(w io.Writer, astro iface.Astro) {
         // print sun astronomy data if present
 	if astro.Sunrise != astro.Sunset {
 	    // half the distance between sunrise and sunset
@@ -102,15 +111,15 @@ func (c *emojiConfig) printAstro(astro iface.Astro) {
 	    noon := astro.Sunrise.Add(noon_distance)
 
 	    // the actual print statement
-	    fmt.Printf("🌞 rise↗ %s noon↑ %s set↘ %s\n", astro.Sunrise.Format(time.Kitchen), noon.Format(time.Kitchen), astro.Sunset.Format(time.Kitchen))
+	    fmt.Fprintf(w, "🌞 rise↗ %s noon↑ %s set↘ %s\n", astro.Sunrise.Format(time.Kitchen), noon.Format(time.Kitchen), astro.Sunset.Format(time.Kitchen))
 	}
         // print moon astronomy data if present
 	if astro.Moonrise != astro.Moonset {
-	    fmt.Printf("🌚 rise↗ %s set↘ %s\n", astro.Moonrise.Format(time.Kitchen), astro.Moonset)
+	    fmt.Fprintf(w, "🌚 rise↗ %s set↘ %s\n", astro.Moonrise.Format(time.Kitchen), astro.Moonset)
 	}
 }
 
-func (c *emojiConfig) printDay(day iface.Day) (ret []string) {
+func (c *emojiConfig) printDay(w io.Writer, day iface.Day) (ret []string) {
 	desiredTimesOfDay := []time.Duration{
 		8 * time.Hour,
 		12 * time.Hour,
@@ -122,7 +131,13 @@ func (c *emojiConfig) printDay(day iface.Day) (ret []string) {
 		ret[i] = "│"
 	}
 
-	c.printAstro(day.Astronomy)
+	c.printAstro(w, day.Astronomy)
+
+	if len(day.Slots) == 0 {
+		// only the summary is known for this day
+		weather, _ := formatSummary(day, c.unit)
+		return []string{"┤  " + day.Date.Format("Mon") + "  ├ " + strings.Join(weather, ", "), " "}
+	}
 
 	// save our selected elements from day.Slots in this array
 	cols := make([]iface.Cond, len(desiredTimesOfDay))
@@ -159,11 +174,14 @@ func (c *emojiConfig) printDay(day iface.Day) (ret []string) {
 func (c *emojiConfig) Setup() {
 }
 
-func (c *emojiConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+func (c *emojiConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
+	if err := checkCodes(r); err != nil {
+		return fmt.Errorf("emoji-frontend: %v", err)
+	}
 	c.unit = unitSystem
 
-	fmt.Printf("Weather for %s\n\n", r.Location)
-	stdout := colorable.NewColorableStdout()
+	stdout := &errWriter{w: w}
+	fmt.Fprintf(stdout, "Weather for %s\n\n", r.Location)
 
 	out := c.formatCond(make([]string, 5), r.Current, true)
 	for _, val := range out {
@@ -171,17 +189,15 @@ func (c *emojiConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
 	}
 
 	if len(r.Forecast) == 0 {
-		return
-	}
-	if r.Forecast == nil {
-		log.Fatal("No detailed weather forecast available.")
+		return stdout.err
 	}
-	fmt.Printf("\n")
+	fmt.Fprintf(stdout, "\n")
 	for _, d := range r.Forecast {
-		for _, val := range c.printDay(d) {
+		for _, val := range c.printDay(stdout, d) {
 			fmt.Fprintln(stdout, val)
 		}
 	}
+	return stdout.err
 }
 
 func init() {
@@ -0,0 +1,171 @@
+package frontends
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/schachmat/wego/derive"
+	"github.com/schachmat/wego/iface"
+)
+
+// errWriter remembers the first error of the underlying writer, so frontends
+// can print line by line and check for failure once at the end.
+type errWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (e *errWriter) Write(p []byte) (int, error) {
+	if e.err != nil {
+		return 0, e.err
+	}
+	n, err := e.w.Write(p)
+	e.err = err
+	return n, err
+}
+
+// checkCodes returns an error if any condition in r has a weather code which is
+// not one of the iface.WeatherCode constants.
+func checkCodes(r iface.Data) error {
+	check := func(cond iface.Cond) error {
+		if cond.Code < iface.CodeUnknown || cond.Code > iface.CodeVeryCloudy {
+			return fmt.Errorf("the following weather code has no icon: %d", cond.Code)
+		}
+		return nil
+	}
+	if err := check(r.Current); err != nil {
+		return err
+	}
+	for _, d := range r.Forecast {
+		if d.Summary != nil {
+			if err := check(iface.Cond{Code: d.Summary.Code}); err != nil {
+				return err
+			}
+		}
+		for _, s := range d.Slots {
+			if err := check(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+var codeNames = map[iface.WeatherCode]string{
+	iface.CodeCloudy:              "Cloudy",
+	iface.CodeFog:                 "Fog",
+	iface.CodeHeavyRain:           "Heavy rain",
+	iface.CodeHeavyShowers:        "Heavy showers",
+	iface.CodeHeavySnow:           "Heavy snow",
+	iface.CodeHeavySnowShowers:    "Heavy snow showers",
+	iface.CodeLightRain:           "Light rain",
+	iface.CodeLightShowers:        "Light showers",
+	iface.CodeLightSleet:          "Light sleet",
+	iface.CodeLightSleetShowers:   "Light sleet showers",
+	iface.CodeLightSnow:           "Light snow",
+	iface.CodeLightSnowShowers:    "Light snow showers",
+	iface.CodePartlyCloudy:        "Partly cloudy",
+	iface.CodeSunny:               "Sunny",
+	iface.CodeThunderyHeavyRain:   "Thundery heavy rain",
+	iface.CodeThunderyShowers:     "Thundery showers",
+	iface.CodeThunderySnowShowers: "Thundery snow showers",
+	iface.CodeVeryCloudy:          "Very cloudy",
+}
+
+// CodeName returns the English name of code, or an empty string if it is
+// unknown.
+func CodeName(code iface.WeatherCode) string {
+	return codeNames[code]
+}
+
+// formatSummary describes the summary of day in the unit system. The first
+// slice contains the weather, the temperature and the precipitation, the
+// second one the wind and the sunshine. Unknown values are left out.
+func formatSummary(day iface.Day, unit iface.UnitSystem) (weather, other []string) {
+	s := derive.Summary(day)
+	if name, ok := codeNames[s.Code]; ok {
+		weather = append(weather, name)
+	}
+	if s.MinTempC != nil && s.MaxTempC != nil {
+		low, u := unit.Temp(*s.MinTempC)
+		high, _ := unit.Temp(*s.MaxTempC)
+		weather = append(weather, fmt.Sprintf("%d – %d %s", int(math.Round(float64(low))), int(math.Round(float64(high))), u))
+	}
+	if s.PrecipM != nil {
+		v, u := unit.Distance(*s.PrecipM)
+		if s.MaxChanceOfRainPercent != nil {
+			weather = append(weather, fmt.Sprintf("%.1f %s (%d%%)", v, u, *s.MaxChanceOfRainPercent))
+		} else {
+			weather = append(weather, fmt.Sprintf("%.1f %s", v, u))
+		}
+	} else if s.MaxChanceOfRainPercent != nil {
+		weather = append(weather, fmt.Sprintf("%d%% rain", *s.MaxChanceOfRainPercent))
+	}
+	if s.MaxWindspeedKmph != nil {
+		v, u := unit.Speed(*s.MaxWindspeedKmph)
+		other = append(other, fmt.Sprintf("wind ≤ %d %s", int(math.Round(float64(v))), u))
+	}
+	if s.MaxWindGustKmph != nil {
+		v, u := unit.Speed(*s.MaxWindGustKmph)
+		other = append(other, fmt.Sprintf("gusts ≤ %d %s", int(math.Round(float64(v))), u))
+	}
+	if s.SunshineHours != nil {
+		other = append(other, fmt.Sprintf("%.1f h sun", *s.SunshineHours))
+	}
+	return
+}
+
+// slugLetters transliterates the accented latin letters of location names.
+var slugLetters = func() map[rune]string {
+	m := make(map[rune]string)
+	for ascii, letters := range map[string]string{
+		"a": "àáâãäåāăą", "ae": "æ", "c": "çćč", "d": "ďđð", "e": "èéêëēėęě",
+		"g": "ğ", "i": "ìíîïīįı", "l": "łľĺ", "n": "ñńň", "o": "òóôõöøōő",
+		"oe": "œ", "r": "řŕ", "s": "śšşș", "ss": "ß", "t": "ťţț", "th": "þ",
+		"u": "ùúûüūůűų", "y": "ýÿ", "z": "źżž",
+	} {
+		for _, r := range letters {
+			m[r] = ascii
+		}
+	}
+	return m
+}()
+
+// locationSlug turns the name of a location into a part of topics, ids and
+// file names. If letters of the name can not be transliterated, a hash of the
+// name is appended, so different locations keep different slugs.
+func locationSlug(location string) string {
+	if i := strings.Index(location, " ("); i > 0 {
+		location = location[:i]
+	}
+	var b strings.Builder
+	lossy := false
+	for _, r := range strings.ToLower(location) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+			continue
+		} else if ascii, ok := slugLetters[r]; ok {
+			b.WriteString(ascii)
+			continue
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			lossy = true
+		}
+		if s := b.String(); s != "" && !strings.HasSuffix(s, "_") {
+			b.WriteByte('_')
+		}
+	}
+	s := strings.TrimSuffix(b.String(), "_")
+	if s == "" {
+		s = "weather"
+	}
+	if lossy {
+		h := fnv.New32a()
+		io.WriteString(h, location)
+		s = fmt.Sprintf("%s_%08x", s, h.Sum32())
+	}
+	return s
+}
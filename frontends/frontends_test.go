@@ -0,0 +1,227 @@
+package frontends
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+var update = flag.Bool("update", false, "update the golden files in testdata")
+
+func loadFixture(t *testing.T) iface.Data {
+	t.Helper()
+	b, err := os.ReadFile("testdata/forecast.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r iface.Data
+	if err := json.Unmarshal(b, &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// goldenFrontends are configured like their flag defaults, except for settings
+// which would depend on the environment.
+var goldenFrontends = map[string]iface.Frontend{
+	"ascii-art-table":  &aatConfig{},
+	"ascii-art-coords": &aatConfig{coords: true, monochrome: true},
+	"chart":            &chartConfig{output: "-", format: "svg", width: 800, height: 400},
+	"compare":          &compareConfig{},
+	"daily":            &dailyConfig{},
+	"emoji":            &emojiConfig{},
+	"graph":            &graphConfig{width: 100, height: 8, rainHeight: 3, image: "none"},
+	"json":             &jsnConfig{},
+	"markdown":         &mdConfig{coords: true},
+	"prometheus":       &promConfig{hours: 24, now: func() time.Time { return time.Date(2024, 7, 14, 12, 0, 0, 0, time.UTC) }},
+}
+
+func TestGolden(t *testing.T) {
+	r := loadFixture(t)
+	units := map[string]iface.UnitSystem{
+		"metric":   iface.UnitsMetric,
+		"imperial": iface.UnitsImperial,
+	}
+
+	for name, fe := range goldenFrontends {
+		for unitName, unit := range units {
+			var out bytes.Buffer
+			if err := fe.Render(&out, r, unit); err != nil {
+				t.Errorf("%s (%s): Render failed: %v", name, unitName, err)
+				continue
+			}
+
+			checkGolden(t, name+"."+unitName, out.Bytes())
+		}
+	}
+}
+
+// checkGolden compares got with the golden file testdata/name.golden.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	golden := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("%v (run go test with -update to create it)", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("output differs from %s:\n%s", golden, got)
+	}
+}
+
+func TestGoldenMulti(t *testing.T) {
+	oslo := loadFixture(t)
+	bergen := loadFixture(t)
+	bergen.Location = "Bergen, Norway"
+	bergen.Forecast = bergen.Forecast[:2]
+	for _, d := range bergen.Forecast {
+		for _, s := range d.Slots {
+			*s.TempC -= 6
+		}
+	}
+
+	for _, name := range []string{"compare", "json", "prometheus"} {
+		var out bytes.Buffer
+		fe := goldenFrontends[name].(iface.MultiFrontend)
+		if err := fe.RenderMulti(&out, []iface.Data{oslo, bergen}, iface.UnitsMetric); err != nil {
+			t.Errorf("%s: RenderMulti failed: %v", name, err)
+			continue
+		}
+		checkGolden(t, name+".multi", out.Bytes())
+	}
+}
+
+func TestGoldenCoversAllFrontends(t *testing.T) {
+	for name := range iface.AllFrontends {
+		if _, ok := goldenFrontends[name]; !ok {
+			t.Errorf("frontend %q has no golden file test", name)
+		}
+	}
+}
+
+func TestRenderInvalidCode(t *testing.T) {
+	r := loadFixture(t)
+	r.Forecast[1].Slots[2].Code = iface.WeatherCode(99)
+	for _, name := range []string{"ascii-art-table", "compare", "emoji", "markdown"} {
+		if err := goldenFrontends[name].Render(&bytes.Buffer{}, r, iface.UnitsMetric); err == nil {
+			t.Errorf("%s: expected an error for an unknown weather code", name)
+		}
+	}
+}
+
+func TestChartOutput(t *testing.T) {
+	oslo := loadFixture(t)
+	bergen := loadFixture(t)
+	bergen.Location = "Bergen, Norway"
+
+	// the format defaults to svg without a file extension
+	var out bytes.Buffer
+	c := &chartConfig{output: "-", width: 800, height: 400}
+	if err := c.Render(&out, oslo, iface.UnitsMetric); err != nil || !bytes.HasPrefix(out.Bytes(), []byte("<svg")) {
+		t.Errorf("got %.20q, %v instead of an svg", out.String(), err)
+	}
+	if err := c.RenderMulti(&out, []iface.Data{oslo, bergen}, iface.UnitsMetric); err == nil {
+		t.Errorf("several meteograms were written to the output")
+	}
+
+	dir := t.TempDir()
+	c.output = filepath.Join(dir, "wego.svg")
+	if err := c.RenderMulti(&out, []iface.Data{oslo, bergen}, iface.UnitsMetric); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"wego-testville.svg", "wego-bergen_norway.svg"} {
+		if b, err := os.ReadFile(filepath.Join(dir, name)); err != nil || !bytes.HasPrefix(b, []byte("<svg")) {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+func TestLocationSlug(t *testing.T) {
+	for in, want := range map[string]string{
+		"Oslo":                        "oslo",
+		"New York, NY (40.71,-74.01)": "new_york_ny",
+		"  São Paulo ":                "sao_paulo",
+		"Zürich":                      "zurich",
+		"Łódź, Poland":                "lodz_poland",
+		"48.14,11.58":                 "48_14_11_58",
+		"":                            "weather",
+	} {
+		if got := locationSlug(in); got != want {
+			t.Errorf("locationSlug(%q) = %q, want %q", in, got, want)
+		}
+	}
+
+	// names which can not be transliterated get a hash to tell them apart
+	slugs := make(map[string]string)
+	for _, in := range []string{"東京", "大阪", "Москва", "Москва, Россия", "Sapporo 札幌"} {
+		got := locationSlug(in)
+		if prev, ok := slugs[got]; ok {
+			t.Errorf("locationSlug(%q) = %q, the same as for %q", in, got, prev)
+		}
+		slugs[got] = in
+	}
+	if got := locationSlug("Sapporo 札幌"); !strings.HasPrefix(got, "sapporo_") {
+		t.Errorf("locationSlug lost the transliterated part: %q", got)
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestRenderWriteError(t *testing.T) {
+	r := loadFixture(t)
+	for name, fe := range goldenFrontends {
+		if err := fe.Render(failingWriter{}, r, iface.UnitsMetric); err == nil {
+			t.Errorf("%s: write error was not returned", name)
+		}
+	}
+}
+
+func TestCompareLocalNoon(t *testing.T) {
+	// noon in Tokyo is 03:00 UTC, the rain falls in the evening
+	tokyo := time.FixedZone("JST", 9*60*60)
+	temp := float32(25)
+	day := iface.Day{Date: time.Date(2024, 7, 14, 0, 0, 0, 0, tokyo), Slots: []iface.Cond{
+		{Time: time.Date(2024, 7, 14, 3, 0, 0, 0, time.UTC).In(tokyo), Code: iface.CodeSunny, TempC: &temp},
+		{Time: time.Date(2024, 7, 14, 12, 0, 0, 0, time.UTC).In(tokyo), Code: iface.CodeHeavyRain, TempC: &temp},
+	}}
+	c := &compareConfig{unit: iface.UnitsMetric}
+	if cell := c.formatDay(day); !strings.Contains(cell, emojiCodes[iface.CodeSunny]) {
+		t.Errorf("got %q, want the condition at noon in Tokyo", cell)
+	}
+}
+
+func TestCompareOneBound(t *testing.T) {
+	high, low := float32(20), float32(11)
+	c := &compareConfig{monochrome: true}
+	for _, tt := range []struct {
+		summary iface.Summary
+		want    string
+	}{
+		{iface.Summary{MaxTempC: &high}, "20/? °C"},
+		{iface.Summary{MinTempC: &low}, "?/11 °C"},
+	} {
+		var out bytes.Buffer
+		r := iface.Data{Location: "Oslo", Forecast: []iface.Day{{Date: time.Date(2024, 7, 30, 0, 0, 0, 0, time.UTC), Summary: &tt.summary}}}
+		if err := c.Render(&out, r, iface.UnitsMetric); err != nil || !strings.Contains(out.String(), tt.want) {
+			t.Errorf("got %q, %v, want %q", out.String(), err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package frontends
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"image"
+	"image/color/palette"
+	"image/draw"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-colorable"
+	"github.com/schachmat/wego/iface"
+)
+
+type graphConfig struct {
+	width      int
+	height     int
+	rainHeight int
+	image      string
+	monochrome bool
+	unit       iface.UnitSystem
+}
+
+// graphLabelWidth is the width of the y-axis label column left of the plots.
+const graphLabelWidth = 8
+
+// brailleDots maps the dot position inside a 2x4 braille cell to its bit.
+var brailleDots = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+func graphTempColor(temp float32) int {
+	colmap := []struct {
+		maxtemp float32
+		color   int
+	}{
+		{-15, 21}, {-12, 27}, {-9, 33}, {-6, 39}, {-3, 45},
+		{0, 51}, {2, 50}, {4, 49}, {6, 48}, {8, 47},
+		{10, 46}, {13, 82}, {16, 118}, {19, 154}, {22, 190},
+		{25, 226}, {28, 220}, {31, 214}, {34, 208}, {37, 202},
+	}
+	for _, candidate := range colmap {
+		if temp < candidate.maxtemp {
+			return candidate.color
+		}
+	}
+	return 196
+}
+
+func graphColorize(s string, col int) string {
+	return fmt.Sprintf("\033[38;5;%03dm%s\033[0m", col, s)
+}
+
+// columns returns the number of terminal columns available for the plots.
+func (c *graphConfig) columns() int {
+	cols := c.width
+	if cols <= 0 {
+		if env, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil {
+			cols = env
+		} else {
+			cols = 100
+		}
+	}
+	if cols-graphLabelWidth < 10 {
+		return 10
+	}
+	return cols - graphLabelWidth
+}
+
+func (c *graphConfig) precip(precipM float32) (float64, string) {
+	if c.unit == iface.UnitsImperial {
+		return float64(precipM / 0.0254), "in/h"
+	}
+	return float64(precipM * 1000), "mm/h"
+}
+
+func (c *graphConfig) lines(r iface.Data, slots []iface.Cond) (ret []string) {
+	plotW := c.columns()
+	t0, t1 := slots[0].Time, slots[len(slots)-1].Time
+	span := t1.Sub(t0)
+	if span <= 0 {
+		span = time.Hour
+	}
+	scale := func(t time.Time, n int) int {
+		return int(math.Round(float64(t.Sub(t0)) / float64(span) * float64(n-1)))
+	}
+
+	// vertical day separators
+	separators := make(map[int]bool)
+	var dayStarts []time.Time
+	for d := time.Date(t0.Year(), t0.Month(), t0.Day(), 0, 0, 0, 0, t0.Location()); !d.After(t1); d = d.AddDate(0, 0, 1) {
+		if d.After(t0) {
+			separators[scale(d, plotW)] = true
+			dayStarts = append(dayStarts, d)
+		} else {
+			dayStarts = append(dayStarts, t0)
+		}
+	}
+
+	// temperature line as braille dots
+	minT, maxT := math.Inf(1), math.Inf(-1)
+	for _, s := range slots {
+		if s.TempC != nil {
+			t, _ := c.unit.Temp(*s.TempC)
+			minT, maxT = math.Min(minT, float64(t)), math.Max(maxT, float64(t))
+		}
+	}
+	if math.IsInf(minT, 1) {
+		minT, maxT = 0, 1
+	}
+	minT, maxT = math.Floor(minT), math.Ceil(maxT)
+	if maxT == minT {
+		maxT++
+	}
+	dotsW, dotsH := plotW*2, c.height*4
+	cells := make([][]rune, c.height)
+	colors := make([][]int, c.height)
+	for i := range cells {
+		cells[i] = make([]rune, plotW)
+		colors[i] = make([]int, plotW)
+	}
+	set := func(x, y, col int) {
+		if x < 0 || y < 0 || x >= dotsW || y >= dotsH {
+			return
+		}
+		cells[y/4][x/2] |= brailleDots[y%4][x%2]
+		colors[y/4][x/2] = col
+	}
+	prevX, prevY := -1, -1
+	for _, s := range slots {
+		if s.TempC == nil {
+			continue
+		}
+		t, _ := c.unit.Temp(*s.TempC)
+		x := scale(s.Time, dotsW)
+		y := int(math.Round((maxT - float64(t)) / (maxT - minT) * float64(dotsH-1)))
+		col := graphTempColor(*s.TempC)
+		if prevX < 0 {
+			prevX, prevY = x, y
+		}
+		// connect to the previous point
+		steps := graphAbs(x - prevX)
+		if dy := graphAbs(y - prevY); dy > steps {
+			steps = dy
+		}
+		for i := 0; i <= steps; i++ {
+			f := 1.0
+			if steps > 0 {
+				f = float64(i) / float64(steps)
+			}
+			set(prevX+int(math.Round(float64(x-prevX)*f)), prevY+int(math.Round(float64(y-prevY)*f)), col)
+		}
+		prevX, prevY = x, y
+	}
+
+	_, tUnit := c.unit.Temp(0)
+	for row := range cells {
+		label := strings.Repeat(" ", graphLabelWidth-1) + "│"
+		if row == 0 || row == c.height-1 || row == c.height/2 {
+			t := maxT - (float64(row*4)+1.5)/float64(dotsH-1)*(maxT-minT)
+			if row == 0 {
+				t = maxT
+			} else if row == c.height-1 {
+				t = minT
+			}
+			label = fmt.Sprintf("%4.0f%s ┤", t, tUnit)
+		}
+		var b strings.Builder
+		b.WriteString(label)
+		for x, dots := range cells[row] {
+			if dots == 0 && separators[x] {
+				b.WriteString(graphColorize("│", 240))
+			} else if dots == 0 {
+				b.WriteString(" ")
+			} else {
+				b.WriteString(graphColorize(string(0x2800+dots), colors[row][x]))
+			}
+		}
+		ret = append(ret, b.String())
+	}
+
+	// precipitation as block bars, each slot covers the columns up to the next
+	values := make([]float64, plotW)
+	chances := make([]int, plotW)
+	for i := range chances {
+		chances[i] = -1
+	}
+	maxP := 1.0
+	if c.unit == iface.UnitsImperial {
+		maxP = 0.04
+	}
+	for i, s := range slots {
+		from, to := scale(s.Time, plotW), plotW
+		if i+1 < len(slots) {
+			to = scale(slots[i+1].Time, plotW)
+		}
+		if to <= from {
+			to = from + 1
+		}
+		for x := from; x < to && x < plotW; x++ {
+			if s.PrecipM != nil {
+				values[x], _ = c.precip(*s.PrecipM)
+				maxP = math.Max(maxP, values[x])
+			}
+			if s.ChanceOfRainPercent != nil {
+				chances[x] = *s.ChanceOfRainPercent
+			}
+		}
+	}
+	blocks := []rune(" ▁▂▃▄▅▆▇█")
+	_, pUnit := c.precip(0)
+	for row := 0; row < c.rainHeight; row++ {
+		label := strings.Repeat(" ", graphLabelWidth-1) + "│"
+		if row == 0 {
+			label = fmt.Sprintf("%6.1f ┤", maxP)
+		} else if row == c.rainHeight-1 {
+			label = fmt.Sprintf("%6s │", pUnit)
+		}
+		var b strings.Builder
+		b.WriteString(label)
+		for x, v := range values {
+			level := int(math.Round(v/maxP*float64(c.rainHeight*8))) - (c.rainHeight-1-row)*8
+			if v > 0 && level < 1 && row == c.rainHeight-1 {
+				level = 1
+			}
+			if level <= 0 {
+				if separators[x] {
+					b.WriteString(graphColorize("│", 240))
+				} else {
+					b.WriteString(" ")
+				}
+				continue
+			}
+			if level > 8 {
+				level = 8
+			}
+			col := 111
+			if chances[x] >= 50 {
+				col = 33
+			}
+			b.WriteString(graphColorize(string(blocks[level]), col))
+		}
+		ret = append(ret, b.String())
+	}
+
+	// time axis with day labels and sunrise/sunset markers
+	axis := []rune(strings.Repeat("─", plotW))
+	dates := []rune(strings.Repeat(" ", plotW))
+	astro := make([]string, plotW)
+	for i := range astro {
+		astro[i] = " "
+	}
+	for x := range separators {
+		axis[x] = '┬'
+	}
+	for _, d := range dayStarts {
+		x := scale(d, plotW)
+		label := []rune(d.Format("Mon 02."))
+		if x+len(label) > plotW {
+			continue
+		}
+		copy(dates[x:], label)
+	}
+	for _, day := range r.Forecast {
+		for _, m := range []struct {
+			t      time.Time
+			marker string
+			col    int
+		}{{day.Astronomy.Sunrise, "↑", 226}, {day.Astronomy.Sunset, "↓", 208}} {
+			if m.t.IsZero() || m.t.Before(t0) || m.t.After(t1) {
+				continue
+			}
+			astro[scale(m.t, plotW)] = graphColorize(m.marker, m.col)
+		}
+	}
+	indent := strings.Repeat(" ", graphLabelWidth-1)
+	ret = append(ret,
+		indent+"└"+string(axis),
+		indent+" "+string(dates),
+		indent+" "+strings.Join(astro, ""),
+		indent+" "+graphColorize("↑", 226)+" sunrise  "+graphColorize("↓", 208)+" sunset  "+graphColorize("▆", 33)+" precipitation")
+	return
+}
+
+func graphAbs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// graphKitty writes img using the kitty terminal graphics protocol.
+func graphKitty(w io.Writer, img image.Image) error {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return err
+	}
+	data := base64.StdEncoding.EncodeToString(buf.Bytes())
+	for i := 0; i < len(data); i += 4096 {
+		end, more := i+4096, 1
+		if end >= len(data) {
+			end, more = len(data), 0
+		}
+		if i == 0 {
+			fmt.Fprintf(w, "\033_Gf=100,a=T,m=%d;%s\033\\", more, data[i:end])
+		} else {
+			fmt.Fprintf(w, "\033_Gm=%d;%s\033\\", more, data[i:end])
+		}
+	}
+	_, err := fmt.Fprintln(w)
+	return err
+}
+
+// graphSixel writes img as DEC sixel graphics reduced to the web safe palette.
+func graphSixel(w io.Writer, img image.Image) error {
+	bounds := img.Bounds()
+	pal := image.NewPaletted(image.Rect(0, 0, bounds.Dx(), bounds.Dy()), palette.WebSafe)
+	draw.Draw(pal, pal.Rect, img, bounds.Min, draw.Src)
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "\033Pq\"1;1;%d;%d", pal.Rect.Dx(), pal.Rect.Dy())
+	for i, col := range pal.Palette {
+		r, g, b, _ := col.RGBA()
+		fmt.Fprintf(bw, "#%d;2;%d;%d;%d", i, r*100/0xffff, g*100/0xffff, b*100/0xffff)
+	}
+	for y := 0; y < pal.Rect.Dy(); y += 6 {
+		used := make(map[uint8]bool)
+		for x := 0; x < pal.Rect.Dx(); x++ {
+			for dy := 0; dy < 6 && y+dy < pal.Rect.Dy(); dy++ {
+				used[pal.ColorIndexAt(x, y+dy)] = true
+			}
+		}
+		idxs := make([]int, 0, len(used))
+		for idx := range used {
+			idxs = append(idxs, int(idx))
+		}
+		sort.Ints(idxs)
+		for _, idx := range idxs {
+			fmt.Fprintf(bw, "#%d", idx)
+			var run byte
+			count := 0
+			flush := func() {
+				if count > 3 {
+					fmt.Fprintf(bw, "!%d%c", count, run)
+				} else if count > 0 {
+					bw.WriteString(strings.Repeat(string(run), count))
+				}
+			}
+			for x := 0; x < pal.Rect.Dx(); x++ {
+				bits := 0
+				for dy := 0; dy < 6 && y+dy < pal.Rect.Dy(); dy++ {
+					if int(pal.ColorIndexAt(x, y+dy)) == idx {
+						bits |= 1 << dy
+					}
+				}
+				if ch := byte(63 + bits); ch == run {
+					count++
+				} else {
+					flush()
+					run, count = ch, 1
+				}
+			}
+			flush()
+			bw.WriteByte('$')
+		}
+		bw.WriteByte('-')
+	}
+	bw.WriteString("\033\\\n")
+	return bw.Flush()
+}
+
+func (c *graphConfig) renderImage(w io.Writer, r iface.Data, slots []iface.Cond, protocol string) error {
+	// roughly match the size of the text graph in an 8x16 pixel cell terminal
+	chart := &chartConfig{
+		width:  (c.columns() + graphLabelWidth) * 8,
+		height: (c.height + c.rainHeight + 4) * 16,
+		unit:   c.unit,
+	}
+	cv := &pngCanvas{img: image.NewRGBA(image.Rect(0, 0, chart.width, chart.height))}
+	chart.plot(cv, r, slots)
+
+	if protocol == "kitty" {
+		return graphKitty(w, cv.img)
+	}
+	return graphSixel(w, cv.img)
+}
+
+func (c *graphConfig) Setup() {
+	flag.IntVar(&c.width, "graph-width", 0, "graph frontend: total width in `COLUMNS`, defaults to $COLUMNS or 100")
+	flag.IntVar(&c.height, "graph-height", 8, "graph frontend: height of the temperature graph in `ROWS`")
+	flag.IntVar(&c.rainHeight, "graph-rain-height", 3, "graph frontend: height of the precipitation bars in `ROWS`")
+	flag.StringVar(&c.image, "graph-image", "none", "graph frontend: draw a real image with the terminal graphics `PROTOCOL`\n    \tChoices are: none, kitty, sixel, auto")
+	flag.BoolVar(&c.monochrome, "graph-monochrome", false, "graph frontend: Monochrome output")
+}
+
+func (c *graphConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
+	c.unit = unitSystem
+	if c.height < 1 {
+		c.height = 1
+	}
+	if c.rainHeight < 1 {
+		c.rainHeight = 1
+	}
+
+	var slots []iface.Cond
+	for _, d := range r.Forecast {
+		slots = append(slots, d.Slots...)
+	}
+	sort.SliceStable(slots, func(i, j int) bool { return slots[i].Time.Before(slots[j].Time) })
+	if len(slots) < 2 {
+		return fmt.Errorf("graph-frontend: Not enough forecast data to draw a graph")
+	}
+
+	protocol := c.image
+	if protocol == "auto" {
+		protocol = "none"
+		if os.Getenv("KITTY_WINDOW_ID") != "" || os.Getenv("TERM") == "xterm-kitty" {
+			protocol = "kitty"
+		}
+	}
+	if protocol != "none" && protocol != "kitty" && protocol != "sixel" {
+		return fmt.Errorf("graph-frontend: Unknown graphics protocol %q", c.image)
+	}
+
+	if _, err := fmt.Fprintf(w, "Weather for %s\n\n", r.Location); err != nil {
+		return err
+	}
+	if protocol != "none" {
+		return c.renderImage(w, r, slots, protocol)
+	}
+
+	ew := &errWriter{w: w}
+	var stdout io.Writer = ew
+	if c.monochrome {
+		stdout = colorable.NewNonColorable(ew)
+	}
+	for _, val := range c.lines(r, slots) {
+		fmt.Fprintln(stdout, val)
+	}
+	return ew.err
+}
+
+func init() {
+	iface.AllFrontends["graph"] = &graphConfig{}
+}
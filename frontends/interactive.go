@@ -0,0 +1,330 @@
+package frontends
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/schachmat/wego/derive"
+	"github.com/schachmat/wego/iface"
+	"golang.org/x/term"
+)
+
+const (
+	interactiveTable = iota
+	interactiveGraph
+	interactiveDetail
+)
+
+var interactiveViews = []string{"table", "graph", "detail"}
+
+var interactiveUnits = []struct {
+	name string
+	unit iface.UnitSystem
+}{
+	{"metric", iface.UnitsMetric},
+	{"imperial", iface.UnitsImperial},
+	{"si", iface.UnitsSi},
+	{"metric-ms", iface.UnitsMetricMs},
+}
+
+// Interactive is a full screen terminal user interface to browse the forecast
+// of any backend. The slot cells are rendered like in the ascii-art-table
+// frontend. If In is a terminal, it is switched to raw mode while Run is
+// active, otherwise every byte read from In is handled as a key press, which
+// allows scripting the interface.
+type Interactive struct {
+	// Backends are the backends to cycle through, Fetch returns the
+	// normalized forecast of one of them.
+	Backends map[string]iface.Backend
+	Fetch    func(backend, location string, numdays int) (iface.Data, error)
+	Backend  string
+	Location string
+	NumDays  int
+	Unit     iface.UnitSystem
+	In       io.Reader
+	Out      io.Writer
+
+	data   iface.Data
+	day    int
+	slot   int
+	view   int
+	width  int
+	cycled string // the backend last selected with b
+	status string // the error of the last refresh
+}
+
+// interactiveLogWriter leaves the full screen mode before anything is logged,
+// because backends exit the program on fatal errors and the terminal must not
+// be left in raw mode then.
+type interactiveLogWriter struct {
+	once    *sync.Once
+	restore func()
+	w       io.Writer
+}
+
+func (l interactiveLogWriter) Write(p []byte) (int, error) {
+	l.once.Do(l.restore)
+	return l.w.Write(p)
+}
+
+func interactiveReadKey(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if b != 0x1b {
+		return string(b), nil
+	}
+	if b, err = r.ReadByte(); err != nil || b != '[' {
+		return "esc", err
+	}
+	if b, err = r.ReadByte(); err != nil {
+		return "", err
+	}
+	switch b {
+	case 'A':
+		return "up", nil
+	case 'B':
+		return "down", nil
+	case 'C':
+		return "right", nil
+	case 'D':
+		return "left", nil
+	}
+	return "", nil
+}
+
+func (t *Interactive) backendNames() []string {
+	names := make([]string, 0, len(t.Backends))
+	for name := range t.Backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (t *Interactive) unitName() string {
+	for _, u := range interactiveUnits {
+		if u.unit == t.Unit {
+			return u.name
+		}
+	}
+	return "metric"
+}
+
+// refresh fetches the forecast of backend. On failure the previous backend and
+// forecast are kept.
+func (t *Interactive) refresh(backend string) error {
+	data, err := t.Fetch(backend, t.Location, t.NumDays)
+	if err != nil {
+		return err
+	}
+	t.Backend, t.data = backend, data
+	if t.day >= len(t.data.Forecast) {
+		t.day = 0
+	}
+	t.slot = 0
+	return nil
+}
+
+// handle applies a key press and reports whether the interface should quit.
+// Failed refreshes are shown in the status line.
+func (t *Interactive) handle(key string) (quit bool) {
+	slots := 0
+	if t.day < len(t.data.Forecast) {
+		slots = len(t.data.Forecast[t.day].Slots)
+	}
+
+	switch key {
+	case "q", "esc", "\x03":
+		return true
+	case "left", "h":
+		if t.day > 0 {
+			t.day, t.slot = t.day-1, 0
+		}
+	case "right", "l":
+		if t.day+1 < len(t.data.Forecast) {
+			t.day, t.slot = t.day+1, 0
+		}
+	case "up", "k":
+		if t.slot > 0 {
+			t.slot--
+		}
+	case "down", "j":
+		if t.slot+1 < slots {
+			t.slot++
+		}
+	case "v", "\t":
+		t.view = (t.view + 1) % len(interactiveViews)
+	case "1", "2", "3":
+		t.view = int(key[0] - '1')
+	case "u":
+		for i, u := range interactiveUnits {
+			if u.unit == t.Unit {
+				t.Unit = interactiveUnits[(i+1)%len(interactiveUnits)].unit
+				break
+			}
+		}
+	case "b":
+		names := t.backendNames()
+		for i, name := range names {
+			if name == t.cycled {
+				t.cycled = names[(i+1)%len(names)]
+				break
+			}
+		}
+		t.status = ""
+		if err := t.refresh(t.cycled); err != nil {
+			t.status = err.Error()
+		}
+	case "r":
+		t.status = ""
+		if err := t.refresh(t.Backend); err != nil {
+			t.status = err.Error()
+		}
+	}
+	return false
+}
+
+func (t *Interactive) detail(day iface.Day) (ret []string) {
+	if len(day.Slots) == 0 {
+		weather, other := formatSummary(day, t.Unit)
+		if len(weather)+len(other) == 0 {
+			return []string{"No hourly data for this day."}
+		}
+		return []string{day.Date.Format("Mon 02. Jan"), "", strings.Join(weather, ", "), strings.Join(other, ", "), "", "No hourly data for this day."}
+	}
+	aat := &aatConfig{unit: t.Unit}
+	sel := day.Slots[t.slot]
+	ret = append(ret, sel.Time.Format("Mon 02. Jan 15:04"), "")
+	ret = append(ret, aat.formatCond(make([]string, 5), sel, true)...)
+	ret = append(ret, "")
+	if sel.Humidity != nil {
+		ret = append(ret, fmt.Sprintf("Humidity: %d%%", *sel.Humidity))
+	}
+	if sel.WinddirDegree != nil {
+		ret = append(ret, fmt.Sprintf("Wind direction: %d°", *sel.WinddirDegree))
+	}
+	if sel.WindspeedKmph != nil {
+		force := derive.Beaufort(*sel.WindspeedKmph)
+		ret = append(ret, fmt.Sprintf("Wind force: %d Bft, %s", force, derive.BeaufortDescription(force)))
+	}
+	for _, temp := range []struct {
+		name string
+		c    *float32
+	}{{"Dew point", sel.DewPointC}, {"Wet-bulb", sel.WetBulbC}, {"Humidex", sel.HumidexC}, {"Heat index", sel.HeatIndexC}} {
+		if temp.c != nil {
+			v, u := aat.unit.Temp(*temp.c)
+			ret = append(ret, fmt.Sprintf("%s: %.1f %s", temp.name, v, u))
+		}
+	}
+	ret = append(ret, "")
+	for i, s := range day.Slots {
+		marker := "  "
+		if i == t.slot {
+			marker = "▶ "
+		}
+		ret = append(ret, fmt.Sprintf("%s%s  %s %s", marker, s.Time.Format("15:04"), aat.formatTemp(s), s.Desc))
+	}
+	return
+}
+
+func (t *Interactive) lines() (ret []string) {
+	ret = append(ret, fmt.Sprintf("\033[1mWeather for %s\033[0m │ backend: %s │ units: %s │ view: %s",
+		t.data.Location, t.Backend, t.unitName(), interactiveViews[t.view]))
+
+	if len(t.data.Forecast) == 0 {
+		aat := &aatConfig{unit: t.Unit}
+		ret = append(ret, "")
+		ret = append(ret, aat.formatCond(make([]string, 5), t.data.Current, true)...)
+		return append(ret, "", "No detailed weather forecast available.")
+	}
+
+	day := t.data.Forecast[t.day]
+	ret = append(ret, fmt.Sprintf("Day %d/%d: %s", t.day+1, len(t.data.Forecast), day.Date.Format("Mon 02. Jan")), "")
+
+	switch t.view {
+	case interactiveTable:
+		aat := &aatConfig{unit: t.Unit}
+		ret = append(ret, aat.formatCond(make([]string, 5), t.data.Current, true)...)
+		ret = append(ret, aat.printDay(day)...)
+	case interactiveGraph:
+		var slots []iface.Cond
+		for _, d := range t.data.Forecast {
+			slots = append(slots, d.Slots...)
+		}
+		sort.SliceStable(slots, func(i, j int) bool { return slots[i].Time.Before(slots[j].Time) })
+		if len(slots) < 2 {
+			ret = append(ret, "Not enough forecast data to draw a graph.")
+			break
+		}
+		g := &graphConfig{width: t.width, height: 8, rainHeight: 3, unit: t.Unit}
+		ret = append(ret, g.lines(t.data, slots)...)
+	case interactiveDetail:
+		ret = append(ret, t.detail(day)...)
+	}
+	return
+}
+
+func (t *Interactive) draw() {
+	var b strings.Builder
+	b.WriteString("\033[H\033[2J")
+	for _, l := range t.lines() {
+		b.WriteString(l)
+		b.WriteString("\r\n")
+	}
+	if t.status != "" {
+		b.WriteString("\r\n\033[31m" + strings.ReplaceAll(t.status, "\n", "\r\n") + "\033[0m\r\n")
+	}
+	b.WriteString("\r\n\033[2m←/→ day  ↑/↓ hour  v view  u units  b backend  r refresh  q quit\033[0m\r\n")
+	io.WriteString(t.Out, b.String())
+}
+
+// Run fetches the forecast and handles key presses until the user quits or In
+// is exhausted.
+func (t *Interactive) Run() error {
+	if f, ok := t.In.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fd := int(f.Fd())
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		var once sync.Once
+		restore := func() {
+			io.WriteString(t.Out, "\033[?25h\033[?1049l")
+			term.Restore(fd, state)
+		}
+		defer once.Do(restore)
+		defer log.SetOutput(log.Writer())
+		log.SetOutput(interactiveLogWriter{&once, restore, log.Writer()})
+
+		io.WriteString(t.Out, "\033[?1049h\033[?25l")
+		if w, _, err := term.GetSize(fd); err == nil {
+			t.width = w
+		}
+	}
+
+	t.cycled = t.Backend
+	if err := t.refresh(t.Backend); err != nil {
+		return err
+	}
+	in := bufio.NewReader(t.In)
+	for {
+		t.draw()
+		key, err := interactiveReadKey(in)
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if t.handle(key) {
+			return nil
+		}
+	}
+}
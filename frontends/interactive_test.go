@@ -0,0 +1,89 @@
+package frontends
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	_ "github.com/schachmat/wego/backends"
+	"github.com/schachmat/wego/iface"
+)
+
+// brokenBackend fails like a backend without its settings.
+type brokenBackend struct{}
+
+func (brokenBackend) Setup() {}
+func (brokenBackend) Fetch(location string, numdays int) (iface.Data, error) {
+	return iface.Data{}, errors.New("Set the url of the station")
+}
+
+// runInteractive drives the interactive interface with the given key presses
+// against the json backend and returns the last drawn screen.
+func runInteractive(t *testing.T, keys string) (*Interactive, string) {
+	t.Helper()
+	var out bytes.Buffer
+	backends := map[string]iface.Backend{"json": iface.AllBackends["json"], "station": brokenBackend{}}
+	ui := &Interactive{
+		Backends: backends,
+		Fetch: func(backend, location string, numdays int) (iface.Data, error) {
+			data, err := backends[backend].Fetch(location, numdays)
+			data, _ = iface.Normalize(data, numdays)
+			return data, err
+		},
+		Backend:  "json",
+		Location: "testdata/forecast.json",
+		NumDays:  3,
+		Unit:     iface.UnitsMetric,
+		In:       strings.NewReader(keys),
+		Out:      &out,
+	}
+	if err := ui.Run(); err != nil {
+		t.Fatalf("Run(%q) failed: %v", keys, err)
+	}
+	screens := strings.Split(out.String(), "\033[H\033[2J")
+	return ui, screens[len(screens)-1]
+}
+
+func TestInteractiveKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		keys string
+		want []string
+	}{
+		{"initial table", "", []string{"Weather for Testville", "view: table", "Day 1/3: Sun 14. Jul", "Morning"}},
+		{"next day", "l", []string{"Day 2/3: Mon 15. Jul"}},
+		{"arrow keys", "\033[C\033[C\033[D", []string{"Day 2/3: Mon 15. Jul"}},
+		{"stop at last day", "lllll", []string{"Day 3/3: Tue 16. Jul"}},
+		{"units", "u", []string{"units: imperial", "°F"}},
+		{"graph view", "v", []string{"view: graph", "sunrise"}},
+		{"detail view", "3jj", []string{"view: detail", "Sun 14. Jul 06:00", "Humidity: 62%", "▶ 06:00"}},
+		{"refresh keeps day", "lr", []string{"Day 2/3: Mon 15. Jul"}},
+		// a failing backend is skipped and the forecast kept
+		{"failing backend", "lb", []string{"backend: json", "Day 2/3: Mon 15. Jul", "Set the url of the station"}},
+		{"backend cycles to itself", "bb", []string{"backend: json", "Day 1/3"}},
+	}
+
+	for _, test := range tests {
+		_, screen := runInteractive(t, test.keys)
+		for _, want := range test.want {
+			if !strings.Contains(screen, want) {
+				t.Errorf("%s: screen after %q does not contain %q:\n%s", test.name, test.keys, want, screen)
+			}
+		}
+	}
+}
+
+func TestInteractiveStatus(t *testing.T) {
+	_, screen := runInteractive(t, "br")
+	if strings.Contains(screen, "Set the url") {
+		t.Errorf("the error is still shown after a refresh:\n%s", screen)
+	}
+}
+
+func TestInteractiveQuit(t *testing.T) {
+	ui, _ := runInteractive(t, "vq3")
+	if ui.view != interactiveGraph {
+		t.Errorf("keys after q were handled, view is %q", interactiveViews[ui.view])
+	}
+}
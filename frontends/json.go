@@ -3,8 +3,7 @@ package frontends
 import (
 	"encoding/json"
 	"flag"
-	"log"
-	"os"
+	"io"
 
 	"github.com/schachmat/wego/iface"
 )
@@ -17,18 +16,28 @@ func (c *jsnConfig) Setup() {
 	flag.BoolVar(&c.noIndent, "jsn-no-indent", false, "json frontend: do not indent the output")
 }
 
-func (c *jsnConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+func (c *jsnConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
+	return c.write(w, r)
+}
+
+// RenderMulti prints the forecasts of several locations as one json array.
+func (c *jsnConfig) RenderMulti(w io.Writer, rs []iface.Data, unitSystem iface.UnitSystem) error {
+	return c.write(w, rs)
+}
+
+func (c *jsnConfig) write(w io.Writer, v interface{}) error {
 	var b []byte
 	var err error
 	if c.noIndent {
-		b, err = json.Marshal(r)
+		b, err = json.Marshal(v)
 	} else {
-		b, err = json.MarshalIndent(r, "", "\t")
+		b, err = json.MarshalIndent(v, "", "\t")
 	}
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
-	os.Stdout.Write(b)
+	_, err = w.Write(b)
+	return err
 }
 
 func init() {
@@ -3,9 +3,8 @@ package frontends
 import (
 	"flag"
 	"fmt"
-	"log"
+	"io"
 	"math"
-	"os"
 	"strings"
 	"time"
 
@@ -133,7 +132,8 @@ func (c *mdConfig) formatCond(cur []string, cond iface.Cond, current bool) (ret
 
 	icon, ok := codes[cond.Code]
 	if !ok {
-		log.Fatalln("markdown-frontend: The following weather code has no icon:", cond.Code)
+		// Render rejects unknown codes, this only guards direct callers
+		icon = codes[iface.CodeUnknown]
 	}
 
 	desc := cond.Desc
@@ -196,8 +196,15 @@ func (c *mdConfig) printDay(day iface.Day) (ret []string) {
 		}
 	}
 	dateFmt := day.Date.Format("Mon Jan 02")
+	header := "\n### Forecast for "+dateFmt+ "\n"
+	if weather, other := formatSummary(day, c.unit); len(weather)+len(other) > 0 {
+		header += "\n" + strings.Join(append(weather, other...), ", ") + "\n"
+	}
+	if len(day.Slots) == 0 {
+		return []string{header}
+	}
 	ret = append([]string{
-		"\n### Forecast for "+dateFmt+ "\n",
+		header,
 		"| Morning                   | Noon                      | Evening                   | Night                     |",
 		"| ------------------------- | ------------------------- | ------------------------- | ------------------------- |"},
 		ret...)
@@ -208,26 +215,25 @@ func (c *mdConfig) Setup() {
 	flag.BoolVar(&c.coords, "md-coords", false, "md-frontend: Show geo coordinates")
 }
 
-func (c *mdConfig) Render(r iface.Data, unitSystem iface.UnitSystem) {
+func (c *mdConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
+	if err := checkCodes(r); err != nil {
+		return fmt.Errorf("markdown-frontend: %v", err)
+	}
 	c.unit = unitSystem
-	fmt.Printf("## Weather for %s%s\n\n", r.Location, c.formatGeo(r.GeoLoc))
-	stdout := colorable.NewNonColorable(os.Stdout)
+	ew := &errWriter{w: w}
+	stdout := colorable.NewNonColorable(ew)
+	fmt.Fprintf(stdout, "## Weather for %s%s\n\n", r.Location, c.formatGeo(r.GeoLoc))
 	out := c.formatCond(make([]string, 5), r.Current, true)
 	for _, val := range out {
 		fmt.Fprintln(stdout, val)
 	}
 
-	if len(r.Forecast) == 0 {
-		return
-	}
-	if r.Forecast == nil {
-		log.Fatal("No detailed weather forecast available.")
-	}
 	for _, d := range r.Forecast {
 		for _, val := range c.printDay(d) {
 			fmt.Fprintln(stdout, val)
 		}
 	}
+	return ew.err
 }
 
 func init() {
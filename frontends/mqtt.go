@@ -0,0 +1,248 @@
+package frontends
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"github.com/schachmat/wego/derive"
+	"github.com/schachmat/wego/iface"
+	"github.com/schachmat/wego/mqtt"
+)
+
+// mqttConfig publishes the weather to an MQTT broker, for home automation
+// systems like Home Assistant, openHAB or Node-RED.
+type mqttConfig struct {
+	broker    string
+	user      string
+	password  string
+	prefix    string
+	discovery string
+	retain    bool
+}
+
+// mqttSensor is a value of the current conditions, published as a key of the
+// state and announced as a sensor to Home Assistant.
+type mqttSensor struct {
+	key         string
+	name        string
+	deviceClass string
+	unit        func(u iface.UnitSystem) string
+	value       func(c iface.Cond, u iface.UnitSystem) *float32
+}
+
+func mqttTempUnit(u iface.UnitSystem) string {
+	_, unit := u.Temp(0)
+	return unit
+}
+
+func mqttSpeedUnit(u iface.UnitSystem) string {
+	_, unit := u.Speed(0)
+	return unit
+}
+
+func mqttTemp(v *float32, u iface.UnitSystem) *float32 {
+	if v == nil {
+		return nil
+	}
+	t, _ := u.Temp(*v)
+	return &t
+}
+
+func mqttSpeed(v *float32, u iface.UnitSystem) *float32 {
+	if v == nil {
+		return nil
+	}
+	s, _ := u.Speed(*v)
+	return &s
+}
+
+func mqttInt(v *int) *float32 {
+	if v == nil {
+		return nil
+	}
+	f := float32(*v)
+	return &f
+}
+
+// mqttPrecip converts meters to millimeters or inches, as the unit of
+// iface.UnitSystem.Distance changes with the amount.
+func mqttPrecip(v *float32, u iface.UnitSystem) *float32 {
+	if v == nil {
+		return nil
+	}
+	p := *v * 1000
+	if u == iface.UnitsImperial {
+		p = *v / 0.0254
+	}
+	return &p
+}
+
+var mqttSensors = []mqttSensor{
+	{"temperature", "Temperature", "temperature", mqttTempUnit,
+		func(c iface.Cond, u iface.UnitSystem) *float32 { return mqttTemp(c.TempC, u) }},
+	{"feels_like", "Feels like", "temperature", mqttTempUnit,
+		func(c iface.Cond, u iface.UnitSystem) *float32 { return mqttTemp(c.FeelsLikeC, u) }},
+	{"dew_point", "Dew point", "temperature", mqttTempUnit,
+		func(c iface.Cond, u iface.UnitSystem) *float32 { return mqttTemp(c.DewPointC, u) }},
+	{"humidity", "Humidity", "humidity", func(iface.UnitSystem) string { return "%" },
+		func(c iface.Cond, u iface.UnitSystem) *float32 { return mqttInt(c.Humidity) }},
+	{"chance_of_rain", "Chance of rain", "", func(iface.UnitSystem) string { return "%" },
+		func(c iface.Cond, u iface.UnitSystem) *float32 { return mqttInt(c.ChanceOfRainPercent) }},
+	{"precipitation", "Precipitation", "precipitation_intensity", func(u iface.UnitSystem) string {
+		if u == iface.UnitsImperial {
+			return "in/h"
+		}
+		return "mm/h"
+	}, func(c iface.Cond, u iface.UnitSystem) *float32 { return mqttPrecip(c.PrecipM, u) }},
+	{"wind_speed", "Wind speed", "wind_speed", mqttSpeedUnit,
+		func(c iface.Cond, u iface.UnitSystem) *float32 { return mqttSpeed(c.WindspeedKmph, u) }},
+	{"wind_gust", "Wind gust", "wind_speed", mqttSpeedUnit,
+		func(c iface.Cond, u iface.UnitSystem) *float32 { return mqttSpeed(c.WindGustKmph, u) }},
+	{"wind_direction", "Wind direction", "", func(iface.UnitSystem) string { return "°" },
+		func(c iface.Cond, u iface.UnitSystem) *float32 { return mqttInt(c.WinddirDegree) }},
+	{"visibility", "Visibility", "distance", func(u iface.UnitSystem) string {
+		if u == iface.UnitsImperial {
+			return "mi"
+		}
+		return "km"
+	}, func(c iface.Cond, u iface.UnitSystem) *float32 {
+		if c.VisibleDistM == nil {
+			return nil
+		}
+		v := *c.VisibleDistM / 1000
+		if u == iface.UnitsImperial {
+			v /= 1.609
+		}
+		return &v
+	}},
+}
+
+// mqttRound rounds v to one decimal, so the state does not show the noise of
+// the unit conversions.
+func mqttRound(v *float32) interface{} {
+	if v == nil {
+		return nil
+	}
+	return math.Round(float64(*v)*10) / 10
+}
+
+// mqttDay is a day of the forecast in the published units.
+type mqttDay struct {
+	Date          string      `json:"date"`
+	Condition     string      `json:"condition"`
+	TempMin       interface{} `json:"temperature_min"`
+	TempMax       interface{} `json:"temperature_max"`
+	Precipitation interface{} `json:"precipitation"`
+	ChanceOfRain  *int        `json:"chance_of_rain"`
+	WindSpeedMax  interface{} `json:"wind_speed_max"`
+}
+
+func (c *mqttConfig) Setup() {
+	flag.StringVar(&c.broker, "mqtt-broker", "localhost:1883", "mqtt frontend: `HOST:PORT` of the broker")
+	flag.StringVar(&c.user, "mqtt-user", "", "mqtt frontend: `USER` name for the broker")
+	flag.StringVar(&c.password, "mqtt-password", "", "mqtt frontend: `PASSWORD` for the broker, sent unencrypted as the connection is plain TCP")
+	flag.StringVar(&c.prefix, "mqtt-prefix", "wego", "mqtt frontend: `PREFIX` of the topics")
+	flag.StringVar(&c.discovery, "mqtt-discovery", "homeassistant", "mqtt frontend: discovery `PREFIX` of Home Assistant, an empty prefix disables the discovery")
+	flag.BoolVar(&c.retain, "mqtt-retain", true, "mqtt frontend: let the broker retain the weather for later subscribers")
+}
+
+// messages returns the messages to publish for r. The discovery configs are
+// always retained, as Home Assistant reads them again when it restarts.
+func (c *mqttConfig) messages(r iface.Data, unit iface.UnitSystem) (ret []mqtt.Message, err error) {
+	slug := locationSlug(r.Location)
+	base := strings.TrimSuffix(c.prefix, "/") + "/" + slug
+	add := func(topic string, v interface{}, retain bool) {
+		b, e := json.Marshal(v)
+		if e != nil && err == nil {
+			err = e
+		}
+		ret = append(ret, mqtt.Message{Topic: topic, Payload: b, Retain: retain})
+	}
+
+	current := map[string]interface{}{
+		"location":    r.Location,
+		"time":        r.Current.Time,
+		"condition":   codeNames[r.Current.Code],
+		"description": r.Current.Desc,
+	}
+	for _, s := range mqttSensors {
+		current[s.key] = mqttRound(s.value(r.Current, unit))
+	}
+	add(base+"/current", current, c.retain)
+
+	days := make([]mqttDay, 0, len(r.Forecast))
+	for _, d := range r.Forecast {
+		s := derive.Summary(d)
+		days = append(days, mqttDay{
+			Date:          d.Date.Format("2006-01-02"),
+			Condition:     codeNames[s.Code],
+			TempMin:       mqttRound(mqttTemp(s.MinTempC, unit)),
+			TempMax:       mqttRound(mqttTemp(s.MaxTempC, unit)),
+			Precipitation: mqttRound(mqttPrecip(s.PrecipM, unit)),
+			ChanceOfRain:  s.MaxChanceOfRainPercent,
+			WindSpeedMax:  mqttRound(mqttSpeed(s.MaxWindspeedKmph, unit)),
+		})
+	}
+	add(base+"/forecast", days, c.retain)
+
+	if c.discovery == "" {
+		return ret, err
+	}
+	device := map[string]interface{}{
+		"identifiers":  []string{"wego_" + slug},
+		"name":         "wego " + r.Location,
+		"manufacturer": "wego",
+	}
+	for _, s := range mqttSensors {
+		id := "wego_" + slug + "_" + s.key
+		config := map[string]interface{}{
+			"name":                s.name,
+			"unique_id":           id,
+			"state_topic":         base + "/current",
+			"value_template":      "{{ value_json." + s.key + " }}",
+			"unit_of_measurement": s.unit(unit),
+			"state_class":         "measurement",
+			"device":              device,
+		}
+		if s.deviceClass != "" {
+			config["device_class"] = s.deviceClass
+		}
+		add(strings.TrimSuffix(c.discovery, "/")+"/sensor/"+id+"/config", config, true)
+	}
+	add(strings.TrimSuffix(c.discovery, "/")+"/sensor/wego_"+slug+"_condition/config", map[string]interface{}{
+		"name":           "Condition",
+		"unique_id":      "wego_" + slug + "_condition",
+		"state_topic":    base + "/current",
+		"value_template": "{{ value_json.condition }}",
+		"device":         device,
+	}, true)
+	return ret, err
+}
+
+func (c *mqttConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
+	msgs, err := c.messages(r, unitSystem)
+	if err != nil {
+		return err
+	}
+	client, err := mqtt.Dial(c.broker, mqtt.Options{ClientID: fmt.Sprintf("wego-%d", os.Getpid()), Username: c.user, Password: c.password})
+	if err != nil {
+		return fmt.Errorf("Unable to connect to the mqtt broker %s: %v", c.broker, err)
+	}
+	defer client.Close()
+	for _, m := range msgs {
+		if err := client.Publish(m.Topic, m.Payload, m.Retain); err != nil {
+			return fmt.Errorf("Unable to publish to %s: %v", m.Topic, err)
+		}
+	}
+	_, err = fmt.Fprintf(w, "Published the weather of %s to %s/%s\n", r.Location, strings.TrimSuffix(c.prefix, "/"), locationSlug(r.Location))
+	return err
+}
+
+func init() {
+	iface.AllFrontends["mqtt"] = &mqttConfig{}
+}
@@ -0,0 +1,61 @@
+package frontends
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+	"github.com/schachmat/wego/mqtt/mqtttest"
+)
+
+// TestMain runs a broker for the mqtt frontend of the golden file tests.
+func TestMain(m *testing.M) {
+	b := mqtttest.NewBroker()
+	goldenFrontends["mqtt"] = &mqttConfig{broker: b.Addr(), prefix: "wego", discovery: "homeassistant", retain: true}
+	code := m.Run()
+	b.Close()
+	os.Exit(code)
+}
+
+func TestMqttPublish(t *testing.T) {
+	b := mqtttest.NewBroker()
+	defer b.Close()
+	c := &mqttConfig{broker: b.Addr(), prefix: "home/weather", discovery: "homeassistant"}
+	if err := c.Render(&bytes.Buffer{}, loadFixture(t), iface.UnitsImperial); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := b.Wait("homeassistant/sensor/wego_testville_condition/config", 5*time.Second); !ok {
+		t.Fatal("the messages were not published")
+	}
+	if _, ok := b.Retained("home/weather/testville/current"); ok {
+		t.Error("the state was retained without -mqtt-retain")
+	}
+	var current map[string]interface{}
+	for _, m := range b.Published() {
+		if m.Topic == "home/weather/testville/current" {
+			if err := json.Unmarshal(m.Payload, &current); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if current["temperature"] != 69.3 || current["wind_speed"] != 22.4 || current["condition"] != "Light rain" {
+		t.Errorf("unexpected state %v", current)
+	}
+
+	p, ok := b.Retained("homeassistant/sensor/wego_testville_temperature/config")
+	if !ok {
+		t.Fatal("the discovery config of the temperature was not retained")
+	}
+	var config map[string]interface{}
+	if err := json.Unmarshal(p, &config); err != nil {
+		t.Fatal(err)
+	}
+	if config["state_topic"] != "home/weather/testville/current" || config["unit_of_measurement"] != "°F" ||
+		config["value_template"] != "{{ value_json.temperature }}" {
+		t.Errorf("unexpected discovery config %s", p)
+	}
+}
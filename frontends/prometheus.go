@@ -0,0 +1,158 @@
+package frontends
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// promConfig writes the weather in the text exposition format of Prometheus
+// and OpenMetrics. The values always use base units as Prometheus recommends,
+// regardless of the unit system.
+type promConfig struct {
+	hours int
+
+	// now is the time the lead times are relative to, time.Now if nil.
+	now func() time.Time
+}
+
+// promMetric is a gauge read from every iface.Cond.
+type promMetric struct {
+	name  string
+	help  string
+	value func(c iface.Cond) *float64
+}
+
+// promFloat converts v to float64 without the noise of its binary
+// representation, so 20.7 is not exposed as 20.700000762939453.
+func promFloat(v *float32, divisor float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	f, _ := strconv.ParseFloat(strconv.FormatFloat(float64(*v), 'g', -1, 32), 64)
+	f /= divisor
+	return &f
+}
+
+func promInt(v *int, divisor float64) *float64 {
+	if v == nil {
+		return nil
+	}
+	f := float64(*v) / divisor
+	return &f
+}
+
+var promMetrics = []promMetric{
+	{"wego_temperature_celsius", "Temperature in degrees celsius.",
+		func(c iface.Cond) *float64 { return promFloat(c.TempC, 1) }},
+	{"wego_feels_like_celsius", "Felt temperature in degrees celsius.",
+		func(c iface.Cond) *float64 { return promFloat(c.FeelsLikeC, 1) }},
+	{"wego_dew_point_celsius", "Dew point in degrees celsius.",
+		func(c iface.Cond) *float64 { return promFloat(c.DewPointC, 1) }},
+	{"wego_heat_index_celsius", "Heat index in degrees celsius.",
+		func(c iface.Cond) *float64 { return promFloat(c.HeatIndexC, 1) }},
+	{"wego_humidex_celsius", "Humidex in degrees celsius.",
+		func(c iface.Cond) *float64 { return promFloat(c.HumidexC, 1) }},
+	{"wego_wet_bulb_celsius", "Wet-bulb temperature in degrees celsius.",
+		func(c iface.Cond) *float64 { return promFloat(c.WetBulbC, 1) }},
+	{"wego_humidity_ratio", "Relative humidity from 0 to 1.",
+		func(c iface.Cond) *float64 { return promInt(c.Humidity, 100) }},
+	{"wego_rain_chance_ratio", "Probability of rain or snow from 0 to 1.",
+		func(c iface.Cond) *float64 { return promInt(c.ChanceOfRainPercent, 100) }},
+	{"wego_precipitation_meters_per_hour", "Precipitation in meters per hour.",
+		func(c iface.Cond) *float64 { return promFloat(c.PrecipM, 1) }},
+	{"wego_visibility_meters", "Visibility range in meters.",
+		func(c iface.Cond) *float64 { return promFloat(c.VisibleDistM, 1) }},
+	{"wego_wind_speed_meters_per_second", "Average wind speed in meters per second.",
+		func(c iface.Cond) *float64 { return promFloat(c.WindspeedKmph, 3.6) }},
+	{"wego_wind_gust_meters_per_second", "Wind gusts in meters per second.",
+		func(c iface.Cond) *float64 { return promFloat(c.WindGustKmph, 3.6) }},
+	{"wego_wind_direction_degrees", "Direction the wind is blowing from in degrees, 0 is north.",
+		func(c iface.Cond) *float64 { return promInt(c.WinddirDegree, 1) }},
+	{"wego_weather_code", "Weather condition as the number of the iface.WeatherCode, 0 is unknown.",
+		func(c iface.Cond) *float64 { v := float64(c.Code); return &v }},
+}
+
+// promSample is a condition with its lead time in hours, 0 for the current
+// conditions.
+type promSample struct {
+	data *iface.Data
+	lead int
+	cond iface.Cond
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func (c *promConfig) Setup() {
+	flag.IntVar(&c.hours, "prom-hours", 24, "prometheus frontend: `NUMBER` of hours of forecast to expose besides the current conditions")
+}
+
+// samples returns the current conditions and the forecast slots of the next
+// hours of every location. Slots with the same rounded lead time as an
+// earlier one are left out, so every series is unique.
+func (c *promConfig) samples(rs []iface.Data) (ret []promSample) {
+	now := time.Now()
+	if c.now != nil {
+		now = c.now()
+	}
+	for i := range rs {
+		r := &rs[i]
+		ret = append(ret, promSample{r, 0, r.Current})
+		seen := map[int]bool{0: true}
+		for _, day := range r.Forecast {
+			for _, slot := range day.Slots {
+				d := slot.Time.Sub(now)
+				lead := int(math.Round(d.Hours()))
+				if d <= 0 || d > time.Duration(c.hours)*time.Hour || seen[lead] {
+					continue
+				}
+				seen[lead] = true
+				ret = append(ret, promSample{r, lead, slot})
+			}
+		}
+	}
+	return ret
+}
+
+func (c *promConfig) write(w io.Writer, rs []iface.Data) error {
+	samples := c.samples(rs)
+	var b strings.Builder
+	for _, m := range promMetrics {
+		header := false
+		for _, s := range samples {
+			v := m.value(s.cond)
+			if v == nil {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", m.name, m.help, m.name)
+				header = true
+			}
+			fmt.Fprintf(&b, "%s{location=\"%s\",backend=\"%s\",lead_hours=\"%d\"} %s\n", m.name,
+				promEscaper.Replace(s.data.Location), promEscaper.Replace(s.data.Backend), s.lead,
+				strconv.FormatFloat(*v, 'g', -1, 64))
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (c *promConfig) Render(w io.Writer, r iface.Data, unitSystem iface.UnitSystem) error {
+	return c.write(w, []iface.Data{r})
+}
+
+// RenderMulti writes the metrics of several locations together, as every
+// metric may only be described once.
+func (c *promConfig) RenderMulti(w io.Writer, rs []iface.Data, unitSystem iface.UnitSystem) error {
+	return c.write(w, rs)
+}
+
+func init() {
+	iface.AllFrontends["prometheus"] = &promConfig{}
+}
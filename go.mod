@@ -3,9 +3,12 @@ module github.com/schachmat/wego
 go 1.20
 
 require (
+	github.com/BurntSushi/toml v1.3.2
 	github.com/mattn/go-colorable v0.1.13
 	github.com/mattn/go-runewidth v0.0.14
 	github.com/schachmat/ingo v0.0.0-20170403011506-a4bdc0729a3f
+	golang.org/x/image v0.7.0
+	golang.org/x/term v0.8.0
 )
 
 require (
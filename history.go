@@ -0,0 +1,193 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// historyStore records the current conditions of every fetched forecast in a
+// file with one JSON object per line. It answers lookups of past days like an
+// iface.HistoricalBackend for backends which have no history themselves.
+type historyStore struct {
+	path   string
+	record bool
+}
+
+type historyEntry struct {
+	Backend  string
+	Location string
+	Cond     iface.Cond
+}
+
+// defaultDataDir returns $XDG_DATA_HOME/wego.
+func defaultDataDir() (string, error) {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(dir, "wego"), nil
+}
+
+// add appends the current conditions of data fetched for q, if recording is
+// enabled.
+func (h *historyStore) add(q query, data iface.Data) error {
+	if !h.record || data.Current.Time.IsZero() {
+		return nil
+	}
+	b, err := json.Marshal(historyEntry{q.backend, q.location, data.Current})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(h.path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(h.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// entries returns all recorded conditions.
+func (h *historyStore) entries() ([]historyEntry, error) {
+	f, err := os.Open(h.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var ret []historyEntry
+	s := bufio.NewScanner(f)
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		var e historyEntry
+		if err := json.Unmarshal(s.Bytes(), &e); err != nil {
+			continue
+		}
+		ret = append(ret, e)
+	}
+	if err := s.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to read the history %s: %v", h.path, err)
+	}
+	return ret, nil
+}
+
+// History returns the conditions recorded at location on the day of date by
+// any backend as the only Day of the Forecast. It returns false if there are
+// none.
+func (h *historyStore) History(location string, date time.Time) (iface.Data, bool, error) {
+	entries, err := h.entries()
+	if err != nil {
+		return iface.Data{}, false, err
+	}
+	day := date.Format("2006-01-02")
+	var slots []iface.Cond
+	for _, e := range entries {
+		if strings.EqualFold(e.Location, location) && e.Cond.Time.Format("2006-01-02") == day {
+			slots = append(slots, e.Cond)
+		}
+	}
+	if len(slots) == 0 {
+		return iface.Data{}, false, nil
+	}
+
+	// several backends or runs may have recorded the same observation
+	sort.SliceStable(slots, func(i, j int) bool { return slots[i].Time.Before(slots[j].Time) })
+	unique := slots[:1]
+	for _, c := range slots[1:] {
+		if !c.Time.Equal(unique[len(unique)-1].Time) {
+			unique = append(unique, c)
+		}
+	}
+	d := slots[0].Time
+	return iface.Data{
+		Location: location + " (recorded observations)",
+		Forecast: []iface.Day{{
+			Date:  time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, d.Location()),
+			Slots: unique,
+		}},
+	}, true, nil
+}
+
+// fetchHistory looks up the weather of the past date for all queries, from
+// their backend if it has a history and from the local history otherwise.
+func (a *app) fetchHistory(qs []query, date string) ([]iface.Data, error) {
+	day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid date \"%s\", use the format YYYY-MM-DD", date)
+	}
+	if y, m, d := time.Now().Date(); !day.Before(time.Date(y, m, d, 0, 0, 0, 0, time.Local)) {
+		return nil, fmt.Errorf("The date %s is not in the past, use DAYS to get a forecast", date)
+	}
+
+	rs := make([]iface.Data, len(qs))
+	for i, q := range qs {
+		if hb, ok := iface.AllBackends[q.backend].(iface.HistoricalBackend); ok {
+			if rs[i], err = fetchHistoryBackend(hb, q, day); err != nil {
+				return nil, err
+			}
+		} else if data, ok, err := a.history.History(q.location, day); err != nil {
+			return nil, err
+		} else if ok {
+			rs[i] = data
+		} else {
+			return nil, noHistoryError(q, date, a.history.record)
+		}
+
+		// frontends show the current conditions, noon is the most typical
+		// time of the day
+		if len(rs[i].Forecast) > 0 && len(rs[i].Forecast[0].Slots) > 0 {
+			slots := rs[i].Forecast[0].Slots
+			noon := rs[i].Forecast[0].Date.Add(12 * time.Hour)
+			rs[i].Current = slots[0]
+			for _, s := range slots[1:] {
+				if s.Time.Sub(noon).Abs() < rs[i].Current.Time.Sub(noon).Abs() {
+					rs[i].Current = s
+				}
+			}
+		}
+	}
+	return rs, nil
+}
+
+// fetchHistoryBackend gets the weather of day for q from its backend, see
+// fetchBackend.
+func fetchHistoryBackend(hb iface.HistoricalBackend, q query, day time.Time) (data iface.Data, err error) {
+	defer recoverBackend(q.backend, &err)
+	return hb.History(q.location, day)
+}
+
+// noHistoryError explains why the weather of date is unknown for q.
+func noHistoryError(q query, date string, recording bool) error {
+	var names []string
+	for name, be := range iface.AllBackends {
+		if _, ok := be.(iface.HistoricalBackend); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	hint := "Enable -history to record the current conditions of your locations for later lookups."
+	if recording {
+		hint = "The local history has no observations of that day."
+	}
+	return fmt.Errorf("The backend \"%s\" has no weather history, so the weather of \"%s\" on %s is unknown. %s\nBackends with history: %s",
+		q.backend, q.location, date, hint, strings.Join(names, ", "))
+}
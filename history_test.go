@@ -0,0 +1,76 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestHistoryStore(t *testing.T) {
+	h := &historyStore{filepath.Join(t.TempDir(), "wego", "history.jsonl"), true}
+	cest := time.FixedZone("CEST", 2*3600)
+	temp := func(v float32) *float32 { return &v }
+
+	if _, ok, err := h.History("Paris", time.Date(2024, 7, 14, 0, 0, 0, 0, cest)); ok || err != nil {
+		t.Fatalf("empty history gave %v, %v", ok, err)
+	}
+	for _, e := range []historyEntry{
+		{"smhi", "Paris", iface.Cond{Time: time.Date(2024, 7, 14, 15, 0, 0, 0, cest), TempC: temp(31)}},
+		{"yr", "paris", iface.Cond{Time: time.Date(2024, 7, 14, 9, 0, 0, 0, cest), TempC: temp(24)}},
+		{"yr", "Paris", iface.Cond{Time: time.Date(2024, 7, 14, 15, 0, 0, 0, cest), TempC: temp(30)}},
+		{"yr", "Paris", iface.Cond{Time: time.Date(2024, 7, 15, 9, 0, 0, 0, cest), TempC: temp(20)}},
+		{"yr", "Oslo", iface.Cond{Time: time.Date(2024, 7, 14, 12, 0, 0, 0, cest), TempC: temp(18)}},
+	} {
+		if err := h.add(query{e.Location, e.Backend, 1}, iface.Data{Current: e.Cond}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	data, ok, err := h.History("Paris", time.Date(2024, 7, 14, 0, 0, 0, 0, cest))
+	if !ok || err != nil {
+		t.Fatalf("History gave %v, %v", ok, err)
+	}
+	if len(data.Forecast) != 1 || !data.Forecast[0].Date.Equal(time.Date(2024, 7, 14, 0, 0, 0, 0, cest)) {
+		t.Fatalf("History returned the days %v", data.Forecast)
+	}
+	var temps []float32
+	for _, s := range data.Forecast[0].Slots {
+		temps = append(temps, *s.TempC)
+	}
+	if len(temps) != 2 || temps[0] != 24 || temps[1] != 31 {
+		t.Errorf("History returned the temperatures %v, want [24 31]", temps)
+	}
+
+	h.record = false
+	if err := h.add(query{"Paris", "yr", 1}, iface.Data{Current: iface.Cond{Time: time.Now()}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := h.History("Paris", time.Now()); ok {
+		t.Errorf("the history was recorded although it is disabled")
+	}
+}
+
+func TestFetchHistoryErrors(t *testing.T) {
+	iface.AllBackends["test"] = capableBackend{}
+	defer delete(iface.AllBackends, "test")
+	a := &app{history: &historyStore{filepath.Join(t.TempDir(), "history.jsonl"), false}}
+	qs := []query{{"Paris", "test", 1}}
+
+	tests := []struct {
+		date string
+		want string
+	}{
+		{"14.07.2024", "use the format YYYY-MM-DD"},
+		{time.Now().Format("2006-01-02"), "is not in the past"},
+		{"2024-07-14", `The backend "test" has no weather history`},
+		{"2024-07-14", "Enable -history"},
+	}
+	for _, tt := range tests {
+		if _, err := a.fetchHistory(qs, tt.date); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("fetchHistory(%s) = %v, want an error containing %q", tt.date, err, tt.want)
+		}
+	}
+}
@@ -1,7 +1,10 @@
 package iface
 
 import (
+	"io"
 	"log"
+	"net/http"
+	"regexp"
 	"time"
 )
 
@@ -75,6 +78,18 @@ type Cond struct {
 
 	// Humidity is the *relative* humidity and must be in [0, 100].
 	Humidity *int
+
+	// DewPointC, HeatIndexC, HumidexC and WetBulbC are further temperatures in
+	// degrees celsius. Backends rarely provide them, they are usually computed
+	// by the derive package.
+	DewPointC  *float32 `json:",omitempty"`
+	HeatIndexC *float32 `json:",omitempty"`
+	HumidexC   *float32 `json:",omitempty"`
+	WetBulbC   *float32 `json:",omitempty"`
+
+	// Derived lists the names of the fields which were computed from other
+	// fields instead of being supplied by the backend.
+	Derived []string `json:",omitempty"`
 }
 
 type Astro struct {
@@ -89,11 +104,40 @@ type Day struct {
 	Date time.Time
 
 	// Slots is a slice of conditions for different times of day. They should be
-	// ordered by the contained Time field.
+	// ordered by the contained Time field. Long-range forecasts may have only a
+	// Summary and no Slots for the later days.
 	Slots []Cond
 
 	// Astronomy contains planetary data.
 	Astronomy Astro
+
+	// Summary aggregates the conditions of the whole day. Backends which
+	// provide daily aggregates set it, the missing fields are computed from
+	// the Slots by the derive package.
+	Summary *Summary `json:",omitempty"`
+}
+
+// Summary aggregates the conditions of a Day. Fields are nil if unknown.
+type Summary struct {
+	MinTempC *float32
+	MaxTempC *float32
+
+	// PrecipM is the total precipitation of the day in meters(!). It must be
+	// >= 0.
+	PrecipM *float32
+
+	// MaxChanceOfRainPercent is the highest probability of rain or snow during
+	// the day. It must be in the range [0, 100].
+	MaxChanceOfRainPercent *int
+
+	MaxWindspeedKmph *float32
+	MaxWindGustKmph  *float32
+
+	// Code is the weather condition prevailing during the day.
+	Code WeatherCode
+
+	// SunshineHours is the duration of sunshine during the day.
+	SunshineHours *float32
 }
 
 type LatLon struct {
@@ -101,11 +145,58 @@ type LatLon struct {
 	Longitude float32
 }
 
+// Alert is an official weather warning for the location.
+type Alert struct {
+	Title       string
+	Description string
+
+	// Severity is one of "minor", "moderate", "severe" and "extreme", or empty
+	// if the backend does not classify its alerts.
+	Severity string
+
+	// Sender is the agency issuing the alert.
+	Sender string
+
+	// Start and End limit the period the alert is valid for. They are zero if
+	// unknown.
+	Start time.Time
+	End   time.Time
+}
+
+// Nowcast is a short-term forecast of the precipitation in steps of a few
+// minutes, usually for the next one to two hours.
+type Nowcast struct {
+	// Start is the beginning of the first step.
+	Start time.Time
+
+	// Step is the duration of each step.
+	Step time.Duration
+
+	// PrecipM is the precipitation intensity of each step in meters(!) per
+	// hour. The values must be >= 0.
+	PrecipM []float32
+
+	// Desc optionally describes the nowcast in one sentence.
+	Desc string `json:",omitempty"`
+}
+
 type Data struct {
 	Current  Cond
 	Forecast []Day
 	Location string
 	GeoLoc   *LatLon
+
+	// Alerts is empty if there are no alerts or the backend does not support
+	// them.
+	Alerts []Alert `json:",omitempty"`
+
+	// Nowcast is nil if the backend does not support it or has none for the
+	// location.
+	Nowcast *Nowcast `json:",omitempty"`
+
+	// Backend is the name of the backend the data was fetched from. Backends
+	// leave it empty, wego sets it before rendering.
+	Backend string `json:",omitempty"`
 }
 
 type UnitSystem int
@@ -166,15 +257,122 @@ func (u UnitSystem) Distance(distM float32) (res float32, unit string) {
 
 type Backend interface {
 	Setup()
-	Fetch(location string, numdays int) Data
+
+	// Fetch returns the forecast of location for numdays days. Unsupported
+	// locations and failed requests are returned as an error, so long running
+	// commands like serve survive them.
+	Fetch(location string, numdays int) (Data, error)
+}
+
+// Location formats a backend can accept.
+const (
+	// LocationCoordinates is a "LATITUDE,LONGITUDE" pair like "59.329,18.068".
+	LocationCoordinates = "coordinates"
+	// LocationPostcode is a location starting with a digit, like "10115".
+	LocationPostcode = "postcode"
+	// LocationName is the name of a place like "Stockholm".
+	LocationName = "name"
+	// LocationFile is the path of a local file.
+	LocationFile = "file"
+)
+
+var coordinatesRegexp = regexp.MustCompile(`^-?([0-9]+(\.[0-9]+)?|\.[0-9]+),-?([0-9]+(\.[0-9]+)?|\.[0-9]+)$`)
+
+// LocationFormat returns the format of location, which is one of
+// LocationCoordinates, LocationPostcode and LocationName. Any location may be a
+// LocationFile.
+func LocationFormat(location string) string {
+	if coordinatesRegexp.MatchString(location) {
+		return LocationCoordinates
+	} else if location != "" && location[0] >= '0' && location[0] <= '9' {
+		return LocationPostcode
+	}
+	return LocationName
+}
+
+// Capabilities describes the data a backend provides and the input it accepts.
+type Capabilities struct {
+	// Fields lists the names of the Cond fields the backend fills, besides
+	// Time and Code.
+	Fields []string
+
+	// MaxDays is the maximum number of days the backend can forecast, or 0 if
+	// there is no limit.
+	MaxDays int
+
+	// Resolution is the time between two forecast slots of a day.
+	Resolution time.Duration
+
+	// Region describes where the backend has forecasts, like "worldwide".
+	Region string
+
+	// KeyFlag is the name of the flag holding the api key, or empty if the
+	// backend does not need one.
+	KeyFlag string
+
+	// LocationFormats lists the Location... formats the backend accepts.
+	LocationFormats []string
+
+	// LanguageFlag is the name of the flag selecting the language of the
+	// descriptions, which must be one of Languages. Both are empty if the
+	// backend has no language option.
+	LanguageFlag string
+	Languages    []string
+
+	// Alerts is set if the backend reports official weather warnings.
+	Alerts bool
+
+	// Nowcast is set if the backend can fill Data.Nowcast, possibly only for
+	// parts of its Region. NowcastFlag is the name of the boolean flag
+	// requesting it, if the backend only does so on demand.
+	Nowcast     bool
+	NowcastFlag string
+}
+
+// AcceptsLocation reports whether the backend accepts the format of location.
+func (c Capabilities) AcceptsLocation(location string) bool {
+	format := LocationFormat(location)
+	for _, f := range c.LocationFormats {
+		if f == format || f == LocationFile {
+			return true
+		}
+	}
+	return false
+}
+
+// CapableBackend is implemented by backends which describe their capabilities.
+type CapableBackend interface {
+	Backend
+	Capabilities() Capabilities
+}
+
+// HistoricalBackend is implemented by backends which can look up the weather of
+// past days.
+type HistoricalBackend interface {
+	Backend
+
+	// History returns the weather at location on the day of date as the only
+	// Day of the Forecast. Current is left empty.
+	History(location string, date time.Time) (Data, error)
 }
 
 type Frontend interface {
 	Setup()
-	Render(weather Data, unitSystem UnitSystem)
+	Render(w io.Writer, weather Data, unitSystem UnitSystem) error
+}
+
+// MultiFrontend is implemented by frontends which can present the forecasts of
+// several locations together. Other frontends render them one after another.
+type MultiFrontend interface {
+	Frontend
+	RenderMulti(w io.Writer, weather []Data, unitSystem UnitSystem) error
 }
 
 var (
 	AllBackends  = make(map[string]Backend)
 	AllFrontends = make(map[string]Frontend)
 )
+
+// HTTPClient is used by all backends for their requests, so the traffic can be
+// recorded or replayed by replacing its transport.
+var HTTPClient = &http.Client{}
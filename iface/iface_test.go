@@ -0,0 +1,26 @@
+package iface
+
+import "testing"
+
+func TestLocationFormat(t *testing.T) {
+	for location, want := range map[string]string{
+		"59.329,18.068": LocationCoordinates,
+		"-33.87,151.21": LocationCoordinates,
+		"48,11":         LocationCoordinates,
+		".5,-.25":       LocationCoordinates,
+		"10115":         LocationPostcode,
+		"1.,2":          LocationPostcode,
+		"Stockholm":     LocationName,
+		"":              LocationName,
+		",":             LocationName,
+		"-,-":           LocationName,
+		".5,":           LocationName,
+		"59.329,":       LocationPostcode,
+		"New York, NY":  LocationName,
+		"forecast.json": LocationName,
+	} {
+		if got := LocationFormat(location); got != want {
+			t.Errorf("LocationFormat(%q) = %s, want %s", location, got, want)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package iface
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Normalize enforces the documented ranges of the Cond fields, orders the days
+// and slots by time, drops duplicate and empty days and duplicate slots, and
+// trims the forecast to numdays days if numdays > 0. It returns the cleaned
+// data and a description of every change it made, so frontends can rely on the
+// contract even if a backend violates it.
+func Normalize(data Data, numdays int) (Data, []string) {
+	var warnings []string
+	warn := func(format string, a ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf(format, a...))
+	}
+
+	data.Current = normalizeCond(data.Current, "current", warn)
+	if data.Nowcast != nil {
+		data.Nowcast = normalizeNowcast(*data.Nowcast, warn)
+	}
+
+	days := make([]Day, 0, len(data.Forecast))
+	for _, day := range data.Forecast {
+		slots := make([]Cond, len(day.Slots))
+		for i, slot := range day.Slots {
+			slots[i] = normalizeCond(slot, day.Date.Format("Jan 02")+" "+slot.Time.Format("15:04"), warn)
+		}
+		if !sort.SliceIsSorted(slots, func(i, j int) bool { return slots[i].Time.Before(slots[j].Time) }) {
+			warn("%s: slots sorted by time", day.Date.Format("Jan 02"))
+			sort.SliceStable(slots, func(i, j int) bool { return slots[i].Time.Before(slots[j].Time) })
+		}
+		day.Slots = slots[:0]
+		for i, slot := range slots {
+			if i > 0 && slot.Time.Equal(slots[i-1].Time) {
+				warn("%s: duplicate slot dropped", slot.Time.Format("Jan 02 15:04"))
+				continue
+			}
+			day.Slots = append(day.Slots, slot)
+		}
+		if day.Summary != nil {
+			day.Summary = normalizeSummary(*day.Summary, day.Date.Format("Jan 02"), warn)
+		}
+		if len(day.Slots) == 0 && day.Summary == nil {
+			warn("%s: day without slots or summary dropped", day.Date.Format("Jan 02"))
+			continue
+		}
+		days = append(days, day)
+	}
+
+	if !sort.SliceIsSorted(days, func(i, j int) bool { return days[i].Date.Before(days[j].Date) }) {
+		warn("days sorted by date")
+		sort.SliceStable(days, func(i, j int) bool { return days[i].Date.Before(days[j].Date) })
+	}
+	data.Forecast = days[:0]
+	for i, day := range days {
+		if i > 0 && day.Date.Format("2006-01-02") == days[i-1].Date.Format("2006-01-02") {
+			warn("%s: duplicate day dropped", day.Date.Format("Jan 02"))
+			continue
+		}
+		data.Forecast = append(data.Forecast, day)
+	}
+
+	if numdays > 0 && len(data.Forecast) > numdays {
+		warn("forecast trimmed from %d to %d days", len(data.Forecast), numdays)
+		data.Forecast = data.Forecast[:numdays]
+	}
+	return data, warnings
+}
+
+// normalizeCond returns c with all fields in their documented ranges. Values
+// which are not numbers are removed, others are clamped. The pointers of c are
+// not written to, as they may be shared with the backend.
+func normalizeCond(c Cond, where string, warn func(string, ...interface{})) Cond {
+	if c.Code < CodeUnknown || c.Code > CodeVeryCloudy {
+		warn("%s: unknown weather code %d", where, c.Code)
+		c.Code = CodeUnknown
+	}
+	c.TempC = finite(c.TempC, where, "TempC", warn)
+	c.FeelsLikeC = finite(c.FeelsLikeC, where, "FeelsLikeC", warn)
+	c.DewPointC = finite(c.DewPointC, where, "DewPointC", warn)
+	c.HeatIndexC = finite(c.HeatIndexC, where, "HeatIndexC", warn)
+	c.HumidexC = finite(c.HumidexC, where, "HumidexC", warn)
+	c.WetBulbC = finite(c.WetBulbC, where, "WetBulbC", warn)
+	c.PrecipM = atLeastZero(finite(c.PrecipM, where, "PrecipM", warn), where, "PrecipM", warn)
+	c.VisibleDistM = atLeastZero(finite(c.VisibleDistM, where, "VisibleDistM", warn), where, "VisibleDistM", warn)
+	c.WindspeedKmph = atLeastZero(finite(c.WindspeedKmph, where, "WindspeedKmph", warn), where, "WindspeedKmph", warn)
+	c.WindGustKmph = atLeastZero(finite(c.WindGustKmph, where, "WindGustKmph", warn), where, "WindGustKmph", warn)
+	c.ChanceOfRainPercent = percent(c.ChanceOfRainPercent, where, "ChanceOfRainPercent", warn)
+	c.Humidity = percent(c.Humidity, where, "Humidity", warn)
+
+	if p := c.WinddirDegree; p != nil && (*p < 0 || *p > 359) {
+		dir := (*p%360 + 360) % 360
+		warn("%s: WinddirDegree %d wrapped to %d", where, *p, dir)
+		c.WinddirDegree = &dir
+	}
+	return c
+}
+
+// normalizeSummary is the equivalent of normalizeCond for the daily summary.
+func normalizeSummary(s Summary, where string, warn func(string, ...interface{})) *Summary {
+	if s.Code < CodeUnknown || s.Code > CodeVeryCloudy {
+		warn("%s: unknown weather code %d", where, s.Code)
+		s.Code = CodeUnknown
+	}
+	s.MinTempC = finite(s.MinTempC, where, "MinTempC", warn)
+	s.MaxTempC = finite(s.MaxTempC, where, "MaxTempC", warn)
+	s.PrecipM = atLeastZero(finite(s.PrecipM, where, "PrecipM", warn), where, "PrecipM", warn)
+	s.MaxWindspeedKmph = atLeastZero(finite(s.MaxWindspeedKmph, where, "MaxWindspeedKmph", warn), where, "MaxWindspeedKmph", warn)
+	s.MaxWindGustKmph = atLeastZero(finite(s.MaxWindGustKmph, where, "MaxWindGustKmph", warn), where, "MaxWindGustKmph", warn)
+	s.SunshineHours = atLeastZero(finite(s.SunshineHours, where, "SunshineHours", warn), where, "SunshineHours", warn)
+	s.MaxChanceOfRainPercent = percent(s.MaxChanceOfRainPercent, where, "MaxChanceOfRainPercent", warn)
+	return &s
+}
+
+// normalizeNowcast returns n with all values in their documented ranges, or nil
+// if it has no valid steps.
+func normalizeNowcast(n Nowcast, warn func(string, ...interface{})) *Nowcast {
+	if n.Step <= 0 || len(n.PrecipM) == 0 {
+		warn("nowcast: dropped without steps")
+		return nil
+	}
+	values := make([]float32, len(n.PrecipM))
+	for i := range n.PrecipM {
+		where := "nowcast " + n.Start.Add(time.Duration(i)*n.Step).Format("15:04")
+		if p := atLeastZero(finite(&n.PrecipM[i], where, "PrecipM", warn), where, "PrecipM", warn); p != nil {
+			values[i] = *p
+		}
+	}
+	n.PrecipM = values
+	return &n
+}
+
+func finite(p *float32, where, field string, warn func(string, ...interface{})) *float32 {
+	if p != nil && (math.IsNaN(float64(*p)) || math.IsInf(float64(*p), 0)) {
+		warn("%s: %s %v removed", where, field, *p)
+		return nil
+	}
+	return p
+}
+
+func atLeastZero(p *float32, where, field string, warn func(string, ...interface{})) *float32 {
+	if p != nil && *p < 0 {
+		warn("%s: %s %v clamped to 0", where, field, *p)
+		var zero float32
+		return &zero
+	}
+	return p
+}
+
+func percent(p *int, where, field string, warn func(string, ...interface{})) *int {
+	if p == nil || (*p >= 0 && *p <= 100) {
+		return p
+	}
+	v := 0
+	if *p > 100 {
+		v = 100
+	}
+	warn("%s: %s %d clamped to %d", where, field, *p, v)
+	return &v
+}
@@ -0,0 +1,74 @@
+package iface
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalize(t *testing.T) {
+	f := func(v float32) *float32 { return &v }
+	i := func(v int) *int { return &v }
+	at := func(day, hour int) time.Time { return time.Date(2024, 7, day, hour, 0, 0, 0, time.UTC) }
+
+	data := Data{
+		Current: Cond{Code: 99, TempC: f(float32(math.NaN())), WinddirDegree: i(360), Humidity: i(104)},
+		Nowcast: &Nowcast{Start: at(14, 12), Step: 5 * time.Minute, PrecipM: []float32{0.001, -0.002}},
+		Forecast: []Day{
+			{Date: at(15, 0), Slots: []Cond{{Time: at(15, 9), PrecipM: f(-0.001)}}, Summary: &Summary{MaxChanceOfRainPercent: i(120)}},
+			{Date: at(14, 0), Slots: []Cond{
+				{Time: at(14, 12), ChanceOfRainPercent: i(-5)},
+				{Time: at(14, 6), WinddirDegree: i(-90)},
+				{Time: at(14, 12)},
+			}},
+			{Date: at(14, 0)},
+			{Date: at(16, 0), Slots: []Cond{{Time: at(16, 9)}}},
+			{Date: at(16, 0), Slots: []Cond{{Time: at(16, 12)}}},
+			{Date: at(17, 0), Summary: &Summary{MaxTempC: f(21)}},
+		},
+	}
+	got, warnings := Normalize(data, 2)
+
+	if got.Current.Code != CodeUnknown || got.Current.TempC != nil || *got.Current.WinddirDegree != 0 || *got.Current.Humidity != 100 {
+		t.Errorf("current conditions not normalized: %+v", got.Current)
+	}
+	if len(got.Forecast) != 2 || !got.Forecast[0].Date.Equal(at(14, 0)) || !got.Forecast[1].Date.Equal(at(15, 0)) {
+		t.Fatalf("got days %+v", got.Forecast)
+	}
+	slots := got.Forecast[0].Slots
+	if len(slots) != 2 || !slots[0].Time.Equal(at(14, 6)) || *slots[0].WinddirDegree != 270 || *slots[1].ChanceOfRainPercent != 0 {
+		t.Errorf("got slots %+v", slots)
+	}
+	if *got.Forecast[1].Slots[0].PrecipM != 0 {
+		t.Errorf("negative precipitation was not clamped")
+	}
+	if got.Nowcast.PrecipM[1] != 0 || data.Nowcast.PrecipM[1] >= 0 {
+		t.Errorf("got nowcast %v from %v", got.Nowcast.PrecipM, data.Nowcast.PrecipM)
+	}
+	if *data.Forecast[0].Slots[0].PrecipM >= 0 {
+		t.Errorf("the input data was modified")
+	}
+
+	for _, want := range []string{
+		"current: unknown weather code 99",
+		"current: TempC NaN removed",
+		"WinddirDegree 360 wrapped to 0",
+		"nowcast 12:05: PrecipM -0.002 clamped to 0",
+		"Jul 14: slots sorted by time",
+		"Jul 15: MaxChanceOfRainPercent 120 clamped to 100",
+		"Jul 14 12:00: duplicate slot dropped",
+		"Jul 14: day without slots or summary dropped",
+		"days sorted by date",
+		"Jul 16: duplicate day dropped",
+		"forecast trimmed from 4 to 2 days",
+	} {
+		if !strings.Contains(strings.Join(warnings, "\n"), want) {
+			t.Errorf("warnings do not contain %q:\n%s", want, strings.Join(warnings, "\n"))
+		}
+	}
+
+	if _, warnings := Normalize(got, 2); len(warnings) > 0 {
+		t.Errorf("normalized data is not clean:\n%s", strings.Join(warnings, "\n"))
+	}
+}
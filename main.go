@@ -5,13 +5,12 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"sort"
-	"strconv"
 	"strings"
+	"text/tabwriter"
 
-	"github.com/schachmat/ingo"
 	_ "github.com/schachmat/wego/backends"
-	_ "github.com/schachmat/wego/frontends"
 	"github.com/schachmat/wego/iface"
 )
 
@@ -28,17 +27,138 @@ func pluginLists() {
 	}
 	sort.Strings(fEnds)
 
-	fmt.Fprintln(os.Stderr, "Available backends:", strings.Join(bEnds, ", "))
+	w := tabwriter.NewWriter(os.Stderr, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Available backends:")
+	for _, name := range bEnds {
+		summary := ""
+		if be, ok := iface.AllBackends[name].(iface.CapableBackend); ok {
+			summary = capabilitySummary(be.Capabilities())
+		}
+		fmt.Fprintf(w, "  %s\t%s\n", name, summary)
+	}
+	w.Flush()
 	fmt.Fprintln(os.Stderr, "Available frontends:", strings.Join(fEnds, ", "))
 }
 
+// parseUnitSystem returns the unit system with the given name.
+func parseUnitSystem(name string) (iface.UnitSystem, bool) {
+	switch name {
+	case "metric":
+		return iface.UnitsMetric, true
+	case "imperial":
+		return iface.UnitsImperial, true
+	case "si":
+		return iface.UnitsSi, true
+	case "metric-ms":
+		return iface.UnitsMetricMs, true
+	}
+	return iface.UnitsMetric, false
+}
+
+// query is a location to fetch along with the backend and number of days to
+// use for it.
+type query struct {
+	location string
+	backend  string
+	numdays  int
+}
+
+// app holds the global settings shared by the subcommands.
+type app struct {
+	location    *string
+	numdays     *int
+	unitSystem  *string
+	backend     *string
+	frontend    *string
+	output      *string
+	interactive *bool
+	strict      *bool
+	bookmarks   *presetList
+	profiles    *presetList
+	cache       *diskCache
+	date        *string
+	history     *historyStore
+	archive     *forecastArchive
+
+	// cli contains the flags given on the command line
+	cli map[flag.Value]bool
+}
+
+// command is a subcommand of wego. Global commands accept the global flags
+// after their name as well. Early commands run before the config files are
+// loaded and get no app.
+type command struct {
+	run    func(a *app, args []string) error
+	global bool
+	usage  string
+	early  bool
+}
+
+// commands is filled in init, because some commands refer to it.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"forecast":   {(*app).forecast, true, "[DAYS] [LOCATION...]\tforecast rendered by the selected frontend (default)", false},
+		"now":        {(*app).now, true, "[LOCATION...]\tcurrent conditions", false},
+		"hourly":     {(*app).hourly, true, "[DAYS] [LOCATION...]\tall forecast slots as a list", false},
+		"astro":      {(*app).astro, true, "[DAYS] [LOCATION...]\tsunrise, sunset, moonrise and moonset", false},
+		"alerts":     {(*app).alerts, true, "[LOCATION...]\tofficial weather warnings", false},
+		"diff":       {(*app).diff, true, "[DATE] [LOCATION...]\tchanges of the forecast for a day, tomorrow by default, since yesterday", false},
+		"nowcast":    {(*app).nowcast, true, "[LOCATION...]\tprecipitation of the next one to two hours", false},
+		"backends":   {(*app).backends, false, "[-verbose]\tlist the backends and the api keys they need", false},
+		"config":     {(*app).config, false, "migrate|check\tmanage the structured config file", true},
+		"completion": {(*app).completion, false, "bash|zsh|fish\tprint a shell completion script", false},
+		"cache":      {(*app).cacheCommand, false, "list|clear|path\tmanage the forecast cache", false},
+		"verify":     {(*app).verify, false, "[-json] [-observed BACKENDS] [LOCATION...]\taccuracy of the archived forecasts of each backend", false},
+		"serve":      {(*app).serve, false, "[-listen ADDRESS] [-cache-ttl DURATION]\tanswer forecast requests over http", false},
+		"exporter":   {(*app).exporter, false, "[-listen ADDRESS] [-interval DURATION] [-location LOCATION]...\tserve the weather as Prometheus metrics", false},
+	}
+}
+
+// selectCommand returns the command named by the first argument left after
+// parsing the flags and its arguments. Without a known command name the
+// arguments are passed to forecast. The global flags given after the name of a
+// global command are parsed as well, which is reported by reparse.
+func selectCommand() (name string, args []string, reparse bool) {
+	name, args = "forecast", flag.Args()
+	if len(args) == 0 {
+		return name, args, false
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		return name, args, false
+	}
+	name, args = args[0], args[1:]
+	if cmd.global {
+		flag.CommandLine.Parse(args)
+		return name, flag.Args(), true
+	}
+	return name, args, false
+}
+
+func commandList() {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	w := tabwriter.NewWriter(os.Stderr, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  wego [FLAGS] %s %s\n", name, commands[name].usage)
+	}
+	w.Flush()
+}
+
 func main() {
 	// initialize backends and frontends (flags and default config)
-	for _, be := range iface.AllBackends {
-		be.Setup()
+	for name, be := range iface.AllBackends {
+		setupPlugin("backends."+name, be.Setup)
 	}
-	for _, fe := range iface.AllFrontends {
-		fe.Setup()
+	for name, fe := range iface.AllFrontends {
+		setupPlugin("frontends."+name, fe.Setup)
 	}
 
 	// initialize global flags and default config
@@ -52,49 +172,103 @@ func main() {
 	flag.StringVar(selectedBackend, "b", "openweathermap", "`BACKEND` to be used (shorthand)")
 	selectedFrontend := flag.String("frontend", "ascii-art-table", "`FRONTEND` to be used")
 	flag.StringVar(selectedFrontend, "f", "ascii-art-table", "`FRONTEND` to be used (shorthand)")
+	output := flag.String("output", "", "`FILE` to write the output of the frontend to instead of stdout")
+	flag.StringVar(output, "o", "", "`FILE` to write the output of the frontend to instead of stdout (shorthand)")
+	bookmarks := &presetList{bookmarks: true}
+	flag.Var(bookmarks, "bookmarks", "`BOOKMARKS` usable instead of a location, separated by \";\".\n    \tExample: home=59.91,10.75;cabin=61.1,8.3|backend=smhi|days=5")
+	profiles := &presetList{}
+	flag.Var(profiles, "profiles", "`PROFILES` grouping flag settings, separated by \";\".\n    \tExample: sailing=frontend=graph|graph-height=12")
+	profile := flag.String("profile", "", "`PROFILE` to apply, command line flags take precedence")
+	interactive := flag.Bool("interactive", false, "run an interactive full screen interface instead of a frontend")
+	flag.BoolVar(interactive, "i", false, "run an interactive full screen interface instead of a frontend (shorthand)")
+	strict := flag.Bool("strict", false, "fail if the backend data violates the documented ranges instead of fixing it silently")
+	record := flag.String("record", "", "`DIR` to save the http traffic of the backends to, with api keys redacted")
+	replay := flag.String("replay", "", "`DIR` with recorded http traffic to answer the requests of the backends from")
+	cacheMaxAge := flag.Duration("cache-max-age", 0, "`DURATION` to reuse fetched forecasts for, 0 disables the cache")
+	date := flag.String("date", "", "past `DATE` like 2024-07-14 to show the weather of instead of the forecast")
+	history := flag.Bool("history", false, "record the current conditions of every fetch in the local history for -date")
+	archive := flag.Bool("archive", false, "keep every fetched forecast in the local archive for the diff command")
 
-	// print out a list of all backends and frontends in the usage
+	// print out a list of all commands, backends and frontends in the usage
 	tmpUsage := flag.Usage
 	flag.Usage = func() {
 		tmpUsage()
+		commandList()
 		pluginLists()
 	}
 
 	// read/write config and parse flags
-	if err := ingo.Parse("wego"); err != nil {
+	if err := parseLegacyConfig(); err != nil {
 		log.Fatalf("Error parsing config: %v", err)
 	}
 
-	// non-flag shortcut arguments overwrite possible flag arguments
-	for _, arg := range flag.Args() {
-		if v, err := strconv.Atoi(arg); err == nil && len(arg) == 1 {
-			*numdays = v
-		} else {
-			*location = arg
+	name, args, reparse := selectCommand()
+	if commands[name].early {
+		if err := commands[name].run(nil, args); err != nil {
+			log.Fatal(err)
 		}
+		return
 	}
 
-	// get selected backend and fetch the weather data from it
-	be, ok := iface.AllBackends[*selectedBackend]
-	if !ok {
-		log.Fatalf("Could not find selected backend \"%s\"", *selectedBackend)
+	// the environment precedes the structured config, which precedes the legacy
+	// one. The command line precedes all of them.
+	cli := commandLineFlags(reparse)
+	if path, err := structuredConfigPath(); err != nil {
+		log.Fatalf("Error locating config: %v", err)
+	} else if err := loadStructuredConfig(path, cli); err != nil {
+		log.Fatalf("Error parsing config: %v", err)
+	}
+	env, err := loadEnvironment(os.Environ(), cli)
+	if err != nil {
+		log.Fatalf("Error parsing environment: %v", err)
 	}
-	r := be.Fetch(*location, *numdays)
 
-	// set unit system
-	unit := iface.UnitsMetric
-	if *unitSystem == "imperial" {
-		unit = iface.UnitsImperial
-	} else if *unitSystem == "si" {
-		unit = iface.UnitsSi
-	} else if *unitSystem == "metric-ms" {
-		unit = iface.UnitsMetricMs
+	// settings of a profile precede the config files, but not the environment
+	// and the command line
+	if *profile != "" {
+		if err := profiles.applyProfile(*profile, cli, env); err != nil {
+			log.Fatal(err)
+		}
 	}
 
-	// get selected frontend and render the weather data with it
-	fe, ok := iface.AllFrontends[*selectedFrontend]
-	if !ok {
-		log.Fatalf("Could not find selected frontend \"%s\"", *selectedFrontend)
+	switch {
+	case *record != "" && *replay != "":
+		log.Fatal("Use only one of -record and -replay")
+	case *record != "":
+		err = setupTape(*record, false)
+	case *replay != "":
+		err = setupTape(*replay, true)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir, err := defaultCacheDir()
+	if err != nil && *cacheMaxAge > 0 {
+		log.Fatalf("Error locating cache: %v", err)
+	}
+	dataDir, err := defaultDataDir()
+	if err != nil && (*history || *archive) {
+		log.Fatalf("Error locating the data directory: %v", err)
+	}
+	a := &app{
+		location:    location,
+		numdays:     numdays,
+		unitSystem:  unitSystem,
+		backend:     selectedBackend,
+		frontend:    selectedFrontend,
+		output:      output,
+		interactive: interactive,
+		strict:      strict,
+		bookmarks:   bookmarks,
+		profiles:    profiles,
+		cache:       &diskCache{dir, *cacheMaxAge},
+		date:        date,
+		history:     &historyStore{filepath.Join(dataDir, "history.jsonl"), *history},
+		archive:     &forecastArchive{filepath.Join(dataDir, "archive"), *archive},
+		cli:         cli,
+	}
+	if err := commands[name].run(a, args); err != nil {
+		log.Fatal(err)
 	}
-	fe.Render(r, unit)
 }
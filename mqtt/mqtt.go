@@ -0,0 +1,243 @@
+// Package mqtt is a minimal MQTT 3.1.1 client. It supports what wego needs:
+// publishing and subscribing with QoS 0 over plain TCP. There is no TLS, so
+// the user name and password are sent in cleartext.
+package mqtt
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+// Types of the control packets.
+const (
+	Connect    byte = 1
+	Connack    byte = 2
+	Publish    byte = 3
+	Subscribe  byte = 8
+	Suback     byte = 9
+	Disconnect byte = 14
+)
+
+const (
+	flagRetain  byte = 0x01
+	maxBodySize      = 1 << 20
+)
+
+// Packet is an MQTT control packet. Flags are the lower four bits of the
+// first byte and Body is everything after the remaining length.
+type Packet struct {
+	Type  byte
+	Flags byte
+	Body  []byte
+}
+
+// ReadPacket reads the next control packet from r.
+func ReadPacket(r *bufio.Reader) (Packet, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return Packet{}, err
+	}
+	p := Packet{Type: b >> 4, Flags: b & 0x0f}
+	n, shift := 0, 0
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return p, err
+		}
+		n |= int(b&0x7f) << shift
+		if b&0x80 == 0 {
+			break
+		}
+		if shift += 7; shift > 21 {
+			return p, errors.New("mqtt: malformed remaining length")
+		}
+	}
+	if n > maxBodySize {
+		return p, fmt.Errorf("mqtt: packet of %d bytes is too large", n)
+	}
+	p.Body = make([]byte, n)
+	_, err = io.ReadFull(r, p.Body)
+	return p, err
+}
+
+// WritePacket writes p to w.
+func WritePacket(w io.Writer, p Packet) error {
+	buf := []byte{p.Type<<4 | p.Flags}
+	n := len(p.Body)
+	for {
+		b := byte(n & 0x7f)
+		if n >>= 7; n > 0 {
+			b |= 0x80
+		}
+		buf = append(buf, b)
+		if n == 0 {
+			break
+		}
+	}
+	_, err := w.Write(append(buf, p.Body...))
+	return err
+}
+
+// AppendString appends s with its length prefix, as strings are encoded in
+// MQTT.
+func AppendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint16(b, uint16(len(s)))
+	return append(b, s...)
+}
+
+// ReadString returns the length prefixed string at the start of b and the
+// rest of b.
+func ReadString(b []byte) (string, []byte, error) {
+	if len(b) < 2 || len(b) < 2+int(binary.BigEndian.Uint16(b)) {
+		return "", nil, errors.New("mqtt: truncated string")
+	}
+	n := 2 + int(binary.BigEndian.Uint16(b))
+	return string(b[2:n]), b[n:], nil
+}
+
+// Message is a message published to a topic.
+type Message struct {
+	Topic   string
+	Payload []byte
+	Retain  bool
+}
+
+// ParsePublish returns the message of a Publish packet with QoS 0.
+func ParsePublish(p Packet) (Message, error) {
+	topic, rest, err := ReadString(p.Body)
+	if err != nil {
+		return Message{}, err
+	}
+	if p.Flags&0x06 != 0 {
+		// skip the packet identifier of QoS 1 and 2
+		if len(rest) < 2 {
+			return Message{}, errors.New("mqtt: truncated publish")
+		}
+		rest = rest[2:]
+	}
+	return Message{topic, rest, p.Flags&flagRetain != 0}, nil
+}
+
+// Options configure the connection to the broker.
+type Options struct {
+	ClientID string
+	Username string
+	Password string
+	Timeout  time.Duration
+}
+
+// Client is a connection to a broker.
+type Client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// connackErrors describe the return codes of the broker refusing a
+// connection.
+var connackErrors = map[byte]string{
+	1: "unacceptable protocol version",
+	2: "client identifier rejected",
+	3: "server unavailable",
+	4: "bad user name or password",
+	5: "not authorized",
+}
+
+// Dial connects to the broker at addr, which is HOST:PORT.
+func Dial(addr string, opts Options) (*Client, error) {
+	if opts.Timeout == 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	conn, err := net.DialTimeout("tcp", addr, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn, bufio.NewReader(conn)}
+	conn.SetDeadline(time.Now().Add(opts.Timeout))
+
+	// clean session and a keep alive of one minute
+	flags := byte(0x02)
+	if opts.Username != "" {
+		flags |= 0x80
+	}
+	if opts.Password != "" {
+		flags |= 0x40
+	}
+	body := AppendString(nil, "MQTT")
+	body = append(body, 4, flags, 0, 60)
+	body = AppendString(body, opts.ClientID)
+	if opts.Username != "" {
+		body = AppendString(body, opts.Username)
+	}
+	if opts.Password != "" {
+		body = AppendString(body, opts.Password)
+	}
+	if err := WritePacket(conn, Packet{Type: Connect, Body: body}); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	p, err := ReadPacket(c.r)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if p.Type != Connack || len(p.Body) != 2 {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt: expected connack, got packet type %d", p.Type)
+	}
+	if code := p.Body[1]; code != 0 {
+		conn.Close()
+		return nil, fmt.Errorf("mqtt: connection refused: %s", connackErrors[code])
+	}
+	conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// Publish sends payload to topic. Retained messages are kept by the broker
+// and delivered to later subscribers.
+func (c *Client) Publish(topic string, payload []byte, retain bool) error {
+	var flags byte
+	if retain {
+		flags = flagRetain
+	}
+	return WritePacket(c.conn, Packet{Type: Publish, Flags: flags, Body: append(AppendString(nil, topic), payload...)})
+}
+
+// Subscribe subscribes to the topic filters. The messages are read with
+// Receive.
+func (c *Client) Subscribe(filters ...string) error {
+	body := []byte{0, 1}
+	for _, f := range filters {
+		body = append(AppendString(body, f), 0)
+	}
+	return WritePacket(c.conn, Packet{Type: Subscribe, Flags: 0x02, Body: body})
+}
+
+// Receive returns the next message of the subscribed topics. It fails when
+// the deadline set with SetDeadline passes.
+func (c *Client) Receive() (Message, error) {
+	for {
+		p, err := ReadPacket(c.r)
+		if err != nil {
+			return Message{}, err
+		}
+		if p.Type == Publish {
+			return ParsePublish(p)
+		}
+	}
+}
+
+// SetDeadline sets the deadline for all reads and writes.
+func (c *Client) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+// Close disconnects from the broker.
+func (c *Client) Close() error {
+	WritePacket(c.conn, Packet{Type: Disconnect})
+	return c.conn.Close()
+}
@@ -0,0 +1,84 @@
+package mqtt_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/mqtt"
+	"github.com/schachmat/wego/mqtt/mqtttest"
+)
+
+func TestPublishSubscribe(t *testing.T) {
+	b := mqtttest.NewBroker()
+	defer b.Close()
+	b.Retain("garden/temperature", "21.4")
+	b.Retain("garden/humidity", "58")
+	b.Retain("attic/temperature", "30")
+
+	sub, err := mqtt.Dial(b.Addr(), mqtt.Options{ClientID: "sub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if err := sub.Subscribe("garden/+", "wego/#"); err != nil {
+		t.Fatal(err)
+	}
+	sub.SetDeadline(time.Now().Add(5 * time.Second))
+
+	got := make(map[string]string)
+	for len(got) < 2 {
+		m, err := sub.Receive()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !m.Retain {
+			t.Errorf("message of %s is not marked as retained", m.Topic)
+		}
+		got[m.Topic] = string(m.Payload)
+	}
+	if got["garden/temperature"] != "21.4" || got["garden/humidity"] != "58" {
+		t.Errorf("got the retained messages %v", got)
+	}
+
+	pub, err := mqtt.Dial(b.Addr(), mqtt.Options{ClientID: "pub", Username: "wego", Password: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := pub.Publish("wego/oslo/current", []byte(`{"temperature":12}`), true); err != nil {
+		t.Fatal(err)
+	}
+	pub.Close()
+
+	m, err := sub.Receive()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Topic != "wego/oslo/current" || string(m.Payload) != `{"temperature":12}` || m.Retain {
+		t.Errorf("got %+v", m)
+	}
+	if p, ok := b.Retained("wego/oslo/current"); !ok || string(p) != `{"temperature":12}` {
+		t.Errorf("the broker retained %q, %v", p, ok)
+	}
+}
+
+func TestLongPayload(t *testing.T) {
+	b := mqtttest.NewBroker()
+	defer b.Close()
+	c, err := mqtt.Dial(b.Addr(), mqtt.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload := make([]byte, 300000)
+	if err := c.Publish("big", payload, false); err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+
+	m, ok := b.Wait("big", 5*time.Second)
+	if !ok {
+		t.Fatal("the message was not published")
+	}
+	if len(m.Payload) != len(payload) {
+		t.Errorf("payload of %d bytes arrived with %d bytes", len(payload), len(m.Payload))
+	}
+}
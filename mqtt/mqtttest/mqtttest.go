@@ -0,0 +1,209 @@
+// Package mqtttest provides an MQTT broker for tests, like net/http/httptest
+// does for http servers. It handles the subset of MQTT 3.1.1 the mqtt package
+// uses.
+package mqtttest
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schachmat/wego/mqtt"
+)
+
+// Broker is an MQTT broker listening on a local port.
+type Broker struct {
+	ln net.Listener
+
+	mu        sync.Mutex
+	retained  map[string][]byte
+	published []mqtt.Message
+	subs      map[net.Conn][]string
+	wg        sync.WaitGroup
+}
+
+// NewBroker starts a broker. It must be stopped with Close.
+func NewBroker() *Broker {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		panic("mqtttest: failed to listen: " + err.Error())
+	}
+	b := &Broker{ln: ln, retained: make(map[string][]byte), subs: make(map[net.Conn][]string)}
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			b.wg.Add(1)
+			go b.serve(conn)
+		}
+	}()
+	return b
+}
+
+// Addr is the HOST:PORT of the broker.
+func (b *Broker) Addr() string {
+	return b.ln.Addr().String()
+}
+
+// Close stops the broker and disconnects all clients.
+func (b *Broker) Close() {
+	b.ln.Close()
+	b.mu.Lock()
+	for conn := range b.subs {
+		conn.Close()
+	}
+	b.mu.Unlock()
+	b.wg.Wait()
+}
+
+// Retain stores a retained message for topic, as if a client published it.
+func (b *Broker) Retain(topic, payload string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.retained[topic] = []byte(payload)
+}
+
+// Retained returns the retained message of topic.
+func (b *Broker) Retained(topic string) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	p, ok := b.retained[topic]
+	return p, ok
+}
+
+// Published returns all messages published by clients so far.
+func (b *Broker) Published() []mqtt.Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]mqtt.Message(nil), b.published...)
+}
+
+// Wait waits up to timeout for a client to publish to topic and returns the
+// last message published to it. Messages arrive asynchronously, so tests
+// should wait for the last topic a client publishes to before checking them.
+func (b *Broker) Wait(topic string, timeout time.Duration) (mqtt.Message, bool) {
+	for deadline := time.Now().Add(timeout); ; time.Sleep(10 * time.Millisecond) {
+		ms := b.Published()
+		for i := len(ms) - 1; i >= 0; i-- {
+			if ms[i].Topic == topic {
+				return ms[i], true
+			}
+		}
+		if time.Now().After(deadline) {
+			return mqtt.Message{}, false
+		}
+	}
+}
+
+func (b *Broker) serve(conn net.Conn) {
+	defer b.wg.Done()
+	defer conn.Close()
+	b.mu.Lock()
+	b.subs[conn] = nil
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		delete(b.subs, conn)
+		b.mu.Unlock()
+	}()
+
+	r := bufio.NewReader(conn)
+	for {
+		p, err := mqtt.ReadPacket(r)
+		if err != nil {
+			return
+		}
+		switch p.Type {
+		case mqtt.Connect:
+			mqtt.WritePacket(conn, mqtt.Packet{Type: mqtt.Connack, Body: []byte{0, 0}})
+		case mqtt.Subscribe:
+			b.subscribe(conn, p)
+		case mqtt.Publish:
+			m, err := mqtt.ParsePublish(p)
+			if err != nil {
+				return
+			}
+			b.publish(m)
+		case mqtt.Disconnect:
+			return
+		}
+	}
+}
+
+func (b *Broker) subscribe(conn net.Conn, p mqtt.Packet) {
+	if len(p.Body) < 2 {
+		return
+	}
+	var filters []string
+	codes := []byte{}
+	for rest := p.Body[2:]; len(rest) > 0; {
+		f, r, err := mqtt.ReadString(rest)
+		if err != nil || len(r) == 0 {
+			return
+		}
+		filters, codes, rest = append(filters, f), append(codes, 0), r[1:]
+	}
+	mqtt.WritePacket(conn, mqtt.Packet{Type: mqtt.Suback, Body: append(p.Body[:2:2], codes...)})
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs[conn] = append(b.subs[conn], filters...)
+	for topic, payload := range b.retained {
+		for _, f := range filters {
+			if match(f, topic) {
+				send(conn, mqtt.Message{Topic: topic, Payload: payload, Retain: true})
+				break
+			}
+		}
+	}
+}
+
+func (b *Broker) publish(m mqtt.Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.published = append(b.published, m)
+	if m.Retain {
+		if len(m.Payload) == 0 {
+			delete(b.retained, m.Topic)
+		} else {
+			b.retained[m.Topic] = m.Payload
+		}
+	}
+	for conn, filters := range b.subs {
+		for _, f := range filters {
+			if match(f, m.Topic) {
+				send(conn, mqtt.Message{Topic: m.Topic, Payload: m.Payload})
+				break
+			}
+		}
+	}
+}
+
+func send(conn net.Conn, m mqtt.Message) {
+	var flags byte
+	if m.Retain {
+		flags = 1
+	}
+	mqtt.WritePacket(conn, mqtt.Packet{Type: mqtt.Publish, Flags: flags, Body: append(mqtt.AppendString(nil, m.Topic), m.Payload...)})
+}
+
+// match reports whether topic matches the filter, which may contain the
+// wildcards + and #.
+func match(filter, topic string) bool {
+	f, t := strings.Split(filter, "/"), strings.Split(topic, "/")
+	for i, level := range f {
+		if level == "#" {
+			return true
+		}
+		if i >= len(t) || (level != "+" && level != t[i]) {
+			return false
+		}
+	}
+	return len(f) == len(t)
+}
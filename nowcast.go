@@ -0,0 +1,151 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// sparkLevels are the bars of the sparkline and sparkThresholds the
+// precipitation in mm/h from which on the bar of the same index plus one is
+// drawn.
+var (
+	sparkLevels     = []rune(" ▁▂▃▄▅▆▇█")
+	sparkThresholds = []float32{0.1, 0.5, 1, 2, 4, 7.6, 15, 30}
+)
+
+// nowcastHeavyMmph is the intensity of heavy rain in mm/h.
+const nowcastHeavyMmph = 7.6
+
+// sparkWidth is the maximum number of bars of the sparkline.
+const sparkWidth = 60
+
+// sparkline draws the intensities of n as at most sparkWidth bars. If there
+// are more steps, every bar shows the maximum of the steps it covers.
+func sparkline(n iface.Nowcast) string {
+	per := (len(n.PrecipM) + sparkWidth - 1) / sparkWidth
+	var b strings.Builder
+	for i := 0; i < len(n.PrecipM); i += per {
+		var v float32
+		for _, p := range n.PrecipM[i:minInt(i+per, len(n.PrecipM))] {
+			if p > v {
+				v = p
+			}
+		}
+		level := sort.Search(len(sparkThresholds), func(j int) bool { return sparkThresholds[j] > v*1000 })
+		b.WriteRune(sparkLevels[level])
+	}
+	return b.String()
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// nowcastSummary describes when the rain of n starts, gets heavy and stops,
+// counted from now.
+func nowcastSummary(n iface.Nowcast, now time.Time) string {
+	wet := func(i int) bool { return n.PrecipM[i]*1000 >= sparkThresholds[0] }
+	at := func(i int) time.Time { return n.Start.Add(time.Duration(i) * n.Step) }
+	in := func(i int) int {
+		if d := at(i).Sub(now); d > 0 {
+			return int(d.Round(time.Minute) / time.Minute)
+		}
+		return 0
+	}
+
+	start := -1
+	for i := range n.PrecipM {
+		if wet(i) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return fmt.Sprintf("no rain for the next %d min", in(len(n.PrecipM)))
+	}
+	stop := -1
+	for i := start; i < len(n.PrecipM); i++ {
+		if !wet(i) {
+			stop = i
+			break
+		}
+	}
+
+	var parts []string
+	if m := in(start); m > 0 {
+		parts = append(parts, fmt.Sprintf("rain starts in %d min", m))
+	} else if stop < 0 {
+		parts = append(parts, fmt.Sprintf("rain for the next %d min", in(len(n.PrecipM))))
+	} else {
+		parts = append(parts, fmt.Sprintf("rain stops in %d min", in(stop)))
+	}
+	end := stop
+	if end < 0 {
+		end = len(n.PrecipM)
+	}
+	for i := start; i < end; i++ {
+		if n.PrecipM[i]*1000 >= nowcastHeavyMmph {
+			parts = append(parts, "heavy at "+at(i).Format("15:04"))
+			break
+		}
+	}
+	if stop >= 0 && in(start) > 0 {
+		parts = append(parts, "stops at "+at(stop).Format("15:04"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// nowcast shows the precipitation of the next one to two hours as a sparkline
+// with a short summary.
+func (a *app) nowcast(args []string) error {
+	qs, err := a.queries(args)
+	if err != nil {
+		return err
+	}
+	for _, q := range qs {
+		be, ok := iface.AllBackends[q.backend].(iface.CapableBackend)
+		if !ok || be.Capabilities().Nowcast {
+			continue
+		}
+		var names []string
+		for name, be := range iface.AllBackends {
+			if cb, ok := be.(iface.CapableBackend); ok && cb.Capabilities().Nowcast {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		return fmt.Errorf("The backend \"%s\" has no nowcast. Choose one of: %s", q.backend, strings.Join(names, ", "))
+	}
+	// some backends only request the nowcast on demand
+	for _, q := range qs {
+		if be, ok := iface.AllBackends[q.backend].(iface.CapableBackend); ok && be.Capabilities().NowcastFlag != "" {
+			if err := flag.Set(be.Capabilities().NowcastFlag, "true"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return a.text(args, 1, true, func(w io.Writer, r iface.Data) {
+		n := r.Nowcast
+		if n == nil {
+			fmt.Fprintf(w, "No nowcast for %s\n", r.Location)
+			return
+		}
+		end := n.Start.Add(time.Duration(len(n.PrecipM)) * n.Step)
+		fmt.Fprintf(w, "Nowcast for %s\n", r.Location)
+		fmt.Fprintf(w, "%s %s %s\n", n.Start.Format("15:04"), sparkline(*n), end.Format("15:04"))
+		fmt.Fprintln(w, nowcastSummary(*n, time.Now()))
+		if n.Desc != "" {
+			fmt.Fprintln(w, n.Desc)
+		}
+	})
+}
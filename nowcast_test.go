@@ -0,0 +1,48 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestNowcastSummary(t *testing.T) {
+	now := time.Date(2024, 7, 15, 14, 0, 0, 0, time.UTC)
+	mmph := func(values ...float32) []float32 {
+		for i := range values {
+			values[i] /= 1000
+		}
+		return values
+	}
+
+	tests := []struct {
+		precip []float32
+		want   string
+	}{
+		{mmph(0, 0, 0, 0), "no rain for the next 20 min"},
+		{mmph(0, 0, 1, 8, 2, 0), "rain starts in 10 min, heavy at 14:15, stops at 14:25"},
+		{mmph(0, 0.5, 0.5, 0.5), "rain starts in 5 min"},
+		{mmph(2, 2, 0, 0), "rain stops in 10 min"},
+		{mmph(9, 2, 2, 2), "rain for the next 20 min, heavy at 14:00"},
+	}
+	for _, tt := range tests {
+		n := iface.Nowcast{Start: now, Step: 5 * time.Minute, PrecipM: tt.precip}
+		if got := nowcastSummary(n, now); got != tt.want {
+			t.Errorf("nowcastSummary(%v) = %q, want %q", tt.precip, got, tt.want)
+		}
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	n := iface.Nowcast{Step: time.Minute, PrecipM: make([]float32, 120)}
+	n.PrecipM[10] = 0.0005
+	n.PrecipM[101] = 0.05
+	got := []rune(sparkline(n))
+	if len(got) != 60 {
+		t.Fatalf("sparkline has %d bars, want 60", len(got))
+	}
+	if got[0] != ' ' || got[5] != '▂' || got[50] != '█' {
+		t.Errorf("sparkline = %q", string(got))
+	}
+}
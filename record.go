@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// redacted replaces api keys in recorded requests and responses.
+const redacted = "REDACTED"
+
+// tape records the http traffic of the backends to files in dir, or replays it
+// from them without touching the network. The files have the format of the
+// conformance fixtures of the backends: the redacted body of the last
+// successful response from each host and path, named HOST_BASE.json where BASE
+// is the last element of the path. A recording can therefore be added to
+// backends/testdata/conformance as it is.
+type tape struct {
+	dir    string
+	replay bool
+	next   http.RoundTripper
+}
+
+// apiKeys returns the values of all api key flags. They are looked up for every
+// request, as bookmarks can change them.
+func apiKeys() []string {
+	var ret []string
+	flag.VisitAll(func(f *flag.Flag) {
+		if strings.HasSuffix(f.Name, "-api-key") && f.Value.String() != "" && f.Value.String() != redacted {
+			ret = append(ret, f.Value.String())
+		}
+	})
+	return ret
+}
+
+func redact(s string) string {
+	for _, key := range apiKeys() {
+		s = strings.ReplaceAll(s, key, redacted)
+		s = strings.ReplaceAll(s, url.QueryEscape(key), redacted)
+	}
+	return s
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// tapeFile returns the name of the file for all requests to the same host and
+// path.
+func tapeFile(u *url.URL) string {
+	return unsafeFileChars.ReplaceAllString(u.Host+"_"+path.Base(u.Path), "_") + ".json"
+}
+
+func (t *tape) RoundTrip(r *http.Request) (*http.Response, error) {
+	if t.replay {
+		return t.load(r)
+	}
+
+	resp, err := t.next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	// failed requests are reported by the backends, their responses would
+	// be replayed as successful ones
+	if resp.StatusCode != http.StatusOK {
+		return resp, nil
+	}
+	if err := os.WriteFile(filepath.Join(t.dir, tapeFile(r.URL)), []byte(redact(string(body))), 0644); err != nil {
+		return nil, fmt.Errorf("Unable to record the response: %v", err)
+	}
+	return resp, nil
+}
+
+// load returns the recorded response for r. Requests with time dependent
+// parameters never match exactly, so the response recorded for the same host
+// and path is used regardless of the query.
+func (t *tape) load(r *http.Request) (*http.Response, error) {
+	b, err := os.ReadFile(filepath.Join(t.dir, tapeFile(r.URL)))
+	if err != nil {
+		return nil, fmt.Errorf("no recorded response for %s in %s", redact(r.URL.String()), t.dir)
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": {"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(b)),
+		ContentLength: int64(len(b)),
+		Request:       r,
+	}, nil
+}
+
+// setupTape routes the requests of all backends through a tape recording to
+// or replaying from dir. When replaying, missing api keys are replaced by the
+// placeholder used in the recordings, so the backends do not refuse to run.
+func setupTape(dir string, replay bool) error {
+	if replay {
+		if _, err := os.Stat(dir); err != nil {
+			return fmt.Errorf("Unable to replay: %v", err)
+		}
+		flag.VisitAll(func(f *flag.Flag) {
+			if strings.HasSuffix(f.Name, "-api-key") && f.Value.String() == "" {
+				f.Value.Set(redacted)
+			}
+		})
+	} else if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("Unable to record: %v", err)
+	}
+
+	next := iface.HTTPClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	iface.HTTPClient.Transport = &tape{dir, replay, next}
+	return nil
+}
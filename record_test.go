@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestRecordReplay(t *testing.T) {
+	defer func(old *flag.FlagSet) { flag.CommandLine = old }(flag.CommandLine)
+	flag.CommandLine = flag.NewFlagSet("wego", flag.ContinueOnError)
+	key := flag.String("test-api-key", "s3cret", "")
+	defer func(old http.RoundTripper) { iface.HTTPClient.Transport = old }(iface.HTTPClient.Transport)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"echo":"`+r.URL.Query().Get("key")+`"}`)
+	}))
+	defer server.Close()
+
+	get := func(url string) string {
+		t.Helper()
+		resp, err := iface.HTTPClient.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+
+	dir := filepath.Join(t.TempDir(), "tape")
+	if err := setupTape(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(server.URL + "/v1/forecast?key=s3cret&day=1"); got != `{"echo":"s3cret"}` {
+		t.Errorf("recording changed the response to %s", got)
+	}
+	// an error is not recorded
+	get(server.URL + "/v1/missing")
+	files, _ := filepath.Glob(filepath.Join(dir, "*"))
+	if len(files) != 1 || !strings.HasSuffix(files[0], "_forecast.json") {
+		t.Fatalf("got the recorded files %v, want one HOST_forecast.json", files)
+	}
+	b, _ := os.ReadFile(files[0])
+	if string(b) != `{"echo":"REDACTED"}` {
+		t.Errorf("the api key was not redacted: %s", b)
+	}
+
+	// the replay needs neither the server nor the api key
+	server.Close()
+	iface.HTTPClient.Transport = nil
+	*key = ""
+	if err := setupTape(dir, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := get(server.URL + "/v1/forecast?key=REDACTED&day=1"); got != `{"echo":"REDACTED"}` {
+		t.Errorf("replayed %s", got)
+	}
+	if got := get(server.URL + "/v1/forecast?key=REDACTED&day=2"); got != `{"echo":"REDACTED"}` {
+		t.Errorf("replayed %s for another query of the same endpoint", got)
+	}
+	if _, err := iface.HTTPClient.Get(server.URL + "/v1/other"); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Errorf("unrecorded request gave %v", err)
+	}
+}
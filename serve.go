@@ -0,0 +1,321 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/schachmat/wego/derive"
+	"github.com/schachmat/wego/iface"
+)
+
+type cachedForecast struct {
+	data    iface.Data
+	fetched time.Time
+}
+
+// server answers weather requests for /LOCATION. The defaults are taken from
+// the global flags and the config file and can be overridden per request with
+// the days, units, backend and frontend query parameters. Bookmarks from the
+// config can be requested like locations.
+type server struct {
+	location string
+	numdays  int
+	units    string
+	backend  string
+	ttl      time.Duration
+
+	bookmarks *presetList
+
+	mu    sync.Mutex
+	cache map[string]cachedForecast
+
+	// frontends keep state while rendering, so only one may render at a time
+	renderMu sync.Mutex
+}
+
+// serveFrontends are the frontends clients may select, those only writing to
+// the response. chart writes files and mqtt publishes to a broker.
+var serveFrontends = map[string]bool{
+	"ascii-art-table": true,
+	"compare":         true,
+	"daily":           true,
+	"emoji":           true,
+	"graph":           true,
+	"json":            true,
+	"markdown":        true,
+	"prometheus":      true,
+}
+
+// serveLocalBackends read local files or devices on the local network, clients
+// may only get them as the default backend of the server.
+var serveLocalBackends = map[string]bool{
+	"json": true,
+	"mqtt": true,
+	"pws":  true,
+}
+
+// fetch returns the forecast from the cache or the backend. The lock is not
+// held while fetching, so a slow backend does not block other locations.
+func (s *server) fetch(backend, location string, numdays int) (iface.Data, error) {
+	key := fmt.Sprintf("%s|%s|%d", backend, strings.ToLower(location), numdays)
+	s.mu.Lock()
+	c, ok := s.cache[key]
+	s.mu.Unlock()
+	if ok && time.Since(c.fetched) < s.ttl {
+		return c.data, nil
+	}
+
+	data, err := fetchBackend(query{location, backend, numdays})
+	if err != nil {
+		return data, err
+	}
+	data, _ = iface.Normalize(data, numdays)
+	data = derive.Data(data)
+	data.Backend = backend
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, c := range s.cache {
+		if time.Since(c.fetched) >= s.ttl {
+			delete(s.cache, k)
+		}
+	}
+	s.cache[key] = cachedForecast{data, time.Now()}
+	return data, nil
+}
+
+// negotiate selects the frontend and whether the output should be converted to
+// html. Terminal clients get ANSI text, browsers html and clients accepting
+// json the output of the json frontend.
+func (s *server) negotiate(r *http.Request) (frontend string, asHTML bool) {
+	accept := r.Header.Get("Accept")
+	ua := strings.ToLower(r.UserAgent())
+	browser := strings.Contains(accept, "text/html")
+	for _, cli := range []string{"curl", "wget", "httpie", "powershell"} {
+		if strings.Contains(ua, cli) {
+			browser = false
+		}
+	}
+
+	frontend = r.URL.Query().Get("frontend")
+	if frontend == "" {
+		frontend = "ascii-art-table"
+		if strings.Contains(accept, "application/json") && !browser {
+			frontend = "json"
+		}
+	}
+	return frontend, browser && frontend != "json"
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if r.URL.Path == "/favicon.ico" || r.URL.Path == "/robots.txt" {
+		http.NotFound(w, r)
+		return
+	}
+
+	location := strings.ReplaceAll(strings.TrimPrefix(r.URL.Path, "/"), "+", " ")
+	if location == "" {
+		location = s.location
+	}
+	numdays, backend := s.numdays, s.backend
+	if bm, ok := s.bookmarks.get(location); ok {
+		location = bm.location
+		if v, ok := bm.option("backend"); ok {
+			backend = v
+		}
+		if v, ok := bm.option("days"); ok {
+			if days, err := strconv.Atoi(v); err == nil {
+				numdays = days
+			}
+		}
+	}
+
+	q := r.URL.Query()
+	if days := q.Get("days"); days != "" {
+		v, err := strconv.Atoi(days)
+		if err != nil || v < 0 {
+			http.Error(w, fmt.Sprintf("invalid number of days %q", days), http.StatusBadRequest)
+			return
+		}
+		numdays = v
+	}
+	units := s.units
+	if u := q.Get("units"); u != "" {
+		units = u
+	}
+	unit, ok := parseUnitSystem(units)
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown unit system %q", units), http.StatusBadRequest)
+		return
+	}
+	if b := q.Get("backend"); b != "" {
+		if serveLocalBackends[b] && b != s.backend {
+			http.Error(w, fmt.Sprintf("the %s backend can not be selected by clients", b), http.StatusForbidden)
+			return
+		}
+		backend = b
+	}
+	if _, ok := iface.AllBackends[backend]; !ok {
+		http.Error(w, fmt.Sprintf("unknown backend %q", backend), http.StatusBadRequest)
+		return
+	}
+	if err := checkQuery(query{location, backend, numdays}); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	frontend, asHTML := s.negotiate(r)
+	fe, ok := iface.AllFrontends[frontend]
+	if !ok {
+		http.Error(w, fmt.Sprintf("unknown frontend %q", frontend), http.StatusBadRequest)
+		return
+	}
+	if !serveFrontends[frontend] {
+		http.Error(w, fmt.Sprintf("the %s frontend can not be selected by clients", frontend), http.StatusForbidden)
+		return
+	}
+
+	data, err := s.fetch(backend, location, numdays)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+
+	var out bytes.Buffer
+	s.renderMu.Lock()
+	err = fe.Render(&out, data, unit)
+	s.renderMu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Vary", "Accept, User-Agent")
+	switch {
+	case frontend == "json":
+		w.Header().Set("Content-Type", "application/json")
+		out.WriteTo(w)
+	case asHTML:
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Weather for %s</title>\n", html.EscapeString(data.Location))
+		fmt.Fprint(w, "<style>body{background:#000;color:#bbb}pre{font-family:'DejaVu Sans Mono',monospace;font-size:14px;line-height:1.1}</style>\n")
+		fmt.Fprintf(w, "</head>\n<body>\n<pre>%s</pre>\n</body>\n</html>\n", ansiToHTML(out.String()))
+	default:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		out.WriteTo(w)
+	}
+}
+
+var ansiEsc = regexp.MustCompile("\033\\[([0-9;]*)m")
+
+// ansi256 returns the html color of an xterm 256 color palette entry.
+func ansi256(n int) string {
+	base := []string{
+		"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+		"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+	}
+	switch {
+	case n < 16:
+		return base[n]
+	case n < 232:
+		n -= 16
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + v*40
+		}
+		return fmt.Sprintf("#%02x%02x%02x", level(n/36), level(n/6%6), level(n%6))
+	default:
+		g := 8 + (n-232)*10
+		return fmt.Sprintf("#%02x%02x%02x", g, g, g)
+	}
+}
+
+// ansiToHTML converts text with ANSI color and bold escape sequences, as used
+// by the terminal frontends, to html spans.
+func ansiToHTML(s string) string {
+	var b strings.Builder
+	open := false
+	color, bold := "", false
+	last := 0
+	for _, m := range ansiEsc.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(html.EscapeString(s[last:m[0]]))
+		last = m[1]
+
+		params := strings.Split(s[m[2]:m[3]], ";")
+		for i := 0; i < len(params); i++ {
+			switch params[i] {
+			case "", "0":
+				color, bold = "", false
+			case "1":
+				bold = true
+			case "22":
+				bold = false
+			case "39":
+				color = ""
+			case "38":
+				if i+2 < len(params) && params[i+1] == "5" {
+					if n, err := strconv.Atoi(params[i+2]); err == nil && n >= 0 && n < 256 {
+						color = ansi256(n)
+					}
+					i += 2
+				}
+			}
+		}
+
+		if open {
+			b.WriteString("</span>")
+			open = false
+		}
+		if color != "" || bold {
+			style := ""
+			if color != "" {
+				style += "color:" + color + ";"
+			}
+			if bold {
+				style += "font-weight:bold;"
+			}
+			fmt.Fprintf(&b, `<span style="%s">`, style)
+			open = true
+		}
+	}
+	b.WriteString(html.EscapeString(s[last:]))
+	if open {
+		b.WriteString("</span>")
+	}
+	return b.String()
+}
+
+// serve runs wego as an http server. args are the command line arguments
+// following the serve subcommand.
+func (a *app) serve(args []string) error {
+	s := &server{
+		location:  *a.location,
+		numdays:   *a.numdays,
+		units:     *a.unitSystem,
+		backend:   *a.backend,
+		bookmarks: a.bookmarks,
+		cache:     make(map[string]cachedForecast),
+	}
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", ":8080", "`ADDRESS` to listen on")
+	fs.DurationVar(&s.ttl, "cache-ttl", 10*time.Minute, "`DURATION` to cache the forecast of a location")
+	fs.Parse(args)
+
+	log.Printf("Serving weather forecasts on %s, try http://%s/%s", *listen, *listen, url.PathEscape(s.location))
+	return http.ListenAndServe(*listen, s)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// failingBackend fails like a backend getting a malformed response.
+type failingBackend struct{ panics bool }
+
+func (failingBackend) Setup() {}
+func (b failingBackend) Fetch(location string, numdays int) (iface.Data, error) {
+	if b.panics {
+		var slots []iface.Cond
+		return iface.Data{Current: slots[0]}, nil
+	}
+	return iface.Data{}, fmt.Errorf("Failed to fetch weather data: %s is nowhere", location)
+}
+
+func TestServeNegotiate(t *testing.T) {
+	tests := []struct {
+		target, accept, ua string
+		frontend           string
+		asHTML             bool
+	}{
+		{"/", "*/*", "curl/8.5.0", "ascii-art-table", false},
+		{"/", "text/html,application/xhtml+xml", "Mozilla/5.0", "ascii-art-table", true},
+		{"/", "text/html", "Wget/1.21", "ascii-art-table", false},
+		{"/", "application/json", "python-requests/2.31", "json", false},
+		{"/?frontend=json", "text/html", "Mozilla/5.0", "json", false},
+		{"/?frontend=emoji", "text/html", "Mozilla/5.0", "emoji", true},
+	}
+	s := &server{}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", tt.target, nil)
+		r.Header.Set("Accept", tt.accept)
+		r.Header.Set("User-Agent", tt.ua)
+		if frontend, asHTML := s.negotiate(r); frontend != tt.frontend || asHTML != tt.asHTML {
+			t.Errorf("negotiate(%s, %q, %q) = %s, %v, want %s, %v", tt.target, tt.accept, tt.ua, frontend, asHTML, tt.frontend, tt.asHTML)
+		}
+	}
+}
+
+func TestAnsiToHTML(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"plain <text>", "plain &lt;text&gt;"},
+		{"\033[38;5;226mSun\033[0m", `<span style="color:#ffff00;">Sun</span>`},
+		{"\033[1mbold\033[22m", `<span style="font-weight:bold;">bold</span>`},
+		{"\033[1;38;5;21mx\033[39my\033[0m", `<span style="color:#0000ff;font-weight:bold;">x</span><span style="font-weight:bold;">y</span>`},
+		{"\033[38;5;244mgray", `<span style="color:#808080;">gray</span>`},
+	}
+	for _, tt := range tests {
+		if got := ansiToHTML(tt.in); got != tt.want {
+			t.Errorf("ansiToHTML(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	iface.AllBackends["test"] = stationBackend{}
+	iface.AllBackends["failing"] = failingBackend{}
+	iface.AllBackends["panicking"] = failingBackend{panics: true}
+	defer func() {
+		for _, name := range []string{"test", "failing", "panicking"} {
+			delete(iface.AllBackends, name)
+		}
+	}()
+	s := &server{location: "Berlin", numdays: 1, units: "metric", backend: "test", ttl: time.Minute,
+		bookmarks: &presetList{}, cache: make(map[string]cachedForecast)}
+
+	tests := []struct {
+		method, target string
+		code           int
+	}{
+		{"GET", "/Paris?frontend=json", http.StatusOK},
+		{"POST", "/Paris", http.StatusMethodNotAllowed},
+		{"GET", "/Paris?days=-1", http.StatusBadRequest},
+		{"GET", "/Paris?units=kelvin", http.StatusBadRequest},
+		{"GET", "/Paris?backend=nope", http.StatusBadRequest},
+		{"GET", "/Paris?frontend=nope", http.StatusBadRequest},
+		{"GET", "/Paris?backend=json", http.StatusForbidden},
+		{"GET", "/Paris?backend=pws", http.StatusForbidden},
+		{"GET", "/Paris?backend=mqtt", http.StatusForbidden},
+		{"GET", "/Paris?frontend=chart", http.StatusForbidden},
+		{"GET", "/Paris?frontend=mqtt", http.StatusForbidden},
+		{"GET", "/nowhere?backend=failing&frontend=json", http.StatusBadGateway},
+		{"GET", "/nowhere?backend=panicking&frontend=json", http.StatusBadGateway},
+		// the server survives failing backends
+		{"GET", "/Rome?frontend=json", http.StatusOK},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(tt.method, tt.target, nil))
+		if rec.Code != tt.code {
+			t.Errorf("%s %s gave %d, want %d: %s", tt.method, tt.target, rec.Code, tt.code, strings.TrimSpace(rec.Body.String()))
+		}
+	}
+}
+
+func TestServeCache(t *testing.T) {
+	iface.AllBackends["test"] = stationBackend{}
+	iface.AllBackends["failing"] = failingBackend{}
+	defer delete(iface.AllBackends, "test")
+	defer delete(iface.AllBackends, "failing")
+	s := &server{ttl: time.Minute, cache: map[string]cachedForecast{
+		"test|old|1": {iface.Data{Location: "old"}, time.Now().Add(-time.Hour)},
+	}}
+
+	data, err := s.fetch("test", "Paris", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.cache["test|old|1"]; ok {
+		t.Errorf("the expired forecast was not pruned")
+	}
+	s.cache["test|paris|1"] = cachedForecast{iface.Data{Location: "cached"}, time.Now()}
+	if data, err = s.fetch("test", "Paris", 1); err != nil || data.Location != "cached" {
+		t.Errorf("got %q, %v instead of the cached forecast", data.Location, err)
+	}
+	if _, err := s.fetch("failing", "Paris", 1); err == nil {
+		t.Errorf("the error of the backend was not returned")
+	}
+}
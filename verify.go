@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+// codeCategories groups the weather codes for the hit rate, as observations
+// rarely tell light from heavy showers reliably.
+var codeCategories = map[iface.WeatherCode]string{
+	iface.CodeSunny:               "clear",
+	iface.CodePartlyCloudy:        "clear",
+	iface.CodeCloudy:              "cloudy",
+	iface.CodeVeryCloudy:          "cloudy",
+	iface.CodeFog:                 "cloudy",
+	iface.CodeLightShowers:        "rain",
+	iface.CodeLightRain:           "rain",
+	iface.CodeHeavyShowers:        "rain",
+	iface.CodeHeavyRain:           "rain",
+	iface.CodeLightSleet:          "rain",
+	iface.CodeLightSleetShowers:   "rain",
+	iface.CodeLightSnow:           "snow",
+	iface.CodeLightSnowShowers:    "snow",
+	iface.CodeHeavySnow:           "snow",
+	iface.CodeHeavySnowShowers:    "snow",
+	iface.CodeThunderyShowers:     "thunder",
+	iface.CodeThunderyHeavyRain:   "thunder",
+	iface.CodeThunderySnowShowers: "thunder",
+}
+
+// verifyMaxOffset is the maximum time between a forecast slot and the
+// observation it is compared with.
+const verifyMaxOffset = 30 * time.Minute
+
+// verifyResult holds the accuracy of the forecasts of a backend for one range
+// of lead times. The scores are nil if there were no samples for them.
+type verifyResult struct {
+	Backend string
+
+	// LeadHours is the start of the range of lead times, which is one day
+	// long.
+	LeadHours int
+
+	// Samples is the number of forecast slots compared with an observation.
+	Samples int
+
+	// TempMAEC and TempBiasC are the mean absolute and the mean error of the
+	// temperature in degrees celsius.
+	TempMAEC  *float64
+	TempBiasC *float64
+
+	// Brier is the mean squared error of the chance of rain, from 0 for
+	// perfect forecasts to 1.
+	Brier *float64
+
+	// CodeHitRate is the share of forecasts with the observed kind of weather.
+	CodeHitRate *float64
+
+	tempN, brierN, codeN int
+	tempAbs, tempSum     float64
+	brierSum             float64
+	codeHits             int
+}
+
+func (r *verifyResult) add(forecast, observed iface.Cond) {
+	r.Samples++
+	if forecast.TempC != nil && observed.TempC != nil {
+		d := float64(*forecast.TempC - *observed.TempC)
+		r.tempN++
+		r.tempSum += d
+		r.tempAbs += math.Abs(d)
+	}
+	if rain, ok := rained(observed); ok && forecast.ChanceOfRainPercent != nil {
+		p := float64(*forecast.ChanceOfRainPercent) / 100
+		if rain {
+			p--
+		}
+		r.brierN++
+		r.brierSum += p * p
+	}
+	if want, ok := codeCategories[observed.Code]; ok {
+		if got, ok := codeCategories[forecast.Code]; ok {
+			r.codeN++
+			if got == want {
+				r.codeHits++
+			}
+		}
+	}
+}
+
+// finish computes the scores from the sums.
+func (r *verifyResult) finish() {
+	mean := func(sum float64, n int) *float64 {
+		if n == 0 {
+			return nil
+		}
+		v := sum / float64(n)
+		return &v
+	}
+	r.TempMAEC = mean(r.tempAbs, r.tempN)
+	r.TempBiasC = mean(r.tempSum, r.tempN)
+	r.Brier = mean(r.brierSum, r.brierN)
+	r.CodeHitRate = mean(float64(r.codeHits), r.codeN)
+}
+
+// rained reports whether precipitation was observed in c, judged by the
+// amount if it is known and by the weather code otherwise. The second result
+// is false if c tells neither.
+func rained(c iface.Cond) (bool, bool) {
+	if c.PrecipM != nil {
+		return *c.PrecipM > 0, true
+	}
+	category, ok := codeCategories[c.Code]
+	return category == "rain" || category == "snow" || category == "thunder", ok
+}
+
+// verifyForecasts compares the slots of the archived forecasts with the
+// observations of the same location and about the same time.
+func verifyForecasts(forecasts []cacheEntry, observations []historyEntry) []verifyResult {
+	byLocation := make(map[string][]iface.Cond)
+	for _, o := range observations {
+		loc := strings.ToLower(o.Location)
+		byLocation[loc] = append(byLocation[loc], o.Cond)
+	}
+	for _, obs := range byLocation {
+		sort.Slice(obs, func(i, j int) bool { return obs[i].Time.Before(obs[j].Time) })
+	}
+
+	type key struct {
+		backend string
+		lead    int
+	}
+	results := make(map[key]*verifyResult)
+	for _, e := range forecasts {
+		obs := byLocation[strings.ToLower(e.Location)]
+		if len(obs) == 0 {
+			continue
+		}
+		for _, day := range e.Data.Forecast {
+			for _, slot := range day.Slots {
+				lead := slot.Time.Sub(e.Fetched)
+				if lead < 0 {
+					continue
+				}
+				i := sort.Search(len(obs), func(i int) bool { return !obs[i].Time.Before(slot.Time) })
+				best := -1
+				for _, j := range []int{i - 1, i} {
+					if j >= 0 && j < len(obs) && (best < 0 || obs[j].Time.Sub(slot.Time).Abs() < obs[best].Time.Sub(slot.Time).Abs()) {
+						best = j
+					}
+				}
+				if best < 0 || obs[best].Time.Sub(slot.Time).Abs() > verifyMaxOffset {
+					continue
+				}
+				k := key{e.Backend, int(lead/(24*time.Hour)) * 24}
+				if results[k] == nil {
+					results[k] = &verifyResult{Backend: k.backend, LeadHours: k.lead}
+				}
+				results[k].add(slot, obs[best])
+			}
+		}
+	}
+
+	ret := make([]verifyResult, 0, len(results))
+	for _, r := range results {
+		r.finish()
+		ret = append(ret, *r)
+	}
+	sort.Slice(ret, func(i, j int) bool {
+		if ret[i].Backend != ret[j].Backend {
+			return ret[i].Backend < ret[j].Backend
+		}
+		return ret[i].LeadHours < ret[j].LeadHours
+	})
+	return ret
+}
+
+// verifyStations are the backends whose current conditions are measured by a
+// weather station instead of taken from a model.
+const verifyStations = "pws,mqtt"
+
+// verify rates the archived forecasts of every backend against the recorded
+// current conditions of weather stations.
+func (a *app) verify(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ExitOnError)
+	asJSON := flags.Bool("json", false, "print the results as json with temperatures in degrees celsius")
+	observed := flags.String("observed", verifyStations, "`BACKENDS` separated by commas whose current conditions are the observations")
+	flags.Parse(args)
+	observers := make(map[string]bool)
+	for _, b := range strings.Split(*observed, ",") {
+		observers[strings.TrimSpace(b)] = true
+	}
+
+	// locations given as arguments limit the verification to them
+	var locations []string
+	for _, arg := range flags.Args() {
+		if bm, ok := a.bookmarks.get(arg); ok {
+			arg = bm.location
+		}
+		locations = append(locations, arg)
+	}
+	wanted := func(location string) bool {
+		if len(locations) == 0 {
+			return true
+		}
+		for _, l := range locations {
+			if strings.EqualFold(l, location) {
+				return true
+			}
+		}
+		return false
+	}
+
+	all, err := a.archive.all()
+	if err != nil {
+		return err
+	}
+	var forecasts []cacheEntry
+	for _, e := range all {
+		if wanted(e.Location) {
+			forecasts = append(forecasts, e)
+		}
+	}
+	entries, err := a.history.entries()
+	if err != nil {
+		return err
+	}
+	var observations []historyEntry
+	for _, e := range entries {
+		if wanted(e.Location) && observers[e.Backend] {
+			observations = append(observations, e)
+		}
+	}
+
+	results := verifyForecasts(forecasts, observations)
+	if len(results) == 0 {
+		return fmt.Errorf("No archived forecast could be compared with an observation of %s. Run wego with -archive and -history for a few days to collect both, or choose other backends with -observed.", *observed)
+	}
+	out, closeOut, err := a.writer()
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			closeOut()
+			return err
+		}
+		return closeOut()
+	}
+
+	unit := a.unit()
+	_, tu := unit.Temp(0)
+	// temperature differences are converted without the offset of the scale
+	temp := func(v *float64, format string) string {
+		if v == nil {
+			return "-"
+		}
+		d, _ := unit.Temp(float32(*v))
+		zero, _ := unit.Temp(0)
+		return fmt.Sprintf(format+" %s", d-zero, tu)
+	}
+	score := func(v *float64, format string, factor float64) string {
+		if v == nil {
+			return "-"
+		}
+		return fmt.Sprintf(format, *v*factor)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "BACKEND\tLEAD\tSAMPLES\tTEMP MAE\tTEMP BIAS\tBRIER\tCODE HITS")
+	for _, r := range results {
+		fmt.Fprintf(w, "%s\t%d-%dh\t%d\t%s\t%s\t%s\t%s\n", r.Backend, r.LeadHours, r.LeadHours+24, r.Samples,
+			temp(r.TempMAEC, "%.1f"), temp(r.TempBiasC, "%+.1f"), score(r.Brier, "%.2f", 1), score(r.CodeHitRate, "%.0f%%", 100))
+	}
+	if err := w.Flush(); err != nil {
+		closeOut()
+		return err
+	}
+	return closeOut()
+}
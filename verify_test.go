@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/schachmat/wego/iface"
+)
+
+func TestVerifyForecasts(t *testing.T) {
+	start := time.Date(2024, 7, 14, 0, 0, 0, 0, time.UTC)
+	temp := func(v float32) *float32 { return &v }
+	chance := func(v int) *int { return &v }
+	forecast := func(backend string, fetched time.Time, slots ...iface.Cond) cacheEntry {
+		return cacheEntry{Backend: backend, Location: "Paris", Fetched: fetched, Data: iface.Data{
+			Forecast: []iface.Day{{Date: start, Slots: slots}},
+		}}
+	}
+	observations := []historyEntry{
+		{"yr", "paris", iface.Cond{Time: start.Add(12*time.Hour + 10*time.Minute), Code: iface.CodeLightRain, TempC: temp(20)}},
+		{"yr", "Paris", iface.Cond{Time: start.Add(18 * time.Hour), Code: iface.CodeSunny, TempC: temp(16), PrecipM: temp(0)}},
+		{"yr", "Oslo", iface.Cond{Time: start.Add(12 * time.Hour), Code: iface.CodeSunny, TempC: temp(10)}},
+	}
+
+	results := verifyForecasts([]cacheEntry{
+		forecast("smhi", start.Add(6*time.Hour),
+			iface.Cond{Time: start.Add(3 * time.Hour), TempC: temp(30)},
+			iface.Cond{Time: start.Add(12 * time.Hour), Code: iface.CodeHeavyShowers, TempC: temp(22), ChanceOfRainPercent: chance(80)},
+			iface.Cond{Time: start.Add(15 * time.Hour), TempC: temp(30)},
+			iface.Cond{Time: start.Add(18 * time.Hour), Code: iface.CodeLightRain, TempC: temp(17), ChanceOfRainPercent: chance(60)}),
+		forecast("smhi", start.Add(-30*time.Hour),
+			iface.Cond{Time: start.Add(12 * time.Hour), Code: iface.CodeSunny, TempC: temp(17)}),
+	}, observations)
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2: %+v", len(results), results)
+	}
+	r := results[0]
+	if r.Backend != "smhi" || r.LeadHours != 0 || r.Samples != 2 {
+		t.Fatalf("first result is %+v", r)
+	}
+	near := func(p *float64, want float64) bool { return p != nil && math.Abs(*p-want) < 1e-6 }
+	if !near(r.TempMAEC, 1.5) || !near(r.TempBiasC, 1.5) {
+		t.Errorf("temperature MAE %v and bias %v, want 1.5 and 1.5", *r.TempMAEC, *r.TempBiasC)
+	}
+	if !near(r.Brier, (0.2*0.2+0.6*0.6)/2) {
+		t.Errorf("Brier score %v, want 0.2", *r.Brier)
+	}
+	if !near(r.CodeHitRate, 0.5) {
+		t.Errorf("code hit rate %v, want 0.5", *r.CodeHitRate)
+	}
+
+	r = results[1]
+	if r.LeadHours != 24 || r.Samples != 1 || !near(r.TempBiasC, -3) || r.Brier != nil {
+		t.Errorf("second result is %+v", r)
+	}
+}
+
+func TestVerifyCommand(t *testing.T) {
+	dir := t.TempDir()
+	units, output := "metric", filepath.Join(dir, "out")
+	a := &app{unitSystem: &units, output: &output, bookmarks: &presetList{bookmarks: true},
+		history: &historyStore{filepath.Join(dir, "history.jsonl"), true}, archive: &forecastArchive{dir, true}}
+	temp := func(v float32) *float32 { return &v }
+	at := time.Now().Add(time.Hour)
+	paris := func(backend string) query { return query{"Paris", backend, 1} }
+
+	if err := a.archive.add(paris("smhi"), iface.Data{Forecast: []iface.Day{{Date: at, Slots: []iface.Cond{{Time: at, TempC: temp(20)}}}}}); err != nil {
+		t.Fatal(err)
+	}
+	// the current conditions of a model are no observations by default
+	if err := a.history.add(paris("smhi"), iface.Data{Current: iface.Cond{Time: at, TempC: temp(20)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.verify(nil); err == nil || !strings.Contains(err.Error(), "-observed") {
+		t.Fatalf("verify without station observations gave %v", err)
+	}
+
+	if err := a.history.add(paris("pws"), iface.Data{Current: iface.Cond{Time: at, TempC: temp(18)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.verify([]string{"-json"}); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var results []verifyResult
+	if err := json.Unmarshal(b, &results); err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Samples != 1 || results[0].TempBiasC == nil || *results[0].TempBiasC != 2 {
+		t.Errorf("got %s, want one sample compared with the station", b)
+	}
+
+	if err := a.verify([]string{"-observed", "smhi"}); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ = os.ReadFile(output); !strings.Contains(string(b), "smhi") || !strings.Contains(string(b), "+0.0") {
+		t.Errorf("got %q with the observations of smhi", b)
+	}
+}